@@ -2,6 +2,8 @@ package authentication
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"log"
@@ -9,6 +11,7 @@ import (
 	"time"
 
 	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
 	"github.com/DanSmirnov48/techno-trades-go-backend/models"
 	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
 	"github.com/gofiber/fiber/v2"
@@ -20,6 +23,7 @@ import (
 
 var cfg = config.GetConfig()
 var SECRETKEY = []byte(cfg.SecretKey)
+var sessionManager = managers.SessionManager{}
 
 type CookieType string
 
@@ -28,20 +32,47 @@ const (
 	RefreshToken CookieType = "refreshToken"
 )
 
+// AccessTokenPayload and RefreshTokenPayload share their ids, Purpose is
+// what stops one kind of token being passed off as the other
 type AccessTokenPayload struct {
-	UserId uuid.UUID `json:"user_id"`
+	UserId    uuid.UUID `json:"user_id"`
+	SessionId uuid.UUID `json:"session_id"`
+	Purpose   string    `json:"purpose"`
 	jwt.RegisteredClaims
 }
 
+// RefreshTokenPayload binds a refresh token to the session it belongs to.
+// Data keeps each issued token unique, so a rotated token never hashes the
+// same as its replacement.
 type RefreshTokenPayload struct {
-	Data string `json:"data"`
+	UserId    uuid.UUID `json:"user_id"`
+	SessionId uuid.UUID `json:"session_id"`
+	Purpose   string    `json:"purpose"`
+	Data      string    `json:"data"`
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(userId uuid.UUID) string {
+// MfaTokenPayload is handed out at login when the first factor was right but
+// a two-factor code is still needed. It only works on the verify endpoint.
+type MfaTokenPayload struct {
+	UserId  uuid.UUID `json:"user_id"`
+	Purpose string    `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+const (
+	accessTokenPurpose    = "access"
+	refreshTokenPurpose   = "refresh"
+	mfaTokenPurpose       = "mfa"
+	mfaTokenExpireMinutes = 5
+)
+
+func GenerateAccessToken(userId uuid.UUID, sessionId uuid.UUID) string {
 	expirationTime := time.Now().Add(time.Duration(cfg.AccessTokenExpireMinutes) * time.Minute)
 	payload := AccessTokenPayload{
-		UserId: userId,
+		UserId:    userId,
+		SessionId: sessionId,
+		Purpose:   accessTokenPurpose,
 		RegisteredClaims: jwt.RegisteredClaims{
 			// In JWT, the expiry time is expressed as unix milliseconds
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
@@ -59,7 +90,9 @@ func GenerateAccessToken(userId uuid.UUID) string {
 	return tokenString
 }
 
-func DecodeAccessToken(token string, db *gorm.DB) (*models.User, *string) {
+// DecodeAccessToken returns the token's user along with the session it was
+// issued for. Tokens from a revoked or expired session are refused.
+func DecodeAccessToken(token string, db *gorm.DB) (*models.User, *models.Session, *string) {
 	claims := &AccessTokenPayload{}
 
 	tkn, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
@@ -67,10 +100,17 @@ func DecodeAccessToken(token string, db *gorm.DB) (*models.User, *string) {
 	})
 	tokenErr := "Auth Token is Invalid or Expired!"
 	if err != nil {
-		return nil, &tokenErr
+		return nil, nil, &tokenErr
 	}
-	if !tkn.Valid {
-		return nil, &tokenErr
+	if !tkn.Valid || claims.Purpose != accessTokenPurpose {
+		return nil, nil, &tokenErr
+	}
+
+	// Check the device is still signed in
+	session := sessionManager.GetActive(db, claims.SessionId, claims.UserId)
+	if session == nil {
+		sessionErr := "Session has been signed out"
+		return nil, nil, &sessionErr
 	}
 
 	// Fetch User model object
@@ -79,15 +119,18 @@ func DecodeAccessToken(token string, db *gorm.DB) (*models.User, *string) {
 	user := models.User{ID: userId}
 	result := db.Where(user).First(&user)
 	if result.Error != nil {
-		return nil, &tokenErr
+		return nil, nil, &tokenErr
 	}
-	return &user, nil
+	return &user, session, nil
 }
 
-func GenerateRefreshToken() string {
+func GenerateRefreshToken(userId uuid.UUID, sessionId uuid.UUID) string {
 	expirationTime := time.Now().Add(time.Duration(cfg.RefreshTokenExpireMinutes) * time.Minute)
 	payload := RefreshTokenPayload{
-		Data: utils.GetRandomString(10),
+		UserId:    userId,
+		SessionId: sessionId,
+		Purpose:   refreshTokenPurpose,
+		Data:      utils.GetRandomString(10),
 		RegisteredClaims: jwt.RegisteredClaims{
 			// In JWT, the expiry time is expressed as unix milliseconds
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
@@ -105,19 +148,58 @@ func GenerateRefreshToken() string {
 	return tokenString
 }
 
-func DecodeRefreshToken(token string) bool {
+// DecodeRefreshToken returns the claims of a genuine, unexpired refresh
+// token. Whether it is still the session's current one is up to the caller.
+// Other tokens are refused here, so they never count as a reused refresh token.
+func DecodeRefreshToken(token string) (*RefreshTokenPayload, bool) {
 	claims := &RefreshTokenPayload{}
 	tkn, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
 		return SECRETKEY, nil
 	})
 	if err != nil {
-		return false
+		return nil, false
 	}
-	if !tkn.Valid {
+	if !tkn.Valid || claims.Purpose != refreshTokenPurpose || claims.SessionId == uuid.Nil {
 		log.Println("Invalid Refresh Token")
-		return false
+		return nil, false
+	}
+	return claims, true
+}
+
+func GenerateMfaToken(userId uuid.UUID) string {
+	expirationTime := time.Now().Add(mfaTokenExpireMinutes * time.Minute)
+	payload := MfaTokenPayload{
+		UserId:  userId,
+		Purpose: mfaTokenPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+		},
 	}
-	return true
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, payload)
+	tokenString, err := token.SignedString(SECRETKEY)
+	if err != nil {
+		log.Fatal("Error Generating Mfa token: ", err)
+	}
+	return tokenString
+}
+
+// DecodeMfaToken returns the id of the user who is part way through logging in
+func DecodeMfaToken(token string) (*uuid.UUID, bool) {
+	claims := &MfaTokenPayload{}
+	tkn, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return SECRETKEY, nil
+	})
+	if err != nil || !tkn.Valid || claims.Purpose != mfaTokenPurpose {
+		return nil, false
+	}
+	return &claims.UserId, true
+}
+
+// HashRefreshToken is how a refresh token is stored against its session
+func HashRefreshToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
 
 func SetAuthCookie(c *fiber.Ctx, cookieType CookieType, token string) {
@@ -212,6 +294,8 @@ func ValidateAndFetchGoogleUser(ctx context.Context, oauthConfig *oauth2.Config,
 	db.Take(&user, user)
 	if user.ID != uuid.Nil && user.AuthType == models.AuthTypePassword {
 		return nil, errors.New("requires password to sign in to this account")
+	} else if user.ID != uuid.Nil && user.AuthType == models.AuthTypePasskey {
+		return nil, errors.New("requires passkey to sign in to this account")
 	} else if user.ID == uuid.Nil {
 		user := models.User{
 			FirstName:       googleUser.GivenName,
@@ -0,0 +1,62 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const GuestCart CookieType = "guestCart"
+
+// Guest carts outlive a session so a returning shopper finds their basket
+const guestCartExpireMinutes = 60 * 24 * 30
+
+func signGuestCart(cartId string) string {
+	mac := hmac.New(sha256.New, SECRETKEY)
+	mac.Write([]byte("guest-cart:" + cartId))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateGuestCartToken signs a guest cart id so clients can't swap in
+// someone else's cart
+func GenerateGuestCartToken(cartId uuid.UUID) string {
+	return cartId.String() + "." + signGuestCart(cartId.String())
+}
+
+func DecodeGuestCartToken(token string) (*uuid.UUID, bool) {
+	cartId, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(signGuestCart(cartId))) {
+		return nil, false
+	}
+	id, err := uuid.Parse(cartId)
+	if err != nil {
+		return nil, false
+	}
+	return &id, true
+}
+
+func SetGuestCartCookie(c *fiber.Ctx, cartId uuid.UUID) {
+	c.Cookie(&fiber.Cookie{
+		Name:     string(GuestCart),
+		Value:    GenerateGuestCartToken(cartId),
+		Expires:  time.Now().Add(time.Duration(guestCartExpireMinutes) * time.Minute),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https" || c.Get("X-Forwarded-Proto") == "https",
+		SameSite: "Lax", // Survives arriving from a link to the shop
+	})
+}
+
+func RemoveGuestCartCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     string(GuestCart),
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+}
@@ -0,0 +1,72 @@
+package authentication
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+var idempotencyManager = managers.IdempotencyManager{}
+
+// Idempotency replays the first response to a request when it's retried with
+// the same Idempotency-Key header, so a double-click doesn't do the work twice.
+// Keys are scoped to the user and route. It must run after AuthMiddleware,
+// and requests without the header go straight through.
+func (mid Middleware) Idempotency(c *fiber.Ctx) error {
+	key := strings.TrimSpace(c.Get(IdempotencyKeyHeader))
+	if key == "" {
+		return c.Next()
+	}
+	if len(key) > 255 {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, "Idempotency-Key must be at most 255 characters"))
+	}
+	user, ok := c.Locals("user").(*models.User)
+	if !ok || user == nil {
+		return c.Next()
+	}
+
+	db := mid.DB
+	hash := sha256.Sum256(c.Body())
+	route := c.Method() + " " + c.Path()
+	record, replay, errCode, errData := idempotencyManager.Begin(db, user.ID, key, route, hex.EncodeToString(hash[:]))
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if replay {
+		c.Set("Idempotent-Replayed", "true")
+		if record.ContentType != "" {
+			c.Set(fiber.HeaderContentType, record.ContentType)
+		}
+		return c.Status(record.StatusCode).Send(record.Body)
+	}
+
+	// The key is let go unless a response gets stored, so an error or a
+	// panic in the handler doesn't leave it stuck in flight
+	completed := false
+	defer func() {
+		if !completed {
+			idempotencyManager.Release(db, record)
+		}
+	}()
+
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	// Server errors aren't kept so the retry gets a real second attempt
+	statusCode := c.Response().StatusCode()
+	if statusCode >= 500 {
+		return nil
+	}
+	body := append([]byte(nil), c.Response().Body()...)
+	idempotencyManager.Complete(db, record, statusCode, string(c.Response().Header.ContentType()), body)
+	completed = true
+	return nil
+}
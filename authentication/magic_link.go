@@ -0,0 +1,74 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MagicLink holds a random nonce tying a sign-in link to the browser that
+// asked for it, so a forwarded link is no use to whoever it was sent to
+const MagicLink CookieType = "magicLink"
+
+func randomToken() string {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func signMagicLink(value string) string {
+	mac := hmac.New(sha256.New, SECRETKEY)
+	mac.Write([]byte("magic-link:" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// HashMagicLinkValue is how link tokens and nonces are stored
+func HashMagicLinkValue(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+// GenerateMagicLinkToken returns a new signed token for a sign-in link
+func GenerateMagicLinkToken() string {
+	value := randomToken()
+	return value + "." + signMagicLink(value)
+}
+
+// VerifyMagicLinkToken checks the token was signed by us, before anything
+// is looked up for it
+func VerifyMagicLinkToken(token string) bool {
+	value, signature, found := strings.Cut(token, ".")
+	return found && hmac.Equal([]byte(signature), []byte(signMagicLink(value)))
+}
+
+// SetMagicLinkCookie gives the browser a new nonce and returns it
+func SetMagicLinkCookie(c *fiber.Ctx, expireMinutes int64) string {
+	nonce := randomToken()
+	c.Cookie(&fiber.Cookie{
+		Name:     string(MagicLink),
+		Value:    nonce,
+		Expires:  time.Now().Add(time.Duration(expireMinutes) * time.Minute),
+		HTTPOnly: true,
+		Secure:   c.Protocol() == "https" || c.Get("X-Forwarded-Proto") == "https",
+		SameSite: "Lax", // Has to be sent when the link is opened from an email
+	})
+	return nonce
+}
+
+func RemoveMagicLinkCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     string(MagicLink),
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+}
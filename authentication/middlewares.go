@@ -1,9 +1,11 @@
 package authentication
 
 import (
+	"os"
 	"strings"
 	"time"
 
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
 	"github.com/DanSmirnov48/techno-trades-go-backend/models"
 	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
 	"github.com/gofiber/fiber/v2"
@@ -15,16 +17,16 @@ type Middleware struct {
 	DB *gorm.DB
 }
 
-func GetUser(token string, db *gorm.DB) (*models.User, *string) {
+func GetUser(token string, db *gorm.DB) (*models.User, *models.Session, *string) {
 	if !strings.HasPrefix(token, "Bearer ") {
 		err := "Auth Bearer Not Provided"
-		return nil, &err
+		return nil, nil, &err
 	}
-	user, err := DecodeAccessToken(token[7:], db)
+	user, session, err := DecodeAccessToken(token[7:], db)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return user, nil
+	return user, session, nil
 }
 
 func (mid Middleware) AuthMiddleware(c *fiber.Ctx) error {
@@ -34,24 +36,53 @@ func (mid Middleware) AuthMiddleware(c *fiber.Ctx) error {
 	if len(token) < 1 {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_UNAUTHORIZED_USER, "Unauthorized User!"))
 	}
-	user, err := GetUser(token, db)
+	user, session, err := GetUser(token, db)
 	if err != nil {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, *err))
 	}
+	sessionManager.Seen(db, session, c.IP())
 	c.Locals("user", user)
+	c.Locals("session", session)
 	return c.Next()
 }
 
+// OptionalAuth attaches the user when a valid token is sent and lets
+// anonymous requests through, for routes guests can use too
+func (mid Middleware) OptionalAuth(c *fiber.Ctx) error {
+	token := c.Get("Authorization")
+	if len(token) > 0 {
+		user, session, err := GetUser(token, mid.DB)
+		if err != nil {
+			return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, *err))
+		}
+		sessionManager.Seen(mid.DB, session, c.IP())
+		c.Locals("user", user)
+		c.Locals("session", session)
+	}
+	return c.Next()
+}
+
+// rateLimiter is built once so every request counts against the same store.
+// Each route is limited on its own, per client IP.
+var rateLimiter = limiter.New(limiter.Config{
+	// Limit the maximum number of requests per period
+	Max:        5,               // 5 requests
+	Expiration: 1 * time.Minute, // Per 1 minute
+	KeyGenerator: func(c *fiber.Ctx) string {
+		return c.IP() + " " + c.Method() + " " + c.Route().Path
+	},
+	// Tests log in far more often than any person would
+	Next: func(c *fiber.Ctx) bool {
+		return os.Getenv("ENVIRONMENT") == "TESTING"
+	},
+	// Customize the response when limit is reached
+	LimitReached: func(c *fiber.Ctx) error {
+		return c.Status(429).JSON(utils.RequestErr(utils.ERR_REQUEST_LIMIT, "Too many requests"))
+	},
+})
+
 func (mid Middleware) RateLimiter(c *fiber.Ctx) error {
-	return limiter.New(limiter.Config{
-		// Limit the maximum number of requests per period
-		Max:        5,               // 5 requests
-		Expiration: 1 * time.Minute, // Per 1 minute
-		// Customize the response when limit is reached
-		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(429).JSON(utils.RequestErr(utils.ERR_REQUEST_LIMIT, "Too many requests"))
-		},
-	})(c)
+	return rateLimiter(c)
 }
 
 func (mid Middleware) Admin(c *fiber.Ctx) error {
@@ -59,5 +90,9 @@ func (mid Middleware) Admin(c *fiber.Ctx) error {
 	if !ok || user == nil || user.AccountType == models.AccountTypeBuyer {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_UNAUTHORIZED_USER, "Unauthorized Access"))
 	}
+	// Staff can sign in to set two-factor up, but can't use staff routes until they have
+	if user.RequiresTotp(config.GetConfig().StaffRequireTotp) && !user.TotpEnabled {
+		return c.Status(403).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Two-factor authentication is required for staff accounts"))
+	}
 	return c.Next()
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+)
+
+// Recomputes every product's stock from the stock ledger and reports any
+// product whose stored stock or held quantity has drifted from it.
+// Exits with status 1 when drift is found.
+func main() {
+	baseline := flag.Bool("baseline", false, "open the ledger of products without movements with their current stock")
+	flag.Parse()
+
+	cfg := config.GetConfig()
+	db := database.ConnectDb(cfg)
+	sqlDb, _ := db.DB()
+	defer sqlDb.Close()
+
+	productManager := managers.ProductManager{}
+	drifts, err := productManager.ReconcileStock(db)
+	if err != nil {
+		log.Fatal("Failed to reconcile stock: ", err)
+	}
+
+	drifted := 0
+	for _, drift := range drifts {
+		if *baseline && drift.Movements == 0 {
+			if err := productManager.BaselineStock(db, drift); err != nil {
+				log.Fatalf("Failed to baseline %s: %v", drift.Name, err)
+			}
+			log.Printf("%s: baselined at %d", drift.Name, drift.CountInStock)
+			continue
+		}
+		if drift.HasDrift() {
+			drifted++
+			log.Printf("%s (%s): stock %d, ledger %d; reserved %d, ledger held %d",
+				drift.Name, drift.ProductId, drift.CountInStock, drift.LedgerStock, drift.Reserved, drift.LedgerReserved)
+		}
+	}
+
+	log.Printf("Checked %d products, %d drifted", len(drifts), drifted)
+	if drifted > 0 {
+		sqlDb.Close()
+		os.Exit(1)
+	}
+}
@@ -1,13 +1,34 @@
 package config
 
 import (
+	"fmt"
+
 	"github.com/spf13/viper"
 )
 
+// Reservations back Stripe checkout sessions, which must expire between
+// 30 minutes and 24 hours after they are created
+const (
+	MinReservationExpireMins = 31
+	MaxReservationExpireMins = 24 * 60
+)
+
 type Config struct {
 	EmailOtpExpireMins        int64  `mapstructure:"EMAIL_OTP_EXPIRE_MINS"`
+	MagicLinkExpireMins       int64  `mapstructure:"MAGIC_LINK_EXPIRE_MINS"`
+	ReservationExpireMins     int64  `mapstructure:"RESERVATION_EXPIRE_MINS"`
+	IdempotencyKeyExpireMins  int64  `mapstructure:"IDEMPOTENCY_KEY_EXPIRE_MINS"`
+	AbandonedCartIdleHours    int64  `mapstructure:"ABANDONED_CART_IDLE_HOURS"`
+	AbandonedCartMaxReminders int64  `mapstructure:"ABANDONED_CART_MAX_REMINDERS"`
+	TaxMode                   string `mapstructure:"TAX_MODE"`
+	InvoiceSellerName         string `mapstructure:"INVOICE_SELLER_NAME"`
+	InvoiceSellerAddress      string `mapstructure:"INVOICE_SELLER_ADDRESS"`
+	InvoiceSellerVATNumber    string `mapstructure:"INVOICE_SELLER_VAT_NUMBER"`
 	AccessTokenExpireMinutes  int    `mapstructure:"ACCESS_TOKEN_EXPIRE_MINUTES"`
 	RefreshTokenExpireMinutes int    `mapstructure:"REFRESH_TOKEN_EXPIRE_MINUTES"`
+	StaffRequireTotp          bool   `mapstructure:"STAFF_REQUIRE_2FA"`
+	WebAuthnRPID              string `mapstructure:"WEBAUTHN_RP_ID"`
+	WebAuthnRPOrigins         string `mapstructure:"WEBAUTHN_RP_ORIGINS"`
 	Port                      string `mapstructure:"PORT"`
 	SecretKey                 string `mapstructure:"SECRET_KEY"`
 	PostgresUser              string `mapstructure:"POSTGRES_USER"`
@@ -22,8 +43,10 @@ type Config struct {
 	MailSenderPort            int    `mapstructure:"MAIL_SENDER_PORT"`
 	CORSAllowedOrigins        string `mapstructure:"CORS_ALLOWED_ORIGINS"`
 	FrontendURL               string `mapstructure:"CLIENT_URL"`
+	ServerURL                 string `mapstructure:"SERVER_URL"`
 	StripeTestKey             string `mapstructure:"STRIPE_TEST_KEY"`
 	StripeSecretKey           string `mapstructure:"STRIPE_SECRET_KEY"`
+	StripeWebhookSecret       string `mapstructure:"STRIPE_WEBHOOK_SECRET"`
 	GoogleClientId            string `mapstructure:"GOOGLE_CLIENT_ID"`
 	GoogleClientSecret        string `mapstructure:"GOOGLE_CLIENT_SECRET"`
 }
@@ -41,3 +64,11 @@ func GetConfig(testOpts ...bool) (config Config) {
 	viper.Unmarshal(&config)
 	return
 }
+
+// Validate rejects settings the app can't run with
+func (config Config) Validate() error {
+	if config.ReservationExpireMins < MinReservationExpireMins || config.ReservationExpireMins > MaxReservationExpireMins {
+		return fmt.Errorf("RESERVATION_EXPIRE_MINS must be between %d and %d, got %d", MinReservationExpireMins, MaxReservationExpireMins, config.ReservationExpireMins)
+	}
+	return nil
+}
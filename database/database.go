@@ -18,11 +18,39 @@ func Models() []interface{} {
 		&models.Product{},
 		&models.Image{},
 		&models.Otp{},
+		&models.Session{},
+		&models.RecoveryCode{},
+		&models.Passkey{},
+		&models.PasskeyCeremony{},
+		&models.MagicLink{},
 		&models.Review{},
+		&models.Order{},
+		&models.OrderItem{},
+		&models.OrderNote{},
+		&models.StripeEvent{},
+		&models.IdempotencyRecord{},
+		&models.StockReservation{},
+		&models.StockMovement{},
+		&models.Promotion{},
+		&models.Coupon{},
+		&models.CouponRedemption{},
+		&models.Cart{},
+		&models.CartItem{},
+		&models.CartReminder{},
+		&models.ShippingMethod{},
+		&models.Address{},
+		&models.TaxCategoryRate{},
+		&models.ReturnRequest{},
+		&models.ReturnItem{},
+		&models.ReturnStatusChange{},
+		&models.DocumentSequence{},
+		&models.Invoice{},
 	}
 }
 
 func MakeMigrations(db *gorm.DB) {
+	migrateMoneyColumns(db)
+
 	models := Models()
 	for _, model := range models {
 		db.AutoMigrate(model)
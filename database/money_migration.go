@@ -0,0 +1,75 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// Legacy float price columns and the integer minor-unit columns that replace them
+var moneyColumns = []struct {
+	table  string
+	legacy string
+	column string
+}{
+	{"products", "price", "price_amount"},
+	{"products", "discounted_price", "discounted_price_amount"},
+	{"orders", "total_amount", "total_amount"},
+	{"orders", "refunded_amount", "refunded_amount"},
+	{"order_items", "unit_price", "unit_price_amount"},
+}
+
+func columnType(db *gorm.DB, table string, column string) string {
+	var dataType string
+	db.Raw(
+		"SELECT data_type FROM information_schema.columns WHERE table_schema = CURRENT_SCHEMA() AND table_name = ? AND column_name = ?",
+		table, column,
+	).Scan(&dataType)
+	return dataType
+}
+
+// migrateMoneyColumns converts float prices into integer pence before
+// AutoMigrate creates the new columns. Amounts are rounded to the nearest
+// penny so 19.99 stored as 19.989999... becomes 1999. Columns that were
+// already converted are left alone, so it's safe to run on every start.
+func migrateMoneyColumns(db *gorm.DB) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, col := range moneyColumns {
+			dataType := columnType(tx, col.table, col.legacy)
+			if dataType != "double precision" && dataType != "real" && dataType != "numeric" {
+				continue
+			}
+			log.Printf("Converting %s.%s to minor units", col.table, col.legacy)
+
+			var statements []string
+			if col.legacy == col.column {
+				statements = []string{
+					fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE bigint USING ROUND(%s * 100)::bigint", col.table, col.column, col.legacy),
+				}
+			} else {
+				statements = []string{
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s bigint NOT NULL DEFAULT 0", col.table, col.column),
+					fmt.Sprintf("UPDATE %s SET %s = ROUND(COALESCE(%s, 0) * 100)::bigint", col.table, col.column, col.legacy),
+					fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", col.table, col.legacy),
+				}
+			}
+			for _, statement := range statements {
+				if err := tx.Exec(statement).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		// Orders kept their currency in a column of its own
+		if columnType(tx, "orders", "currency") != "" && columnType(tx, "orders", "total_currency") == "" {
+			if err := tx.Exec("ALTER TABLE orders RENAME COLUMN currency TO total_currency").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal("Failed to migrate money columns: ", err.Error())
+	}
+}
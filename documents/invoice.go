@@ -0,0 +1,199 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+)
+
+// Seller is who the invoice is from
+type Seller struct {
+	Name      string
+	Address   string
+	VATNumber string
+}
+
+// InvoiceData is everything printed on an invoice or credit note.
+// OriginalNumber is the invoice a credit note is raised against.
+type InvoiceData struct {
+	Invoice        *models.Invoice
+	Order          *models.Order
+	Buyer          *models.User
+	Seller         Seller
+	OriginalNumber string
+}
+
+func percent(rate int64) string {
+	if rate%100 == 0 {
+		return fmt.Sprintf("%d%%", rate/100)
+	}
+	return fmt.Sprintf("%d.%02d%%", rate/100, rate%100)
+}
+
+// RenderInvoice draws the invoice or credit note as an A4 PDF
+func RenderInvoice(data InvoiceData) ([]byte, error) {
+	invoice, order := data.Invoice, data.Order
+	creditNote := invoice.Type == models.InvoiceTypeCreditNote
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(invoice.Number, true)
+	pdf.SetMargins(15, 15, 15)
+	pdf.AddPage()
+	// The core fonts are cp1252, which has the pound sign
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	title := "VAT INVOICE"
+	if creditNote {
+		title = "CREDIT NOTE"
+	}
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(100, 10, title, "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(80, 5, tr(data.Seller.Name), "", 2, "R", false, 0, "")
+	for _, line := range strings.Split(data.Seller.Address, ",") {
+		pdf.CellFormat(80, 5, tr(strings.TrimSpace(line)), "", 2, "R", false, 0, "")
+	}
+	if data.Seller.VATNumber != "" {
+		pdf.CellFormat(80, 5, "VAT No: "+data.Seller.VATNumber, "", 2, "R", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	// ### Document details and the buyer
+	y := pdf.GetY()
+	details := [][2]string{
+		{"Number", invoice.Number},
+		{"Date", invoice.IssuedAt.Format("02 January 2006")},
+		{"Order", order.OrderNumber},
+	}
+	if creditNote && data.OriginalNumber != "" {
+		details = append(details, [2]string{"Against invoice", data.OriginalNumber})
+	}
+	for _, detail := range details {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(30, 5, detail[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(60, 5, detail[1], "", 1, "L", false, 0, "")
+	}
+
+	pdf.SetXY(110, y)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(85, 5, "Bill to", "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	address := order.ShippingAddress
+	buyerLines := []string{address.FullName}
+	if address.FullName == "" {
+		buyerLines = []string{fmt.Sprintf("%s %s", data.Buyer.FirstName, data.Buyer.LastName)}
+	}
+	buyerLines = append(buyerLines, order.BuyerCompany, address.Line1, address.Line2, address.City, address.Region, address.PostalCode, address.Country, data.Buyer.Email)
+	if order.BuyerVATNumber != "" {
+		buyerLines = append(buyerLines, "VAT No: "+order.BuyerVATNumber)
+	}
+	for _, line := range buyerLines {
+		if line != "" {
+			pdf.CellFormat(85, 5, tr(line), "", 2, "L", false, 0, "")
+		}
+	}
+	pdf.SetX(15)
+	pdf.Ln(8)
+
+	// ### Lines
+	widths := []float64{80, 15, 25, 15, 20, 25}
+	headers := []string{"Item", "Qty", "Unit price", "VAT", "VAT amount", "Total"}
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for i, header := range headers {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 7, header, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	taxByRate := map[int64]int64{}
+	for _, item := range order.Items {
+		taxByRate[item.TaxRate] += item.Tax.Amount
+		cells := []string{
+			tr(item.Name),
+			fmt.Sprintf("%d", item.Quantity),
+			item.UnitPrice.Decimal(),
+			percent(item.TaxRate),
+			item.Tax.Decimal(),
+			item.UnitPrice.Multiply(item.Quantity).Decimal(),
+		}
+		for i, cell := range cells {
+			align := "R"
+			if i == 0 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 6, cell, "", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	// ### Totals
+	totalRow := func(label string, value string, bold bool) {
+		style := ""
+		if bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(140, 6, label, "", 0, "R", false, 0, "")
+		pdf.CellFormat(40, 6, value, "", 1, "R", false, 0, "")
+	}
+	currency := strings.ToUpper(invoice.Amount.Currency)
+	if creditNote {
+		totalRow("Original order total", order.Total.String(), false)
+		totalRow("VAT credited", invoice.Tax.String(), false)
+		totalRow("Total credited", invoice.Amount.String(), true)
+	} else {
+		totalRow("Subtotal", order.Subtotal.String(), false)
+		if order.Discount.Amount > 0 {
+			label := "Discount"
+			if order.CouponCode != "" {
+				label = fmt.Sprintf("Discount (%s)", order.CouponCode)
+			}
+			totalRow(label, "-"+order.Discount.String(), false)
+		}
+		if order.ShippingMethodName != "" {
+			totalRow(tr(fmt.Sprintf("Delivery (%s)", order.ShippingMethodName)), order.Shipping.String(), false)
+		}
+
+		// One VAT line per rate, delivery counted with the highest
+		rates := []int64{}
+		for rate := range taxByRate {
+			rates = append(rates, rate)
+		}
+		sort.Slice(rates, func(i, j int) bool { return rates[i] > rates[j] })
+		if len(rates) > 0 {
+			taxByRate[rates[0]] += order.ShippingTax.Amount
+		}
+		for _, rate := range rates {
+			totalRow(fmt.Sprintf("VAT at %s", percent(rate)), models.NewMoney(taxByRate[rate], currency).String(), false)
+		}
+		if order.TaxMode == models.TaxModeInclusive {
+			totalRow("Total (VAT included)", order.Total.String(), true)
+		} else {
+			totalRow("Total", order.Total.String(), true)
+		}
+	}
+
+	if order.ShippingAddress.Country != "" && !models.IsUKCountry(order.ShippingAddress.Country) {
+		pdf.Ln(4)
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.MultiCell(180, 5, "Zero rated export of goods outside the UK.", "", "L", false)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/senders"
+	"gorm.io/gorm"
+)
+
+// RemindAbandonedCarts emails customers about carts they left idle and
+// returns how many reminders went out
+func RemindAbandonedCarts(db *gorm.DB) int {
+	cfg := config.GetConfig()
+	idleHours := cfg.AbandonedCartIdleHours
+	if idleHours <= 0 {
+		idleHours = 24
+	}
+	maxReminders := cfg.AbandonedCartMaxReminders
+	if maxReminders <= 0 {
+		maxReminders = 2
+	}
+
+	cartManager := managers.CartManager{}
+	link := fmt.Sprintf("%s/cart?utm_source=email&utm_medium=reminder&utm_campaign=abandoned_cart", cfg.FrontendURL)
+	sent := 0
+	for _, cart := range cartManager.AbandonedCarts(db, time.Duration(idleHours)*time.Hour, int(maxReminders)) {
+		// Recorded first so a slow mail server can't get the same cart nudged twice
+		if _, errCode, errData := cartManager.RecordReminder(db, cart); errCode != nil {
+			log.Printf("Cart %s: reminder not sent: %s", cart.ID, errData.Message)
+			continue
+		}
+		senders.SendCartReminder(&cart.User, cart, link)
+		sent++
+	}
+	return sent
+}
+
+// StartAbandonedCartReminders periodically reminds customers about carts they left behind
+func StartAbandonedCartReminders(db *gorm.DB, interval time.Duration) {
+	every("abandoned-carts", interval, func() {
+		if sent := RemindAbandonedCarts(db); sent > 0 {
+			log.Printf("Sent %d abandoned cart reminders", sent)
+		}
+	})
+}
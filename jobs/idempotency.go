@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"gorm.io/gorm"
+)
+
+// StartIdempotencyCleanup deletes idempotency keys once their replay window has passed
+func StartIdempotencyCleanup(db *gorm.DB, interval time.Duration) {
+	idempotencyManager := managers.IdempotencyManager{}
+	every("idempotency-cleanup", interval, func() {
+		if purged := idempotencyManager.PurgeExpired(db); purged > 0 {
+			log.Printf("Purged %d expired idempotency keys", purged)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"gorm.io/gorm"
+)
+
+// StartMagicLinkCleanup deletes sign-in links once they are used or expired
+func StartMagicLinkCleanup(db *gorm.DB, interval time.Duration) {
+	magicLinkManager := managers.MagicLinkManager{}
+	every("magic-link-cleanup", interval, func() {
+		if purged := magicLinkManager.PurgeExpired(db); purged > 0 {
+			log.Printf("Purged %d used or expired magic links", purged)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"gorm.io/gorm"
+)
+
+// StartPasskeyCeremonyCleanup deletes passkey challenges that were never answered
+func StartPasskeyCeremonyCleanup(db *gorm.DB, interval time.Duration) {
+	passkeyManager := managers.PasskeyManager{}
+	every("passkey-ceremony-cleanup", interval, func() {
+		if purged := passkeyManager.PurgeExpiredCeremonies(db); purged > 0 {
+			log.Printf("Purged %d expired passkey challenges", purged)
+		}
+	})
+}
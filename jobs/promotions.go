@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"gorm.io/gorm"
+)
+
+// StartPromotionScheduler opens and closes promotion windows as they come due
+func StartPromotionScheduler(db *gorm.DB, interval time.Duration) {
+	promotionManager := managers.PromotionManager{}
+	every("promotions", interval, func() {
+		activated, expired := promotionManager.SyncStatuses(db)
+		if activated > 0 || expired > 0 {
+			log.Printf("Promotions: %d activated, %d expired", activated, expired)
+		}
+	})
+}
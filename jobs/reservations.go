@@ -0,0 +1,20 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
+	"gorm.io/gorm"
+)
+
+// StartReservationExpiry cancels pending orders whose stock reservations have lapsed
+func StartReservationExpiry(db *gorm.DB, provider payments.PaymentProvider, interval time.Duration) {
+	orderManager := managers.OrderManager{}
+	every("reservation-expiry", interval, func() {
+		if cancelled := orderManager.ExpireReservations(db, provider); cancelled > 0 {
+			log.Printf("Released stock for %d expired orders", cancelled)
+		}
+	})
+}
@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"log"
+	"time"
+)
+
+// every runs task on a fixed interval in the background for the life of the process
+func every(name string, interval time.Duration, task func()) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			func() {
+				// A panicking task must not take the scheduler down with it
+				defer func() {
+					if r := recover(); r != nil {
+						log.Printf("Job %s panicked: %v", name, r)
+					}
+				}()
+				task()
+			}()
+		}
+	}()
+}
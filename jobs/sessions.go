@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"gorm.io/gorm"
+)
+
+// StartSessionCleanup deletes sessions whose refresh token has run out
+func StartSessionCleanup(db *gorm.DB, interval time.Duration) {
+	sessionManager := managers.SessionManager{}
+	every("session-cleanup", interval, func() {
+		if purged := sessionManager.PurgeExpired(db); purged > 0 {
+			log.Printf("Purged %d expired sessions", purged)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -11,6 +12,8 @@ import (
 	"github.com/DanSmirnov48/techno-trades-go-backend/config"
 	"github.com/DanSmirnov48/techno-trades-go-backend/database"
 	_ "github.com/DanSmirnov48/techno-trades-go-backend/docs"
+	"github.com/DanSmirnov48/techno-trades-go-backend/jobs"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
 	"github.com/DanSmirnov48/techno-trades-go-backend/routes"
 )
 
@@ -21,6 +24,9 @@ import (
 // @BasePath /api/v1
 func main() {
 	cfg := config.GetConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
 	db := database.ConnectDb(cfg)
 	sqlDb, _ := db.DB()
 
@@ -31,7 +37,7 @@ func main() {
 	// CORS config
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORSAllowedOrigins,
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Access-Control-Allow-Origin, Content-Disposition",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, Access-Control-Allow-Origin, Content-Disposition, Idempotency-Key",
 		AllowCredentials: true,
 		AllowMethods:     "GET, POST, PUT, PATCH, DELETE, OPTIONS",
 	}))
@@ -47,7 +53,17 @@ func main() {
 	})
 
 	// Set up routes
-	routes.SetupRoutes(app, db)
+	provider := payments.NewStripeProvider(cfg.StripeSecretKey, cfg.StripeWebhookSecret)
+	routes.SetupRoutes(app, db, provider)
+
+	// Background jobs
+	jobs.StartReservationExpiry(db, provider, time.Minute)
+	jobs.StartPromotionScheduler(db, time.Minute)
+	jobs.StartIdempotencyCleanup(db, time.Hour)
+	jobs.StartSessionCleanup(db, time.Hour)
+	jobs.StartPasskeyCeremonyCleanup(db, time.Hour)
+	jobs.StartMagicLinkCleanup(db, time.Hour)
+	jobs.StartAbandonedCartReminders(db, 15*time.Minute)
 	defer sqlDb.Close()
 	log.Fatal(app.Listen(":8000"))
 }
@@ -0,0 +1,119 @@
+package managers
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// ADDRESS MANAGEMENT
+// --------------------------------
+type AddressManager struct{}
+
+func postalAddress(data schemas.AddressSchema) models.PostalAddress {
+	return models.PostalAddress{
+		FullName:   data.FullName,
+		Line1:      data.Line1,
+		Line2:      data.Line2,
+		City:       data.City,
+		Region:     data.Region,
+		PostalCode: strings.ToUpper(data.PostalCode),
+		Country:    strings.ToUpper(data.Country),
+		Phone:      data.Phone,
+	}
+}
+
+// save writes the address, keeping a single default per user. A user's
+// first address is always their default.
+func (obj AddressManager) save(db *gorm.DB, address *models.Address) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Model(&models.Address{}).Where("user_id = ? AND id <> ?", address.UserId, address.ID).Count(&count)
+		if count == 0 {
+			address.IsDefault = true
+		}
+		if address.IsDefault {
+			err := tx.Model(&models.Address{}).
+				Where("user_id = ? AND id <> ?", address.UserId, address.ID).
+				Update("is_default", false).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Save(address).Error
+	})
+}
+
+func (obj AddressManager) Create(db *gorm.DB, userId uuid.UUID, data schemas.AddressSchema) (*models.Address, *int, *utils.ErrorResponse) {
+	address := models.Address{
+		ID:            uuid.New(),
+		UserId:        userId,
+		PostalAddress: postalAddress(data),
+		IsDefault:     data.IsDefault,
+	}
+	if err := obj.save(db, &address); err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to save address")
+		return nil, &statusCode, &errData
+	}
+	return &address, nil, nil
+}
+
+func (obj AddressManager) GetAll(db *gorm.DB, userId uuid.UUID) []*models.Address {
+	addresses := []*models.Address{}
+	db.Where("user_id = ?", userId).Order("is_default DESC, created_at").Find(&addresses)
+	return addresses
+}
+
+// GetForUser finds one of the user's own addresses
+func (obj AddressManager) GetForUser(db *gorm.DB, userId uuid.UUID, id uuid.UUID) (*models.Address, *int, *utils.ErrorResponse) {
+	address := models.Address{}
+	db.Take(&address, "id = ? AND user_id = ?", id, userId)
+	if address.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Address does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &address, nil, nil
+}
+
+func (obj AddressManager) Update(db *gorm.DB, address *models.Address, data schemas.AddressSchema) (*models.Address, *int, *utils.ErrorResponse) {
+	address.PostalAddress = postalAddress(data)
+	address.IsDefault = data.IsDefault || address.IsDefault
+	if err := obj.save(db, address); err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to save address")
+		return nil, &statusCode, &errData
+	}
+	return address, nil, nil
+}
+
+func (obj AddressManager) Delete(db *gorm.DB, address *models.Address) (*int, *utils.ErrorResponse) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(address).Error; err != nil {
+			return err
+		}
+		if !address.IsDefault {
+			return nil
+		}
+		// Hand the default on to the oldest remaining address
+		next := models.Address{}
+		tx.Where("user_id = ?", address.UserId).Order("created_at").Take(&next)
+		if next.ID == uuid.Nil {
+			return nil
+		}
+		return tx.Model(&next).Update("is_default", true).Error
+	})
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to delete address")
+		return &statusCode, &errData
+	}
+	return nil, nil
+}
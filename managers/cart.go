@@ -0,0 +1,379 @@
+package managers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// CART MANAGEMENT
+// --------------------------------
+type CartManager struct{}
+
+func (obj CartManager) GetOrCreate(db *gorm.DB, userId uuid.UUID) (*models.Cart, *int, *utils.ErrorResponse) {
+	cart := models.Cart{UserId: &userId}
+	err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&cart).Error
+	if err == nil {
+		err = db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at")
+		}).Take(&cart, "user_id = ?", userId).Error
+	}
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to load cart")
+		return nil, &statusCode, &errData
+	}
+	return &cart, nil, nil
+}
+
+func (obj CartManager) CreateGuest(db *gorm.DB) (*models.Cart, *int, *utils.ErrorResponse) {
+	cart := models.Cart{Items: []models.CartItem{}}
+	if err := db.Create(&cart).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create cart")
+		return nil, &statusCode, &errData
+	}
+	return &cart, nil, nil
+}
+
+// GetGuest finds a cart that hasn't been claimed by a user yet
+func (obj CartManager) GetGuest(db *gorm.DB, cartId uuid.UUID) *models.Cart {
+	cart := models.Cart{}
+	db.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	}).Where("user_id IS NULL").Take(&cart, "id = ?", cartId)
+	if cart.ID == uuid.Nil {
+		return nil
+	}
+	return &cart
+}
+
+// MergeGuest moves a guest cart into the user's cart and deletes it.
+// Quantities of products in both carts are added together, then every line
+// is capped at what's available. Lines that had to change are reported.
+func (obj CartManager) MergeGuest(db *gorm.DB, guestCartId uuid.UUID, userId uuid.UUID) []string {
+	warnings := []string{}
+	guest := obj.GetGuest(db, guestCartId)
+	if guest == nil {
+		return warnings
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		cart, _, errData := obj.GetOrCreate(tx, userId)
+		if errData != nil {
+			return errData
+		}
+
+		productManager := ProductManager{}
+		for _, guestItem := range guest.Items {
+			product, _, _ := productManager.GetById(tx, guestItem.ProductId)
+			if product == nil {
+				continue
+			}
+			available := product.CountInStock - productManager.ReservedStock(tx, product.ID)
+
+			quantity := guestItem.Quantity
+			item := obj.findItem(cart, product.ID)
+			if item != nil {
+				quantity += item.Quantity
+			}
+			if quantity > available {
+				quantity = available
+				warnings = append(warnings, fmt.Sprintf("Only %d of %s left, quantity updated", available, product.Name))
+			}
+
+			switch {
+			case item != nil && quantity > 0:
+				tx.Model(item).Update("quantity", quantity)
+			case item != nil:
+				tx.Delete(item)
+			case quantity > 0:
+				tx.Create(&models.CartItem{CartId: cart.ID, ProductId: product.ID, Quantity: quantity})
+			}
+		}
+		obj.touch(tx, cart)
+		return tx.Delete(guest).Error
+	})
+	if err != nil {
+		log.Printf("Failed to merge guest cart %s: %v", guestCartId, err)
+	}
+	return warnings
+}
+
+// touch records that the customer used the cart just now
+func (obj CartManager) touch(db *gorm.DB, cart *models.Cart) {
+	cart.UpdatedAt = time.Now()
+	db.Model(&models.Cart{}).Where("id = ?", cart.ID).Update("updated_at", cart.UpdatedAt)
+}
+
+func (obj CartManager) findItem(cart *models.Cart, productId uuid.UUID) *models.CartItem {
+	for i := range cart.Items {
+		if cart.Items[i].ProductId == productId {
+			return &cart.Items[i]
+		}
+	}
+	return nil
+}
+
+// checkAvailable makes sure the quantity can be bought right now, counting
+// stock held by other checkouts as gone
+func (obj CartManager) checkAvailable(db *gorm.DB, productId uuid.UUID, quantity int) (*int, *utils.ErrorResponse) {
+	productManager := ProductManager{}
+	product, errCode, errData := productManager.GetById(db, productId)
+	if errCode != nil {
+		return errCode, errData
+	}
+	available := product.CountInStock - productManager.ReservedStock(db, productId)
+	if quantity > available {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, fmt.Sprintf("Insufficient stock for product: %s. Available: %d, Requested: %d", product.Name, available, quantity))
+		return &statusCode, &errData
+	}
+	return nil, nil
+}
+
+func (obj CartManager) AddItem(db *gorm.DB, cart *models.Cart, productId uuid.UUID, quantity int) (*models.Cart, *int, *utils.ErrorResponse) {
+	item := obj.findItem(cart, productId)
+	if item != nil {
+		return obj.SetQuantity(db, cart, productId, item.Quantity+quantity)
+	}
+
+	if errCode, errData := obj.checkAvailable(db, productId, quantity); errCode != nil {
+		return nil, errCode, errData
+	}
+
+	newItem := models.CartItem{CartId: cart.ID, ProductId: productId, Quantity: quantity}
+	if err := db.Create(&newItem).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to add item to cart")
+		return nil, &statusCode, &errData
+	}
+	cart.Items = append(cart.Items, newItem)
+	obj.touch(db, cart)
+	return cart, nil, nil
+}
+
+func (obj CartManager) SetQuantity(db *gorm.DB, cart *models.Cart, productId uuid.UUID, quantity int) (*models.Cart, *int, *utils.ErrorResponse) {
+	item := obj.findItem(cart, productId)
+	if item == nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Product is not in the cart")
+		return nil, &statusCode, &errData
+	}
+
+	if errCode, errData := obj.checkAvailable(db, productId, quantity); errCode != nil {
+		return nil, errCode, errData
+	}
+
+	item.Quantity = quantity
+	if err := db.Model(item).Update("quantity", quantity).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update cart")
+		return nil, &statusCode, &errData
+	}
+	obj.touch(db, cart)
+	return cart, nil, nil
+}
+
+func (obj CartManager) RemoveItem(db *gorm.DB, cart *models.Cart, productId uuid.UUID) (*models.Cart, *int, *utils.ErrorResponse) {
+	item := obj.findItem(cart, productId)
+	if item == nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Product is not in the cart")
+		return nil, &statusCode, &errData
+	}
+
+	db.Delete(item)
+	items := []models.CartItem{}
+	for _, cartItem := range cart.Items {
+		if cartItem.ProductId != productId {
+			items = append(items, cartItem)
+		}
+	}
+	cart.Items = items
+	obj.touch(db, cart)
+	return cart, nil, nil
+}
+
+func (obj CartManager) Clear(db *gorm.DB, cart *models.Cart) *models.Cart {
+	db.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{})
+	cart.Items = []models.CartItem{}
+	obj.touch(db, cart)
+	return cart
+}
+
+// RemoveOrdered takes the products of a paid order out of the buyer's cart
+func (obj CartManager) RemoveOrdered(db *gorm.DB, order *models.Order) {
+	productIds := make([]uuid.UUID, 0, len(order.Items))
+	for _, item := range order.Items {
+		productIds = append(productIds, item.ProductId)
+	}
+	db.Where("product_id IN ? AND cart_id IN (?)", productIds,
+		db.Model(&models.Cart{}).Select("id").Where("user_id = ?", order.UserId),
+	).Delete(&models.CartItem{})
+}
+
+// Revalidate prices the cart at today's prices and fixes up anything that is
+// no longer possible: products that were removed or sold out are dropped and
+// quantities are cut down to what's available. Each change is reported as a
+// warning so the client can tell the customer.
+func (obj CartManager) Revalidate(db *gorm.DB, cart *models.Cart) schemas.CartSchema {
+	productManager := ProductManager{}
+	view := schemas.CartSchema{ID: cart.ID, Items: []schemas.CartLineSchema{}, Warnings: []string{}}
+
+	productIds := make([]uuid.UUID, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		productIds = append(productIds, item.ProductId)
+	}
+	products := []*models.Product{}
+	if len(productIds) > 0 {
+		db.Where("id IN ?", productIds).Find(&products)
+	}
+	PricingManager{}.Apply(db, products...)
+	byId := make(map[uuid.UUID]*models.Product, len(products))
+	for _, product := range products {
+		byId[product.ID] = product
+	}
+
+	items := []models.CartItem{}
+	for _, item := range cart.Items {
+		product, ok := byId[item.ProductId]
+		if !ok {
+			db.Delete(&item)
+			view.Warnings = append(view.Warnings, "A product in your cart is no longer available and was removed")
+			continue
+		}
+
+		available := product.CountInStock - productManager.ReservedStock(db, product.ID)
+		if available <= 0 {
+			db.Delete(&item)
+			view.Warnings = append(view.Warnings, fmt.Sprintf("%s is out of stock and was removed", product.Name))
+			continue
+		}
+		if item.Quantity > available {
+			item.Quantity = available
+			db.Model(&item).Update("quantity", available)
+			view.Warnings = append(view.Warnings, fmt.Sprintf("Only %d of %s left, quantity updated", available, product.Name))
+		}
+		items = append(items, item)
+
+		view.Items = append(view.Items, schemas.CartLineSchema{
+			ProductId: product.ID,
+			Name:      product.Name,
+			Slug:      product.Slug,
+			UnitPrice: product.EffectivePrice,
+			Quantity:  item.Quantity,
+			Available: available,
+			LineTotal: product.EffectivePrice.Multiply(item.Quantity),
+		})
+	}
+	cart.Items = items
+
+	view.Subtotal = models.NewMoney(0, models.DefaultCurrency)
+	if len(view.Items) > 0 {
+		view.Subtotal = models.NewMoney(0, view.Items[0].UnitPrice.Currency)
+	}
+	for _, line := range view.Items {
+		view.Subtotal = view.Subtotal.Add(line.LineTotal)
+	}
+	return view
+}
+
+// OrderItems snapshots the cart into order items at their effective price.
+// Stock is checked again when the order reserves it.
+func (obj CartManager) OrderItems(db *gorm.DB, cart *models.Cart) ([]models.OrderItem, *int, *utils.ErrorResponse) {
+	if len(cart.Items) == 0 {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, "Cart is empty")
+		return nil, &statusCode, &errData
+	}
+
+	productManager := ProductManager{}
+	orderItems := []models.OrderItem{}
+	for _, item := range cart.Items {
+		product, errCode, errData := productManager.GetById(db, item.ProductId)
+		if errCode != nil {
+			return nil, errCode, errData
+		}
+		orderItems = append(orderItems, models.OrderItem{
+			ProductId: product.ID,
+			Name:      product.Name,
+			UnitPrice: product.EffectivePrice,
+			Quantity:  item.Quantity,
+		})
+	}
+	return orderItems, nil, nil
+}
+
+// ----------------------------------
+// ABANDONED CART RECOVERY
+// --------------------------------
+
+// How long after a reminder a paid order still counts as won back by it
+const cartRecoveryWindow = 7 * 24 * time.Hour
+
+// AbandonedCarts finds signed in customers' carts that have sat untouched for
+// idleFor and are due a reminder: the customer hasn't opted out of marketing
+// or started a checkout since, fewer than maxReminders went out since the
+// cart was last used, and the last one is at least idleFor old. Items that
+// can't be bought right now are left off each cart, and carts with nothing
+// left in stock are skipped.
+func (obj CartManager) AbandonedCarts(db *gorm.DB, idleFor time.Duration, maxReminders int) []*models.Cart {
+	cutoff := time.Now().Add(-idleFor)
+	carts := []*models.Cart{}
+	db.Preload("User").Preload("Items.Product").
+		Joins("JOIN users ON users.id = carts.user_id").
+		Where("carts.updated_at <= ?", cutoff).
+		Where("users.marketing_opt_out = ? AND users.active = ? AND users.is_email_verified = ? AND users.deleted_at IS NULL", false, true, true).
+		Where("EXISTS (SELECT 1 FROM cart_items WHERE cart_items.cart_id = carts.id)").
+		Where("NOT EXISTS (SELECT 1 FROM orders WHERE orders.user_id = carts.user_id AND orders.created_at >= carts.updated_at)").
+		Where("(SELECT COUNT(*) FROM cart_reminders WHERE cart_reminders.cart_id = carts.id AND cart_reminders.created_at >= carts.updated_at) < ?", maxReminders).
+		Where("NOT EXISTS (SELECT 1 FROM cart_reminders WHERE cart_reminders.cart_id = carts.id AND cart_reminders.created_at > ?)", cutoff).
+		Find(&carts)
+
+	productManager := ProductManager{}
+	due := []*models.Cart{}
+	for _, cart := range carts {
+		inStock := []models.CartItem{}
+		for _, item := range cart.Items {
+			available := item.Product.CountInStock - productManager.ReservedStock(db, item.ProductId)
+			if item.Product.ID != uuid.Nil && available > 0 {
+				inStock = append(inStock, item)
+			}
+		}
+		if len(inStock) == 0 {
+			continue
+		}
+		cart.Items = inStock
+		due = append(due, cart)
+	}
+	return due
+}
+
+func (obj CartManager) RecordReminder(db *gorm.DB, cart *models.Cart) (*models.CartReminder, *int, *utils.ErrorResponse) {
+	reminder := models.CartReminder{CartId: cart.ID, UserId: *cart.UserId}
+	if err := db.Create(&reminder).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to record cart reminder")
+		return nil, &statusCode, &errData
+	}
+	return &reminder, nil, nil
+}
+
+// RecordConversion credits a paid order to the customer's abandoned cart
+// reminders sent within the recovery window
+func (obj CartManager) RecordConversion(db *gorm.DB, order *models.Order) int64 {
+	now := time.Now()
+	return db.Model(&models.CartReminder{}).
+		Where("user_id = ? AND converted_at IS NULL AND created_at >= ?", order.UserId, now.Add(-cartRecoveryWindow)).
+		Updates(map[string]interface{}{"order_id": order.ID, "converted_at": now}).RowsAffected
+}
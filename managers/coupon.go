@@ -0,0 +1,216 @@
+package managers
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// COUPON MANAGEMENT
+// --------------------------------
+type CouponManager struct{}
+
+func (obj CouponManager) Create(db *gorm.DB, data schemas.CreateCoupon) (*models.Coupon, *int, *utils.ErrorResponse) {
+	code := models.NormalizeCouponCode(data.Code)
+	existing := models.Coupon{}
+	db.Take(&existing, "code = ?", code)
+	if existing.ID != uuid.Nil {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, "A coupon with this code already exists")
+		return nil, &statusCode, &errData
+	}
+	if data.Type == models.CouponPercentage && data.Value > 100 {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Percentage coupons can't exceed 100")
+		return nil, &statusCode, &errData
+	}
+
+	coupon := models.Coupon{
+		Code:           code,
+		Type:           data.Type,
+		Value:          data.Value,
+		Currency:       models.NewMoney(0, data.Currency).Currency,
+		MinBasket:      data.MinBasket,
+		MaxRedemptions: data.MaxRedemptions,
+		MaxPerUser:     data.MaxPerUser,
+		Categories:     strings.Join(data.Categories, ","),
+		ExpiresAt:      data.ExpiresAt,
+		IsActive:       true,
+	}
+	if err := db.Create(&coupon).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create coupon")
+		return nil, &statusCode, &errData
+	}
+	return &coupon, nil, nil
+}
+
+func (obj CouponManager) GetAll(db *gorm.DB) []*models.Coupon {
+	coupons := []*models.Coupon{}
+	db.Order("created_at DESC").Find(&coupons)
+	return coupons
+}
+
+func (obj CouponManager) GetById(db *gorm.DB, id uuid.UUID) (*models.Coupon, *int, *utils.ErrorResponse) {
+	coupon := models.Coupon{}
+	db.Take(&coupon, "id = ?", id)
+	if coupon.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Coupon does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &coupon, nil, nil
+}
+
+func (obj CouponManager) GetByCode(db *gorm.DB, code string) (*models.Coupon, *int, *utils.ErrorResponse) {
+	coupon := models.Coupon{}
+	db.Take(&coupon, "code = ?", models.NormalizeCouponCode(code))
+	if coupon.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Coupon does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &coupon, nil, nil
+}
+
+func (obj CouponManager) Deactivate(db *gorm.DB, coupon *models.Coupon) (*models.Coupon, *int, *utils.ErrorResponse) {
+	coupon.IsActive = false
+	if err := db.Model(coupon).Update("is_active", false).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to deactivate coupon")
+		return nil, &statusCode, &errData
+	}
+	return coupon, nil, nil
+}
+
+func couponErr(message string) (models.Money, *int, *utils.ErrorResponse) {
+	statusCode := 400
+	errData := utils.RequestErr(utils.ERR_INVALID_VALUE, message)
+	return models.Money{}, &statusCode, &errData
+}
+
+// Evaluate checks that the user may use the coupon on these items and
+// returns the discount it gives. Only items in eligible categories count
+// towards the discount, while the minimum basket applies to the whole order.
+func (obj CouponManager) Evaluate(db *gorm.DB, coupon *models.Coupon, userId uuid.UUID, items []models.OrderItem) (models.Money, *int, *utils.ErrorResponse) {
+	if !coupon.IsActive {
+		return couponErr("Coupon is no longer active")
+	}
+	if coupon.IsExpired() {
+		return couponErr("Coupon has expired")
+	}
+	if coupon.MaxRedemptions > 0 && coupon.Redemptions >= coupon.MaxRedemptions {
+		return couponErr("Coupon has reached its usage limit")
+	}
+	if coupon.MaxPerUser > 0 {
+		var used int64
+		db.Model(&models.CouponRedemption{}).
+			Where("coupon_id = ? AND user_id = ? AND status = ?", coupon.ID, userId, models.RedemptionRedeemed).
+			Count(&used)
+		if used >= int64(coupon.MaxPerUser) {
+			return couponErr("You have already used this coupon")
+		}
+	}
+
+	productIds := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		productIds = append(productIds, item.ProductId)
+	}
+	var products []models.Product
+	db.Select("id", "category").Where("id IN ?", productIds).Find(&products)
+	categories := make(map[uuid.UUID]string, len(products))
+	for _, product := range products {
+		categories[product.ID] = product.Category
+	}
+
+	subtotal := models.NewMoney(0, coupon.Currency)
+	eligible := models.NewMoney(0, coupon.Currency)
+	for _, item := range items {
+		if !item.UnitPrice.SameCurrency(subtotal) {
+			return couponErr("Coupon can't be used with this currency")
+		}
+		line := item.UnitPrice.Multiply(item.Quantity)
+		subtotal = subtotal.Add(line)
+		if coupon.AppliesToCategory(categories[item.ProductId]) {
+			eligible = eligible.Add(line)
+		}
+	}
+
+	if subtotal.Amount < coupon.MinBasket {
+		return couponErr("Basket total is below the minimum for this coupon: " + models.NewMoney(coupon.MinBasket, coupon.Currency).String())
+	}
+	if eligible.Amount == 0 {
+		return couponErr("Coupon doesn't apply to any items in your basket")
+	}
+
+	discount := models.NewMoney(coupon.Value, coupon.Currency)
+	if coupon.Type == models.CouponPercentage {
+		discount.Amount = eligible.Amount * coupon.Value / 100
+	}
+	if discount.Amount > eligible.Amount {
+		discount.Amount = eligible.Amount
+	}
+	return discount, nil, nil
+}
+
+// Lock takes the coupon row for the rest of the transaction so redemptions
+// of the same code are checked and recorded one at a time.
+func (obj CouponManager) Lock(tx *gorm.DB, code string) (*models.Coupon, *int, *utils.ErrorResponse) {
+	coupon := models.Coupon{}
+	tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&coupon, "code = ?", models.NormalizeCouponCode(code))
+	if coupon.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Coupon does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &coupon, nil, nil
+}
+
+// Redeem records the use of a locked coupon against an order.
+func (obj CouponManager) Redeem(tx *gorm.DB, coupon *models.Coupon, order *models.Order) (*int, *utils.ErrorResponse) {
+	redemption := models.CouponRedemption{
+		CouponId: coupon.ID,
+		UserId:   order.UserId,
+		OrderId:  order.ID,
+		Discount: order.Discount,
+		Status:   models.RedemptionRedeemed,
+	}
+	err := tx.Create(&redemption).Error
+	if err == nil {
+		err = tx.Model(coupon).Update("redemptions", gorm.Expr("redemptions + 1")).Error
+	}
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to redeem coupon")
+		return &statusCode, &errData
+	}
+	return nil, nil
+}
+
+// Release gives a cancelled order's coupon use back.
+func (obj CouponManager) Release(db *gorm.DB, orderId uuid.UUID) (*int, *utils.ErrorResponse) {
+	redemption := models.CouponRedemption{}
+	db.Take(&redemption, "order_id = ? AND status = ?", orderId, models.RedemptionRedeemed)
+	if redemption.ID == uuid.Nil {
+		return nil, nil
+	}
+
+	err := db.Model(&redemption).Update("status", models.RedemptionReleased).Error
+	if err == nil {
+		err = db.Model(&models.Coupon{}).Where("id = ?", redemption.CouponId).
+			Update("redemptions", gorm.Expr("GREATEST(redemptions - 1, 0)")).Error
+	}
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to release coupon")
+		return &statusCode, &errData
+	}
+	return nil, nil
+}
@@ -0,0 +1,96 @@
+package managers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// IDEMPOTENCY MANAGEMENT
+// --------------------------------
+type IdempotencyManager struct{}
+
+// IdempotencyInFlightTimeout is how long a key's first request has to finish.
+// After that it's taken to have died with the process and the key is free.
+const IdempotencyInFlightTimeout = 5 * time.Minute
+
+func idempotencyExpiry() time.Time {
+	expirationMins := config.GetConfig().IdempotencyKeyExpireMins
+	if expirationMins <= 0 {
+		expirationMins = 60 * 24
+	}
+	return time.Now().Add(time.Minute * time.Duration(expirationMins))
+}
+
+// Begin claims the key for this request. When the key has been used before
+// the stored record comes back with replay set, so its response can be sent
+// again. A key reused for a different body, or whose first request hasn't
+// finished yet, is refused.
+func (obj IdempotencyManager) Begin(db *gorm.DB, userId uuid.UUID, key string, route string, requestHash string) (*models.IdempotencyRecord, bool, *int, *utils.ErrorResponse) {
+	// A lapsed key is free to be used again, as is one whose first request
+	// was abandoned
+	db.Where("user_id = ? AND key = ? AND route = ?", userId, key, route).
+		Where("expires_at <= ? OR (status_code = 0 AND created_at <= ?)", time.Now(), time.Now().Add(-IdempotencyInFlightTimeout)).
+		Delete(&models.IdempotencyRecord{})
+
+	record := models.IdempotencyRecord{
+		UserId:      userId,
+		Key:         key,
+		Route:       route,
+		RequestHash: requestHash,
+		ExpiresAt:   idempotencyExpiry(),
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to store idempotency key")
+		return nil, false, &statusCode, &errData
+	}
+	if result.RowsAffected == 1 {
+		return &record, false, nil, nil
+	}
+
+	existing := models.IdempotencyRecord{}
+	db.Where("user_id = ? AND key = ? AND route = ?", userId, key, route).Take(&existing)
+	if existing.RequestHash != requestHash {
+		statusCode := 422
+		errData := utils.RequestErr(utils.ERR_INVALID_REQUEST, "Idempotency-Key has already been used for a different request")
+		return nil, false, &statusCode, &errData
+	}
+	if !existing.IsCompleted() {
+		statusCode := 409
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "A request with this Idempotency-Key is still being processed")
+		return nil, false, &statusCode, &errData
+	}
+	return &existing, true, nil, nil
+}
+
+// Complete stores the response so retries get the same one
+func (obj IdempotencyManager) Complete(db *gorm.DB, record *models.IdempotencyRecord, statusCode int, contentType string, body []byte) {
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = body
+	db.Model(record).Updates(map[string]interface{}{
+		"status_code":  statusCode,
+		"content_type": contentType,
+		"body":         body,
+	})
+}
+
+// Release forgets the key, for requests that failed on our side and should
+// run again when retried
+func (obj IdempotencyManager) Release(db *gorm.DB, record *models.IdempotencyRecord) {
+	db.Delete(record)
+}
+
+// PurgeExpired deletes keys whose window has passed and returns how many went
+func (obj IdempotencyManager) PurgeExpired(db *gorm.DB) int64 {
+	return db.Where("expires_at <= ?", time.Now()).Delete(&models.IdempotencyRecord{}).RowsAffected
+}
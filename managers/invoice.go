@@ -0,0 +1,200 @@
+package managers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/documents"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// INVOICE MANAGEMENT
+// --------------------------------
+type InvoiceManager struct{}
+
+// lockSeries locks a series row until the transaction ends, creating the
+// series on first use
+func (obj InvoiceManager) lockSeries(tx *gorm.DB, invoiceType models.InvoiceType) (*models.DocumentSequence, error) {
+	series := string(invoiceType)
+	tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.DocumentSequence{Series: series, Next: 1})
+
+	sequence := models.DocumentSequence{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&sequence, "series = ?", series).Error; err != nil {
+		return nil, err
+	}
+	return &sequence, nil
+}
+
+// nextNumber takes the next number in a series. It must run inside the
+// transaction that writes the document, the lock on the series row is what
+// keeps the numbering free of gaps and duplicates.
+func (obj InvoiceManager) nextNumber(tx *gorm.DB, invoiceType models.InvoiceType) (string, error) {
+	sequence, err := obj.lockSeries(tx, invoiceType)
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Model(sequence).Where("series = ?", sequence.Series).Update("next", sequence.Next+1).Error; err != nil {
+		return "", err
+	}
+	return models.FormatDocumentNumber(invoiceType, sequence.Next), nil
+}
+
+// issue numbers and records a document for the order. Its PDF is rendered
+// when it's first downloaded, so the series stays locked only for the
+// database writes.
+func (obj InvoiceManager) issue(db *gorm.DB, order *models.Order, invoiceType models.InvoiceType, amount models.Money, tax models.Money) (*models.Invoice, *int, *utils.ErrorResponse) {
+	invoice := models.Invoice{
+		Type:     invoiceType,
+		OrderId:  order.ID,
+		UserId:   order.UserId,
+		Amount:   amount,
+		Tax:      tax,
+		IssuedAt: time.Now(),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if invoiceType == models.InvoiceTypeInvoice {
+			// Checked again under the series lock, so a payment and a first
+			// download racing to raise the invoice end up with the same one
+			if _, err := obj.lockSeries(tx, invoiceType); err != nil {
+				return err
+			}
+			if existing, errCode, _ := obj.GetInvoice(tx, order.ID); errCode == nil {
+				invoice = *existing
+				return nil
+			}
+		}
+
+		number, err := obj.nextNumber(tx, invoiceType)
+		if err != nil {
+			return err
+		}
+		invoice.Number = number
+		invoice.FileKey = fmt.Sprintf("invoices/%s.pdf", number)
+		return tx.Create(&invoice).Error
+	})
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to issue "+string(invoiceType))
+		return nil, &statusCode, &errData
+	}
+	return &invoice, nil, nil
+}
+
+// store renders the document's PDF and uploads it
+func (obj InvoiceManager) store(db *gorm.DB, invoice *models.Invoice) error {
+	order := models.Order{}
+	if err := db.Preload("Items").Take(&order, "id = ?", invoice.OrderId).Error; err != nil {
+		return err
+	}
+	buyer := models.User{}
+	db.Take(&buyer, "id = ?", invoice.UserId)
+
+	// Credit notes point back at the invoice they correct
+	originalNumber := ""
+	if invoice.Type == models.InvoiceTypeCreditNote {
+		if original, errCode, _ := obj.GetInvoice(db, invoice.OrderId); errCode == nil {
+			originalNumber = original.Number
+		}
+	}
+
+	cfg := config.GetConfig()
+	content, err := documents.RenderInvoice(documents.InvoiceData{
+		Invoice: invoice,
+		Order:   &order,
+		Buyer:   &buyer,
+		Seller: documents.Seller{
+			Name:      cfg.InvoiceSellerName,
+			Address:   cfg.InvoiceSellerAddress,
+			VATNumber: cfg.InvoiceSellerVATNumber,
+		},
+		OriginalNumber: originalNumber,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := utils.UploadBytes(invoice.FileKey, content, "application/pdf"); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	invoice.StoredAt = &now
+	return db.Model(invoice).Update("stored_at", now).Error
+}
+
+// IssueInvoice raises the order's invoice once it's paid. Orders only ever
+// get one, so calling it again returns the existing invoice.
+func (obj InvoiceManager) IssueInvoice(db *gorm.DB, order *models.Order) (*models.Invoice, *int, *utils.ErrorResponse) {
+	if existing, errCode, _ := obj.GetInvoice(db, order.ID); errCode == nil {
+		return existing, nil, nil
+	}
+	return obj.issue(db, order, models.InvoiceTypeInvoice, order.Total, order.Tax)
+}
+
+// IssueCreditNote raises a credit note for money given back on the order.
+// The VAT credited is the same share of the refund as VAT was of the total.
+func (obj InvoiceManager) IssueCreditNote(db *gorm.DB, order *models.Order, amount models.Money) (*models.Invoice, *int, *utils.ErrorResponse) {
+	if _, errCode, errData := obj.IssueInvoice(db, order); errCode != nil {
+		return nil, errCode, errData
+	}
+	tax := models.NewMoney(0, amount.Currency)
+	if order.Total.Amount > 0 {
+		tax = amount.Portion(order.Tax.Amount, order.Total.Amount)
+	}
+	return obj.issue(db, order, models.InvoiceTypeCreditNote, amount, tax)
+}
+
+// GetInvoice finds the invoice raised for an order
+func (obj InvoiceManager) GetInvoice(db *gorm.DB, orderId uuid.UUID) (*models.Invoice, *int, *utils.ErrorResponse) {
+	invoice := models.Invoice{}
+	db.Take(&invoice, "order_id = ? AND type = ?", orderId, models.InvoiceTypeInvoice)
+	if invoice.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Invoice does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &invoice, nil, nil
+}
+
+// GetByNumber finds one of the order's invoices or credit notes
+func (obj InvoiceManager) GetByNumber(db *gorm.DB, orderId uuid.UUID, number string) (*models.Invoice, *int, *utils.ErrorResponse) {
+	invoice := models.Invoice{}
+	db.Take(&invoice, "order_id = ? AND number = ?", orderId, number)
+	if invoice.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Invoice does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &invoice, nil, nil
+}
+
+// GetForOrder lists the order's invoice and credit notes in the order they were issued
+func (obj InvoiceManager) GetForOrder(db *gorm.DB, orderId uuid.UUID) []*models.Invoice {
+	invoices := []*models.Invoice{}
+	db.Where("order_id = ?", orderId).Order("issued_at").Find(&invoices)
+	return invoices
+}
+
+// Download fetches the PDF, rendering and storing it on first download
+func (obj InvoiceManager) Download(db *gorm.DB, invoice *models.Invoice) ([]byte, *int, *utils.ErrorResponse) {
+	if invoice.StoredAt == nil {
+		if err := obj.store(db, invoice); err != nil {
+			statusCode := 500
+			errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to render invoice")
+			return nil, &statusCode, &errData
+		}
+	}
+	content, err := utils.GetFile(invoice.FileKey)
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to fetch invoice")
+		return nil, &statusCode, &errData
+	}
+	return content, nil, nil
+}
@@ -0,0 +1,83 @@
+package managers
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// MAGIC LINK MANAGEMENT
+// --------------------------------
+type MagicLinkManager struct{}
+
+// MagicLinkExpireMins is how long a sign-in link works for
+func MagicLinkExpireMins() int64 {
+	expirationMins := config.GetConfig().MagicLinkExpireMins
+	if expirationMins <= 0 {
+		expirationMins = 15
+	}
+	return expirationMins
+}
+
+// Create stores a new sign-in link for the user. Any they asked for before
+// stop working, so only the latest email can be used.
+func (obj MagicLinkManager) Create(db *gorm.DB, userId uuid.UUID, tokenHash string, nonceHash string) *models.MagicLink {
+	db.Where("user_id = ? AND used_at IS NULL", userId).Delete(&models.MagicLink{})
+	link := models.MagicLink{
+		UserId:    userId,
+		TokenHash: tokenHash,
+		NonceHash: nonceHash,
+		ExpiresAt: time.Now().Add(time.Minute * time.Duration(MagicLinkExpireMins())),
+	}
+	db.Create(&link)
+	return &link
+}
+
+// Redeem uses up the link and returns its user. A link opened in a browser
+// other than the one that asked for it is refused but not used up, so its
+// owner can still open it.
+func (obj MagicLinkManager) Redeem(db *gorm.DB, tokenHash string, nonceHash string) (*models.User, *int, *utils.ErrorResponse) {
+	user := models.User{}
+	var statusCode int
+	var errData utils.ErrorResponse
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		link := models.MagicLink{}
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("token_hash = ?", tokenHash).Take(&link)
+		if link.ID == uuid.Nil || link.UsedAt != nil || time.Now().After(link.ExpiresAt) {
+			statusCode = 400
+			errData = utils.RequestErr(utils.ERR_INVALID_TOKEN, "Sign-in link is invalid or has expired")
+			return &errData
+		}
+		if link.NonceHash != nonceHash {
+			statusCode = 401
+			errData = utils.RequestErr(utils.ERR_INVALID_AUTH, "Open the sign-in link in the browser you requested it from")
+			return &errData
+		}
+
+		if err := tx.Model(&link).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", link.UserId).Take(&user).Error
+	})
+	if err != nil {
+		if statusCode == 0 {
+			statusCode = 500
+			errData = utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to sign in")
+		}
+		return nil, &statusCode, &errData
+	}
+	return &user, nil, nil
+}
+
+// PurgeExpired deletes links that can no longer be used
+func (obj MagicLinkManager) PurgeExpired(db *gorm.DB) int64 {
+	return db.Where("expires_at <= ? OR used_at IS NOT NULL", time.Now()).Delete(&models.MagicLink{}).RowsAffected
+}
@@ -0,0 +1,530 @@
+package managers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// ORDER MANAGEMENT
+// --------------------------------
+type OrderManager struct{}
+
+func generateOrderNumber() string {
+	token, _ := utils.GenerateRandomToken(10, true)
+	return fmt.Sprintf("TT-%s", token)
+}
+
+// OrderOptions carries the optional parts of a checkout
+type OrderOptions struct {
+	CouponCode       string
+	AddressId        *uuid.UUID
+	ShippingMethodId *uuid.UUID
+}
+
+func (obj OrderManager) Create(db *gorm.DB, userId uuid.UUID, items []models.OrderItem, options ...OrderOptions) (*models.Order, *int, *utils.ErrorResponse) {
+	if len(items) == 0 {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, "Order must contain at least one item")
+		return nil, &statusCode, &errData
+	}
+	opts := OrderOptions{}
+	if len(options) > 0 {
+		opts = options[0]
+	}
+
+	subtotal := models.NewMoney(0, items[0].UnitPrice.Currency)
+	for _, item := range items {
+		if !item.UnitPrice.SameCurrency(subtotal) {
+			statusCode := 400
+			errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, "All items in an order must be priced in the same currency")
+			return nil, &statusCode, &errData
+		}
+		subtotal = subtotal.Add(item.UnitPrice.Multiply(item.Quantity))
+	}
+
+	order := models.Order{
+		OrderNumber: generateOrderNumber(),
+		UserId:      userId,
+		Status:      models.OrderStatusPending,
+		Items:       items,
+		Subtotal:    subtotal,
+		Discount:    models.NewMoney(0, subtotal.Currency),
+		Shipping:    models.NewMoney(0, subtotal.Currency),
+		Total:       subtotal,
+	}
+
+	// The order, its stock holds and its coupon redemption are written
+	// together, so a failure in any of them leaves none behind
+	expiresAt := reservationExpiry()
+	order.ReservedUntil = &expiresAt
+	var statusCode int
+	var errData utils.ErrorResponse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		couponManager := CouponManager{}
+		var coupon *models.Coupon
+		if opts.CouponCode != "" {
+			// The coupon stays locked until commit, so its limits can't be
+			// passed by concurrent checkouts
+			locked, errCode, lockErr := couponManager.Lock(tx, opts.CouponCode)
+			if errCode != nil {
+				statusCode = *errCode
+				errData = *lockErr
+				return &errData
+			}
+			discount, errCode, evalErr := couponManager.Evaluate(tx, locked, userId, items)
+			if errCode != nil {
+				statusCode = *errCode
+				errData = *evalErr
+				return &errData
+			}
+			coupon = locked
+			order.CouponCode = coupon.Code
+			order.Discount = discount
+			order.Total = models.NewMoney(subtotal.Amount-discount.Amount, subtotal.Currency)
+		}
+
+		if opts.AddressId != nil && opts.ShippingMethodId != nil {
+			// Delivery is priced on the goods after discount and the address
+			// is copied, so later edits to the address book don't touch it
+			address, errCode, addrErr := AddressManager{}.GetForUser(tx, userId, *opts.AddressId)
+			if errCode != nil {
+				statusCode = *errCode
+				errData = *addrErr
+				return &errData
+			}
+			method, shipping, errCode, quoteErr := ShippingManager{}.Quote(tx, *opts.ShippingMethodId, address.PostalAddress, order.Total)
+			if errCode != nil {
+				statusCode = *errCode
+				errData = *quoteErr
+				return &errData
+			}
+			order.ShippingMethodId = &method.ID
+			order.ShippingMethodName = method.Name
+			order.ShippingAddress = address.PostalAddress
+			order.Shipping = shipping
+			order.Total = order.Total.Add(shipping)
+		}
+
+		// Business buyers get their details on the VAT breakdown
+		buyer := models.User{}
+		tx.Select("company_name", "vat_number").Take(&buyer, "id = ?", userId)
+		order.BuyerCompany = buyer.CompanyName
+		order.BuyerVATNumber = buyer.VATNumber
+		TaxManager{}.Apply(tx, &order, TaxManager{}.Mode(), coupon)
+
+		if err := tx.Create(&order).Error; err != nil {
+			statusCode = 500
+			errData = utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create order")
+			return &errData
+		}
+
+		productManager := ProductManager{}
+		for _, item := range order.Items {
+			if _, errCode, resErr := productManager.Reserve(tx, item.ProductId, order.ID, item.Quantity, expiresAt); errCode != nil {
+				statusCode = *errCode
+				errData = *resErr
+				return &errData
+			}
+		}
+
+		if coupon != nil {
+			if errCode, redeemErr := couponManager.Redeem(tx, coupon, &order); errCode != nil {
+				statusCode = *errCode
+				errData = *redeemErr
+				return &errData
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &statusCode, &errData
+	}
+
+	return &order, nil, nil
+}
+
+func reservationExpiry() time.Time {
+	expirationMins := config.GetConfig().ReservationExpireMins
+	if expirationMins <= 0 {
+		expirationMins = 35
+	}
+	return time.Now().Add(time.Minute * time.Duration(expirationMins))
+}
+
+func (obj OrderManager) GetById(db *gorm.DB, id uuid.UUID) (*models.Order, *int, *utils.ErrorResponse) {
+	order := models.Order{}
+	db.Preload("Items").Take(&order, "id = ?", id)
+	if order.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Order does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &order, nil, nil
+}
+
+// GetForUser pages through the user's orders, newest first, optionally
+// only those in the given statuses. It also returns how many match in total.
+func (obj OrderManager) GetForUser(db *gorm.DB, userId uuid.UUID, statuses []models.OrderStatus, page int, limit int) ([]*models.Order, int64) {
+	orders := []*models.Order{}
+	query := db.Model(&models.Order{}).Where("user_id = ?", userId)
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+
+	var total int64
+	query.Count(&total)
+	query.Preload("Items").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&orders)
+	return orders, total
+}
+
+// OrderFilter narrows down the staff order search. Empty fields match everything.
+type OrderFilter struct {
+	Email       string
+	OrderNumber string
+	From        *time.Time
+	To          *time.Time
+	Statuses    []models.OrderStatus
+}
+
+func (obj OrderManager) filter(db *gorm.DB, filter OrderFilter) *gorm.DB {
+	query := db.Model(&models.Order{})
+	if filter.Email != "" {
+		query = query.Joins("JOIN users ON users.id = orders.user_id").Where("users.email ILIKE ?", "%"+filter.Email+"%")
+	}
+	if filter.OrderNumber != "" {
+		query = query.Where("orders.order_number ILIKE ?", "%"+filter.OrderNumber+"%")
+	}
+	if filter.From != nil {
+		query = query.Where("orders.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("orders.created_at < ?", *filter.To)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("orders.status IN ?", filter.Statuses)
+	}
+	return query
+}
+
+// Search pages through every customer's orders matching the filter, newest
+// first, along with how many match in total
+func (obj OrderManager) Search(db *gorm.DB, filter OrderFilter, page int, limit int) ([]*models.Order, int64) {
+	orders := []*models.Order{}
+	var total int64
+	obj.filter(db, filter).Count(&total)
+	obj.filter(db, filter).Preload("Items").Preload("User").
+		Order("orders.created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&orders)
+	return orders, total
+}
+
+// SearchAll is Search without the paging, for exports
+func (obj OrderManager) SearchAll(db *gorm.DB, filter OrderFilter) []*models.Order {
+	orders := []*models.Order{}
+	obj.filter(db, filter).Preload("Items").Preload("User").Order("orders.created_at DESC").Find(&orders)
+	return orders
+}
+
+// GetForUpdate loads the order with its row locked until the transaction ends
+func (obj OrderManager) GetForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Order, *int, *utils.ErrorResponse) {
+	order := models.Order{}
+	tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&order, "id = ?", id)
+	if order.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Order does not exist")
+		return nil, &statusCode, &errData
+	}
+	tx.Where("order_id = ?", order.ID).Find(&order.Items)
+	return &order, nil, nil
+}
+
+func (obj OrderManager) GetByCheckoutSession(db *gorm.DB, sessionId string) (*models.Order, *int, *utils.ErrorResponse) {
+	order := models.Order{}
+	db.Preload("Items").Take(&order, "stripe_checkout_session_id = ?", sessionId)
+	if order.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Order does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &order, nil, nil
+}
+
+func (obj OrderManager) GetByPaymentIntent(db *gorm.DB, paymentIntentId string) (*models.Order, *int, *utils.ErrorResponse) {
+	order := models.Order{}
+	db.Preload("Items").Take(&order, "stripe_payment_intent_id = ?", paymentIntentId)
+	if order.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Order does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &order, nil, nil
+}
+
+func (obj OrderManager) SetCheckoutSession(db *gorm.DB, order *models.Order, sessionId string) (*models.Order, *int, *utils.ErrorResponse) {
+	order.StripeCheckoutSessionId = &sessionId
+	if err := db.Model(order).Update("stripe_checkout_session_id", sessionId).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update order")
+		return nil, &statusCode, &errData
+	}
+	return order, nil, nil
+}
+
+func (obj OrderManager) SetPaymentIntent(db *gorm.DB, order *models.Order, paymentIntentId string) (*models.Order, *int, *utils.ErrorResponse) {
+	order.StripePaymentIntentId = &paymentIntentId
+	if err := db.Model(order).Update("stripe_payment_intent_id", paymentIntentId).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update order")
+		return nil, &statusCode, &errData
+	}
+	return order, nil, nil
+}
+
+func (obj OrderManager) UpdateStatus(db *gorm.DB, order *models.Order, status models.OrderStatus) (*models.Order, *int, *utils.ErrorResponse) {
+	if !status.IsValid() {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Invalid order status")
+		return nil, &statusCode, &errData
+	}
+
+	previous := order.Status
+	if err := order.TransitionTo(status); err != nil {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, err.Error())
+		return nil, &statusCode, &errData
+	}
+
+	if err := db.Omit("Items").Save(order).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update order status")
+		return nil, &statusCode, &errData
+	}
+
+	// A cancelled order no longer holds any stock or coupon use
+	if status == models.OrderStatusCancelled {
+		productManager := ProductManager{}
+		if errCode, errData := productManager.ReleaseReservations(db, order.ID); errCode != nil {
+			return nil, errCode, errData
+		}
+		if errCode, errData := (CouponManager{}).Release(db, order.ID); errCode != nil {
+			return nil, errCode, errData
+		}
+
+		// Paid stock was already taken off, so it goes back on
+		if previous == models.OrderStatusPaid {
+			for _, item := range order.Items {
+				if _, errCode, errData := productManager.UpdateStock(db, item.ProductId, item.Quantity, models.StockMovementReturn, nil, &order.ID); errCode != nil {
+					return nil, errCode, errData
+				}
+			}
+		}
+	}
+
+	return order, nil, nil
+}
+
+// BulkUpdateStatus moves each of the orders on to the status on its own, so
+// one that can't move doesn't hold the rest back. Failures are keyed by order id.
+func (obj OrderManager) BulkUpdateStatus(db *gorm.DB, orderIds []uuid.UUID, status models.OrderStatus) ([]*models.Order, map[uuid.UUID]string) {
+	updated := []*models.Order{}
+	failed := map[uuid.UUID]string{}
+	for _, orderId := range orderIds {
+		var order *models.Order
+		err := db.Transaction(func(tx *gorm.DB) error {
+			locked, errCode, errData := obj.GetForUpdate(tx, orderId)
+			if errCode != nil {
+				return errData
+			}
+			order, errCode, errData = obj.UpdateStatus(tx, locked, status)
+			if errCode != nil {
+				return errData
+			}
+			return nil
+		})
+		if err != nil {
+			failed[orderId] = err.Error()
+			continue
+		}
+		updated = append(updated, order)
+	}
+	return updated, failed
+}
+
+// Ship marks a fulfilled order as shipped with the parcel's tracking details
+func (obj OrderManager) Ship(db *gorm.DB, order *models.Order, carrier string, trackingNumber string) (*models.Order, *int, *utils.ErrorResponse) {
+	if !order.Status.CanTransitionTo(models.OrderStatusShipped) {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Only fulfilled orders can be shipped")
+		return nil, &statusCode, &errData
+	}
+	order.Carrier = carrier
+	order.TrackingNumber = trackingNumber
+	return obj.UpdateStatus(db, order, models.OrderStatusShipped)
+}
+
+func (obj OrderManager) AddNote(db *gorm.DB, orderId uuid.UUID, authorId uuid.UUID, body string) (*models.OrderNote, *int, *utils.ErrorResponse) {
+	note := models.OrderNote{OrderId: orderId, AuthorId: authorId, Body: body}
+	if err := db.Create(&note).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to add note")
+		return nil, &statusCode, &errData
+	}
+	return &note, nil, nil
+}
+
+func (obj OrderManager) GetNotes(db *gorm.DB, orderId uuid.UUID) []*models.OrderNote {
+	notes := []*models.OrderNote{}
+	db.Where("order_id = ?", orderId).Order("created_at ASC").Find(&notes)
+	return notes
+}
+
+// MarkPaid moves a pending order to paid and converts its reservations into
+// stock decrements. Orders that already left the pending state are returned
+// untouched so repeated payment notifications are harmless.
+func (obj OrderManager) MarkPaid(db *gorm.DB, order *models.Order, paymentIntentId string) (*models.Order, *int, *utils.ErrorResponse) {
+	if order.Status == models.OrderStatusCancelled {
+		log.Printf("Order %s: payment %s received for a cancelled order, needs manual review", order.OrderNumber, paymentIntentId)
+	}
+	if order.Status != models.OrderStatusPending {
+		return order, nil, nil
+	}
+
+	if paymentIntentId != "" {
+		order.StripePaymentIntentId = &paymentIntentId
+	}
+	order.LastPaymentError = ""
+	if _, errCode, errData := obj.UpdateStatus(db, order, models.OrderStatusPaid); errCode != nil {
+		return nil, errCode, errData
+	}
+
+	productManager := ProductManager{}
+	if errCode, errData := productManager.ConvertReservations(db, order); errCode != nil {
+		return nil, errCode, errData
+	}
+
+	// What was bought no longer belongs in the cart, and any reminder that
+	// brought the customer back gets the credit
+	CartManager{}.RemoveOrdered(db, order)
+	CartManager{}.RecordConversion(db, order)
+
+	// Only the number is taken here, the PDF is rendered when the invoice is
+	// first downloaded. If even that fails the invoice is raised on download.
+	invoiceManager := InvoiceManager{}
+	if _, errCode, errData := invoiceManager.IssueInvoice(db, order); errCode != nil {
+		log.Printf("Order %s: invoice not issued: %s", order.OrderNumber, errData.Message)
+	}
+
+	return order, nil, nil
+}
+
+func (obj OrderManager) RecordPaymentFailure(db *gorm.DB, order *models.Order, reason string) (*models.Order, *int, *utils.ErrorResponse) {
+	order.LastPaymentError = reason
+	if err := db.Model(order).Update("last_payment_error", reason).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update order")
+		return nil, &statusCode, &errData
+	}
+	return order, nil, nil
+}
+
+// RecordRefund stores the amount refunded so far and raises a credit note
+// for whatever is new since the last refund. A full refund moves the
+// order to refunded and puts the items back into stock, apart from units
+// that went through a return, whose restock the return decided.
+func (obj OrderManager) RecordRefund(db *gorm.DB, order *models.Order, amountRefunded models.Money, fullyRefunded bool) (*models.Order, *int, *utils.ErrorResponse) {
+	newlyRefunded := amountRefunded.Amount - order.Refunded.Amount
+	order.Refunded = amountRefunded
+	err := db.Model(order).Updates(map[string]interface{}{
+		"refunded_amount":   amountRefunded.Amount,
+		"refunded_currency": amountRefunded.Currency,
+	}).Error
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update order")
+		return nil, &statusCode, &errData
+	}
+
+	if newlyRefunded > 0 {
+		invoiceManager := InvoiceManager{}
+		if _, errCode, errData := invoiceManager.IssueCreditNote(db, order, models.NewMoney(newlyRefunded, amountRefunded.Currency)); errCode != nil {
+			log.Printf("Order %s: credit note not issued: %s", order.OrderNumber, errData.Message)
+		}
+	}
+
+	if !fullyRefunded || !order.Status.CanTransitionTo(models.OrderStatusRefunded) {
+		return order, nil, nil
+	}
+
+	if _, errCode, errData := obj.UpdateStatus(db, order, models.OrderStatusRefunded); errCode != nil {
+		return nil, errCode, errData
+	}
+
+	productManager := ProductManager{}
+	returned := ReturnManager{}.ReturnedQuantities(db, order.ID)
+	for _, item := range order.Items {
+		quantity := item.Quantity - returned[item.ID]
+		if quantity <= 0 {
+			continue
+		}
+		if _, errCode, errData := productManager.UpdateStock(db, item.ProductId, quantity, models.StockMovementReturn, nil, &order.ID); errCode != nil {
+			return nil, errCode, errData
+		}
+	}
+
+	return order, nil, nil
+}
+
+// ExpireReservations cancels pending orders whose stock holds have lapsed,
+// which releases the held stock back to the pool. The order's payment intent
+// is cancelled first so it can't be paid afterwards, and an order whose
+// payment already went through is left for its webhook. It returns how many
+// orders were cancelled.
+func (obj OrderManager) ExpireReservations(db *gorm.DB, provider payments.PaymentProvider) int {
+	var orderIds []uuid.UUID
+	db.Model(&models.StockReservation{}).
+		Distinct("order_id").
+		Where("status = ? AND expires_at <= ?", models.ReservationActive, time.Now()).
+		Pluck("order_id", &orderIds)
+
+	productManager := ProductManager{}
+	cancelled := 0
+	for _, orderId := range orderIds {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			order, errCode, errData := obj.GetForUpdate(tx, orderId)
+			if errCode != nil {
+				return errData
+			}
+			if order.Status != models.OrderStatusPending {
+				_, errData := productManager.ReleaseReservations(tx, orderId)
+				if errData != nil {
+					return errData
+				}
+				return nil
+			}
+			if order.StripePaymentIntentId != nil {
+				if err := provider.CancelIntent(*order.StripePaymentIntentId); err != nil {
+					return err
+				}
+			}
+			if _, errCode, errData := obj.UpdateStatus(tx, order, models.OrderStatusCancelled); errCode != nil {
+				return errData
+			}
+			cancelled++
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to expire reservations for order %s: %v", orderId, err)
+		}
+	}
+	return cancelled
+}
@@ -0,0 +1,451 @@
+package managers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// PASSKEY MANAGEMENT
+// --------------------------------
+type PasskeyManager struct{}
+
+// passkeyCeremonyExpiry is how long the browser has to answer a challenge
+const passkeyCeremonyExpiry = 5 * time.Minute
+
+// passkeyUser presents a user and their passkeys the way the webauthn
+// library expects. The user handle is the user's id.
+type passkeyUser struct {
+	user     *models.User
+	passkeys []*models.Passkey
+}
+
+func (u passkeyUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u passkeyUser) WebAuthnDisplayName() string {
+	return strings.TrimSpace(u.user.FirstName + " " + u.user.LastName)
+}
+
+func (u passkeyUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.passkeys))
+	for _, passkey := range u.passkeys {
+		transports := []protocol.AuthenticatorTransport{}
+		for _, transport := range strings.Split(passkey.Transports, ",") {
+			if transport != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(transport))
+			}
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              passkey.CredentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{AAGUID: passkey.AAGUID, SignCount: passkey.SignCount},
+		})
+	}
+	return credentials
+}
+
+func (u passkeyUser) exclusions() []protocol.CredentialDescriptor {
+	descriptors := []protocol.CredentialDescriptor{}
+	for _, credential := range u.WebAuthnCredentials() {
+		descriptors = append(descriptors, credential.Descriptor())
+	}
+	return descriptors
+}
+
+// relyingParty is this site as WebAuthn sees it. Passkeys have to be
+// discoverable, so login can start before we know who the user is, and have
+// to verify the user with a PIN or biometric, since passkey logins skip the
+// two-factor step.
+func relyingParty() (*webauthn.WebAuthn, error) {
+	cfg := config.GetConfig()
+	origins := []string{}
+	for _, origin := range strings.Split(cfg.WebAuthnRPOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: TotpIssuer,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		},
+	})
+}
+
+func passkeyErr() (*int, *utils.ErrorResponse) {
+	statusCode := 400
+	errData := utils.RequestErr(utils.ERR_INVALID_AUTH, "Passkey could not be verified")
+	return &statusCode, &errData
+}
+
+func passkeyServerErr() (*int, *utils.ErrorResponse) {
+	statusCode := 500
+	errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Passkeys are unavailable right now")
+	return &statusCode, &errData
+}
+
+func newPasskey(userId uuid.UUID, name string, credential *webauthn.Credential) models.Passkey {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	return models.Passkey{
+		UserId:          userId,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		Transports:      strings.Join(transports, ","),
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+}
+
+func (obj PasskeyManager) startCeremony(db *gorm.DB, kind models.PasskeyCeremonyKind, userId *uuid.UUID, session *webauthn.SessionData, signup []byte) (*models.PasskeyCeremony, *int, *utils.ErrorResponse) {
+	sessionData, err := json.Marshal(session)
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, errCode, errData
+	}
+	ceremony := models.PasskeyCeremony{
+		Kind:      kind,
+		UserId:    userId,
+		Session:   sessionData,
+		Signup:    signup,
+		ExpiresAt: time.Now().Add(passkeyCeremonyExpiry),
+	}
+	if err := db.Create(&ceremony).Error; err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, errCode, errData
+	}
+	return &ceremony, nil, nil
+}
+
+// takeCeremony claims a ceremony for finishing. It is deleted as it's read,
+// so the same challenge can't be answered twice.
+func (obj PasskeyManager) takeCeremony(db *gorm.DB, id uuid.UUID, kind models.PasskeyCeremonyKind) (*models.PasskeyCeremony, *webauthn.SessionData, *int, *utils.ErrorResponse) {
+	ceremony := models.PasskeyCeremony{}
+	db.Clauses(clause.Returning{}).Where("id = ? AND kind = ?", id, kind).Delete(&ceremony)
+	if ceremony.ID == uuid.Nil || time.Now().After(ceremony.ExpiresAt) {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_REQUEST, "Passkey request has expired, please try again")
+		return nil, nil, &statusCode, &errData
+	}
+	session := webauthn.SessionData{}
+	if err := json.Unmarshal(ceremony.Session, &session); err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, nil, errCode, errData
+	}
+	return &ceremony, &session, nil, nil
+}
+
+// BeginRegistration starts adding a passkey to a signed in user's account
+func (obj PasskeyManager) BeginRegistration(db *gorm.DB, user *models.User) (*protocol.CredentialCreation, *uuid.UUID, *int, *utils.ErrorResponse) {
+	rp, err := relyingParty()
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, nil, errCode, errData
+	}
+	owner := passkeyUser{user: user, passkeys: obj.GetAll(db, user.ID)}
+	options, session, err := rp.BeginRegistration(owner, webauthn.WithExclusions(owner.exclusions()))
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, nil, errCode, errData
+	}
+	ceremony, errCode, errData := obj.startCeremony(db, models.PasskeyCeremonyRegistration, &user.ID, session, nil)
+	if errCode != nil {
+		return nil, nil, errCode, errData
+	}
+	return options, &ceremony.ID, nil, nil
+}
+
+// FinishRegistration checks the new credential and saves it. Passkeys with
+// no name are named after the device they were made on.
+func (obj PasskeyManager) FinishRegistration(db *gorm.DB, user *models.User, data schemas.FinishPasskeyRegistrationSchema, userAgent string) (*models.Passkey, *int, *utils.ErrorResponse) {
+	ceremony, session, errCode, errData := obj.takeCeremony(db, data.CeremonyId, models.PasskeyCeremonyRegistration)
+	if errCode != nil {
+		return nil, errCode, errData
+	}
+	if ceremony.UserId == nil || *ceremony.UserId != user.ID {
+		errCode, errData := passkeyErr()
+		return nil, errCode, errData
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, errCode, errData
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(data.Credential))
+	if err != nil {
+		errCode, errData := passkeyErr()
+		return nil, errCode, errData
+	}
+	credential, err := rp.CreateCredential(passkeyUser{user: user}, *session, parsed)
+	if err != nil {
+		errCode, errData := passkeyErr()
+		return nil, errCode, errData
+	}
+
+	name := data.Name
+	if name == "" {
+		name = deviceLabel(userAgent)
+	}
+	passkey := newPasskey(user.ID, name, credential)
+	if err := db.Create(&passkey).Error; err != nil {
+		statusCode := 409
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "This passkey has already been added")
+		return nil, &statusCode, &errData
+	}
+	return &passkey, nil, nil
+}
+
+func emailTakenErr() (*int, *utils.ErrorResponse) {
+	statusCode := 422
+	errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, "Invalid Entry", map[string]string{
+		"email": "Email already taken!",
+	})
+	return &statusCode, &errData
+}
+
+// BeginSignup starts creating an account that signs in with a passkey
+// instead of a password. Nothing is saved until the passkey is made.
+func (obj PasskeyManager) BeginSignup(db *gorm.DB, data schemas.PasskeySignupSchema) (*protocol.CredentialCreation, *uuid.UUID, *int, *utils.ErrorResponse) {
+	existing := models.User{}
+	db.Where("email = ?", data.Email).Take(&existing)
+	if existing.ID != uuid.Nil {
+		errCode, errData := emailTakenErr()
+		return nil, nil, errCode, errData
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, nil, errCode, errData
+	}
+	// The account's id is chosen now, since the passkey stores it as the user handle
+	user := models.User{ID: uuid.New(), FirstName: data.FirstName, LastName: data.LastName, Email: data.Email}
+	options, session, err := rp.BeginRegistration(passkeyUser{user: &user})
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, nil, errCode, errData
+	}
+	signup, _ := json.Marshal(data)
+	ceremony, errCode, errData := obj.startCeremony(db, models.PasskeyCeremonySignup, &user.ID, session, signup)
+	if errCode != nil {
+		return nil, nil, errCode, errData
+	}
+	return options, &ceremony.ID, nil, nil
+}
+
+// FinishSignup creates the account along with its first passkey. Like any
+// other new account, its email still has to be verified.
+func (obj PasskeyManager) FinishSignup(db *gorm.DB, data schemas.FinishPasskeySchema, userAgent string) (*models.User, *int, *utils.ErrorResponse) {
+	ceremony, session, errCode, errData := obj.takeCeremony(db, data.CeremonyId, models.PasskeyCeremonySignup)
+	if errCode != nil {
+		return nil, errCode, errData
+	}
+	signup := schemas.PasskeySignupSchema{}
+	if ceremony.UserId == nil || json.Unmarshal(ceremony.Signup, &signup) != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, errCode, errData
+	}
+	user := models.User{
+		ID:        *ceremony.UserId,
+		FirstName: signup.FirstName,
+		LastName:  signup.LastName,
+		Email:     signup.Email,
+		AuthType:  models.AuthTypePasskey,
+		// Nobody knows this password, until the user sets one with a reset
+		Password: uuid.NewString(),
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, errCode, errData
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(data.Credential))
+	if err != nil {
+		errCode, errData := passkeyErr()
+		return nil, errCode, errData
+	}
+	credential, err := rp.CreateCredential(passkeyUser{user: &user}, *session, parsed)
+	if err != nil {
+		errCode, errData := passkeyErr()
+		return nil, errCode, errData
+	}
+
+	var statusCode *int
+	var errResp *utils.ErrorResponse
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// The email could have been taken while the passkey was being made
+		existing := models.User{}
+		tx.Where("email = ?", user.Email).Take(&existing)
+		if existing.ID != uuid.Nil {
+			statusCode, errResp = emailTakenErr()
+			return errResp
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		passkey := newPasskey(user.ID, deviceLabel(userAgent), credential)
+		return tx.Create(&passkey).Error
+	})
+	if err != nil {
+		if statusCode == nil {
+			statusCode, errResp = passkeyServerErr()
+		}
+		return nil, statusCode, errResp
+	}
+	return &user, nil, nil
+}
+
+// BeginLogin starts a passkey login. The browser offers whichever passkeys
+// it holds for this site, so no email is needed.
+func (obj PasskeyManager) BeginLogin(db *gorm.DB) (*protocol.CredentialAssertion, *uuid.UUID, *int, *utils.ErrorResponse) {
+	rp, err := relyingParty()
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, nil, errCode, errData
+	}
+	options, session, err := rp.BeginDiscoverableLogin()
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, nil, errCode, errData
+	}
+	ceremony, errCode, errData := obj.startCeremony(db, models.PasskeyCeremonyLogin, nil, session, nil)
+	if errCode != nil {
+		return nil, nil, errCode, errData
+	}
+	return options, &ceremony.ID, nil, nil
+}
+
+// FinishLogin checks the signed challenge and returns the passkey's owner.
+// A sign count that hasn't gone up means the key may have been cloned, so
+// the login is refused.
+func (obj PasskeyManager) FinishLogin(db *gorm.DB, data schemas.FinishPasskeySchema) (*models.User, *int, *utils.ErrorResponse) {
+	_, session, errCode, errData := obj.takeCeremony(db, data.CeremonyId, models.PasskeyCeremonyLogin)
+	if errCode != nil {
+		return nil, errCode, errData
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		errCode, errData := passkeyServerErr()
+		return nil, errCode, errData
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(data.Credential))
+	if err != nil {
+		errCode, errData := passkeyErr()
+		return nil, errCode, errData
+	}
+
+	owner := passkeyUser{}
+	findOwner := func(rawID, userHandle []byte) (webauthn.User, error) {
+		passkey := models.Passkey{}
+		db.Where("credential_id = ?", rawID).Take(&passkey)
+		if passkey.ID == uuid.Nil || !bytes.Equal(passkey.UserId[:], userHandle) {
+			return nil, protocol.ErrBadRequest.WithDetails("Unknown passkey")
+		}
+		user := models.User{}
+		db.Where("id = ?", passkey.UserId).Take(&user)
+		if user.ID == uuid.Nil {
+			return nil, protocol.ErrBadRequest.WithDetails("Unknown passkey")
+		}
+		owner = passkeyUser{user: &user, passkeys: obj.GetAll(db, user.ID)}
+		return owner, nil
+	}
+	credential, err := rp.ValidateDiscoverableLogin(findOwner, *session, parsed)
+	if err != nil || credential.Authenticator.CloneWarning {
+		errCode, errData := passkeyErr()
+		return nil, errCode, errData
+	}
+
+	now := time.Now()
+	db.Model(&models.Passkey{}).Where("credential_id = ?", credential.ID).Updates(map[string]interface{}{
+		"sign_count":   credential.Authenticator.SignCount,
+		"backup_state": credential.Flags.BackupState,
+		"last_used_at": now,
+	})
+	return owner.user, nil, nil
+}
+
+func (obj PasskeyManager) GetAll(db *gorm.DB, userId uuid.UUID) []*models.Passkey {
+	passkeys := []*models.Passkey{}
+	db.Where("user_id = ?", userId).Order("created_at").Find(&passkeys)
+	return passkeys
+}
+
+func (obj PasskeyManager) GetForUser(db *gorm.DB, userId uuid.UUID, id uuid.UUID) (*models.Passkey, *int, *utils.ErrorResponse) {
+	passkey := models.Passkey{}
+	db.Where("id = ? AND user_id = ?", id, userId).Take(&passkey)
+	if passkey.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Passkey does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &passkey, nil, nil
+}
+
+func (obj PasskeyManager) Rename(db *gorm.DB, passkey *models.Passkey, name string) *models.Passkey {
+	passkey.Name = name
+	db.Model(passkey).Update("name", name)
+	return passkey
+}
+
+// Delete removes a passkey. Accounts made with a passkey have no password
+// of their own, so their last passkey has to stay.
+func (obj PasskeyManager) Delete(db *gorm.DB, user *models.User, passkey *models.Passkey) (*int, *utils.ErrorResponse) {
+	if user.AuthType == models.AuthTypePasskey {
+		var count int64
+		db.Model(&models.Passkey{}).Where("user_id = ?", user.ID).Count(&count)
+		if count <= 1 {
+			statusCode := 400
+			errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "You can't remove your only passkey. Add another one or set a password first")
+			return &statusCode, &errData
+		}
+	}
+	db.Delete(passkey)
+	return nil, nil
+}
+
+// PurgeExpiredCeremonies deletes challenges nobody answered
+func (obj PasskeyManager) PurgeExpiredCeremonies(db *gorm.DB) int64 {
+	return db.Where("expires_at <= ?", time.Now()).Delete(&models.PasskeyCeremony{}).RowsAffected
+}
@@ -0,0 +1,49 @@
+package managers
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+)
+
+// ----------------------------------
+// PRICING MANAGEMENT
+// --------------------------------
+// PricingManager is the one place that decides what a product costs right
+// now. Listings, carts, payment line items and order snapshots all go
+// through it so the price shown is the price charged.
+type PricingManager struct{}
+
+// UnitPrice resolves the effective price of a single unit of the product.
+func (obj PricingManager) UnitPrice(db *gorm.DB, product *models.Product) models.Money {
+	return obj.bestPrice(product, PromotionManager{}.Live(db))
+}
+
+// Apply fills in EffectivePrice on each product.
+func (obj PricingManager) Apply(db *gorm.DB, products ...*models.Product) {
+	if len(products) == 0 {
+		return
+	}
+	promotions := PromotionManager{}.Live(db)
+	for _, product := range products {
+		product.EffectivePrice = obj.bestPrice(product, promotions)
+	}
+}
+
+// bestPrice picks the lowest of the list price, the product's own discount
+// and any promotion that targets it. Promotions don't stack.
+func (obj PricingManager) bestPrice(product *models.Product, promotions []models.Promotion) models.Money {
+	price := product.Price
+	if product.IsDiscounted && product.DiscountedPrice.Amount > 0 && product.DiscountedPrice.Amount < price.Amount {
+		price = models.NewMoney(product.DiscountedPrice.Amount, price.Currency)
+	}
+	for _, promotion := range promotions {
+		if !promotion.AppliesTo(product) {
+			continue
+		}
+		if promoted := promotion.Apply(product.Price); promoted.Amount < price.Amount {
+			price = promoted
+		}
+	}
+	return price
+}
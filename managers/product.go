@@ -3,10 +3,12 @@ package managers
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gosimple/slug"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/DanSmirnov48/techno-trades-go-backend/models"
 	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
@@ -19,13 +21,32 @@ import (
 type ProductManager struct{}
 
 func (obj ProductManager) Create(db *gorm.DB, data schemas.CreateProduct, userId uuid.UUID) *models.Product {
-	product := utils.ConvertStructData(data, models.Product{}).(*models.Product)
-	product.ID = uuid.New()
-	product.Slug = slug.Make(product.Name)
-	product.Rating = 0
-	product.UserID = userId
+	product := &models.Product{
+		ID:              uuid.New(),
+		Slug:            slug.Make(data.Name),
+		Name:            data.Name,
+		Brand:           data.Brand,
+		Category:        data.Category,
+		Description:     data.Description,
+		Rating:          0,
+		Price:           models.NewMoney(data.Price, data.Currency),
+		CountInStock:    data.CountInStock,
+		IsDiscounted:    data.IsDiscounted,
+		DiscountedPrice: models.NewMoney(data.DiscountedPrice, data.Currency),
+		UserID:          userId,
+	}
 
-	db.Create(&product)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		// Opening stock is the first entry in the ledger
+		return recordStockMovement(tx, product.ID, product.CountInStock, models.StockMovementRestock, &userId, nil)
+	})
+	if err != nil {
+		log.Printf("Failed to create product: %v", err)
+		return &models.Product{}
+	}
 
 	return product
 }
@@ -39,6 +60,7 @@ func (obj ProductManager) GetAll(db *gorm.DB) ([]*models.Product, *int, *utils.E
 		return nil, &status_code, &errData
 	}
 
+	PricingManager{}.Apply(db, products...)
 	return products, nil, nil
 }
 
@@ -50,6 +72,7 @@ func (obj ProductManager) GetById(db *gorm.DB, id uuid.UUID) (*models.Product, *
 		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Product does not exist")
 		return nil, &status_code, &errData
 	}
+	PricingManager{}.Apply(db, &product)
 	return &product, nil, nil
 }
 
@@ -61,6 +84,7 @@ func (obj ProductManager) GetBySlug(db *gorm.DB, slug string) (*models.Product,
 		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Product does not exist")
 		return nil, &status_code, &errData
 	}
+	PricingManager{}.Apply(db, &product)
 	return &product, nil, nil
 }
 
@@ -73,7 +97,7 @@ func (obj ProductManager) UpdateDiscount(db *gorm.DB, id uuid.UUID, data schemas
 		return nil, &status_code, &errData
 	}
 
-	if data.DiscountedPrice >= product.Price {
+	if data.DiscountedPrice >= product.Price.Amount {
 		statusCode := 400
 		errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, "Discounted price has to be lower than original!")
 		return nil, &statusCode, &errData
@@ -81,9 +105,9 @@ func (obj ProductManager) UpdateDiscount(db *gorm.DB, id uuid.UUID, data schemas
 
 	product.IsDiscounted = data.IsDiscounted
 	if data.IsDiscounted {
-		product.DiscountedPrice = data.DiscountedPrice
+		product.DiscountedPrice = models.NewMoney(data.DiscountedPrice, product.Price.Currency)
 	} else {
-		product.DiscountedPrice = 0.0
+		product.DiscountedPrice = models.NewMoney(0, product.Price.Currency)
 	}
 
 	if err := db.Save(product).Error; err != nil {
@@ -95,36 +119,236 @@ func (obj ProductManager) UpdateDiscount(db *gorm.DB, id uuid.UUID, data schemas
 	return &product, nil, nil
 }
 
-func (pm ProductManager) UpdateStock(db *gorm.DB, id uuid.UUID, stockChange int) (*models.Product, *int, *utils.ErrorResponse) {
-	product := models.Product{ID: id}
-	db.Take(&product, product)
+// UpdateStock changes a product's stock and records why in the stock ledger,
+// both in the same transaction. Restocks and adjustments can't take the stock
+// below what active reservations are holding.
+func (pm ProductManager) UpdateStock(db *gorm.DB, id uuid.UUID, stockChange int, reason models.StockMovementReason, actorId *uuid.UUID, orderId *uuid.UUID) (*models.Product, *int, *utils.ErrorResponse) {
+	product := models.Product{}
+	var statusCode int
+	var errData utils.ErrorResponse
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the row so concurrent stock changes are applied one after another
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&product, "id = ?", id)
+		if product.ID == uuid.Nil {
+			statusCode = 404
+			errData = utils.RequestErr(utils.ERR_NON_EXISTENT, "Product does not exist")
+			return &errData
+		}
+
+		// Calculate the new stock value
+		newStock := product.CountInStock + stockChange
+
+		// Ensure the stock doesn't fall below zero
+		if newStock < 0 {
+			statusCode = 400
+			errData = utils.RequestErr(utils.ERR_INVALID_ENTRY, "Insufficient stock to complete the operation")
+			return &errData
+		}
+
+		// Reservations take the same row lock, so the held quantity can't change under us
+		if stockChange < 0 && (reason == models.StockMovementAdjustment || reason == models.StockMovementRestock) {
+			if reserved := pm.ReservedStock(tx, id); newStock < reserved {
+				statusCode = 400
+				errData = utils.RequestErr(utils.ERR_INVALID_ENTRY, fmt.Sprintf("Stock can't go below the %d units held for pending orders", reserved))
+				return &errData
+			}
+		}
+
+		// Update product stock
+		product.CountInStock = newStock
+
+		// Save the updated product in the database
+		if err := tx.Save(&product).Error; err != nil {
+			statusCode = 500
+			errData = utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update product stock")
+			return &errData
+		}
+
+		if err := recordStockMovement(tx, product.ID, stockChange, reason, actorId, orderId); err != nil {
+			statusCode = 500
+			errData = utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to record stock movement")
+			return &errData
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &statusCode, &errData
+	}
+
+	return &product, nil, nil
+}
+
+// ReservedStock is the quantity currently held by unexpired reservations
+func (obj ProductManager) ReservedStock(db *gorm.DB, id uuid.UUID) int {
+	var reserved int
+	db.Model(&models.StockReservation{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("product_id = ? AND status = ? AND expires_at > ?", id, models.ReservationActive, time.Now()).
+		Scan(&reserved)
+	return reserved
+}
+
+// Reserve holds stock for a pending order. The product row is locked while the
+// available quantity is checked, so concurrent checkouts can't both take the last unit.
+// It must run inside a transaction for the lock to hold until the reservation is written.
+func (obj ProductManager) Reserve(db *gorm.DB, id uuid.UUID, orderId uuid.UUID, quantity int, expiresAt time.Time) (*models.StockReservation, *int, *utils.ErrorResponse) {
+	product := models.Product{}
+	db.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&product, "id = ?", id)
 	if product.ID == uuid.Nil {
-		status_code := 404
+		statusCode := 404
 		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Product does not exist")
-		return nil, &status_code, &errData
+		return nil, &statusCode, &errData
 	}
 
-	// Calculate the new stock value
-	newStock := product.CountInStock + stockChange
-
-	// Ensure the stock doesn't fall below zero
-	if newStock < 0 {
+	available := product.CountInStock - obj.ReservedStock(db, id)
+	if quantity > available {
+		msg := fmt.Sprintf("Insufficient stock for product: %s. Available: %d, Requested: %d", product.Name, available, quantity)
 		statusCode := 400
-		errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, "Insufficient stock to complete the operation")
+		errData := utils.RequestErr(utils.ERR_INVALID_ENTRY, msg)
+		return nil, &statusCode, &errData
+	}
+
+	reservation := models.StockReservation{
+		ProductId: id,
+		OrderId:   orderId,
+		Quantity:  quantity,
+		Status:    models.ReservationActive,
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Create(&reservation).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to reserve stock")
 		return nil, &statusCode, &errData
 	}
+	if err := recordStockMovement(db, id, -quantity, models.StockMovementReservation, nil, &orderId); err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to record stock movement")
+		return nil, &statusCode, &errData
+	}
+	return &reservation, nil, nil
+}
 
-	// Update product stock
-	product.CountInStock = newStock
+// ConvertReservations turns an order's active reservations into stock decrements.
+// Items whose reservation already lapsed are decremented directly.
+func (obj ProductManager) ConvertReservations(db *gorm.DB, order *models.Order) (*int, *utils.ErrorResponse) {
+	var reservations []models.StockReservation
+	db.Where("order_id = ? AND status = ?", order.ID, models.ReservationActive).Find(&reservations)
 
-	// Save the updated product in the database
-	if err := db.Save(product).Error; err != nil {
+	reserved := make(map[uuid.UUID]bool)
+	for _, reservation := range reservations {
+		if err := db.Model(&reservation).Update("status", models.ReservationConverted).Error; err != nil {
+			statusCode := 500
+			errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update reservation")
+			return &statusCode, &errData
+		}
+		// The hold is lifted and replaced by the sale itself
+		if err := recordStockMovement(db, reservation.ProductId, reservation.Quantity, models.StockMovementReservation, nil, &order.ID); err != nil {
+			statusCode := 500
+			errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to record stock movement")
+			return &statusCode, &errData
+		}
+		if _, errCode, errData := obj.UpdateStock(db, reservation.ProductId, -reservation.Quantity, models.StockMovementSale, nil, &order.ID); errCode != nil {
+			// The customer has already paid, so an oversell is logged rather than rejected
+			log.Printf("Order %s: failed to decrement stock for product %s: %s", order.OrderNumber, reservation.ProductId, errData.Message)
+		}
+		reserved[reservation.ProductId] = true
+	}
+
+	for _, item := range order.Items {
+		if reserved[item.ProductId] {
+			continue
+		}
+		if _, errCode, errData := obj.UpdateStock(db, item.ProductId, -item.Quantity, models.StockMovementSale, nil, &order.ID); errCode != nil {
+			// The customer has already paid, so an oversell is logged rather than rejected
+			log.Printf("Order %s: failed to decrement stock for product %s: %s", order.OrderNumber, item.ProductId, errData.Message)
+		}
+	}
+	return nil, nil
+}
+
+// ReleaseReservations gives an order's held stock back to the pool
+func (obj ProductManager) ReleaseReservations(db *gorm.DB, orderId uuid.UUID) (*int, *utils.ErrorResponse) {
+	var reservations []models.StockReservation
+	db.Where("order_id = ? AND status = ?", orderId, models.ReservationActive).Find(&reservations)
+
+	for _, reservation := range reservations {
+		err := db.Model(&reservation).Update("status", models.ReservationReleased).Error
+		if err == nil {
+			err = recordStockMovement(db, reservation.ProductId, reservation.Quantity, models.StockMovementReservation, nil, &orderId)
+		}
+		if err != nil {
+			statusCode := 500
+			errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to release reservations")
+			return &statusCode, &errData
+		}
+	}
+	return nil, nil
+}
+
+func recordStockMovement(db *gorm.DB, productId uuid.UUID, delta int, reason models.StockMovementReason, actorId *uuid.UUID, orderId *uuid.UUID) error {
+	movement := models.StockMovement{
+		ProductId: productId,
+		Delta:     delta,
+		Reason:    reason,
+		ActorId:   actorId,
+		OrderId:   orderId,
+	}
+	return db.Create(&movement).Error
+}
+
+func (obj ProductManager) GetStockMovements(db *gorm.DB, id uuid.UUID) ([]*models.StockMovement, *int, *utils.ErrorResponse) {
+	if _, errCode, errData := obj.GetById(db, id); errCode != nil {
+		return nil, errCode, errData
+	}
+
+	movements := []*models.StockMovement{}
+	if err := db.Where("product_id = ?", id).Order("created_at DESC").Find(&movements).Error; err != nil {
 		statusCode := 500
-		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update product stock")
+		errData := utils.RequestErr(utils.ERR_NETWORK_FAILURE, "Failed to fetch stock movements")
 		return nil, &statusCode, &errData
 	}
+	return movements, nil, nil
+}
 
-	return &product, nil, nil
+type StockDrift struct {
+	ProductId      uuid.UUID
+	Name           string
+	CountInStock   int
+	LedgerStock    int
+	Reserved       int
+	LedgerReserved int
+	Movements      int
+}
+
+func (d StockDrift) HasDrift() bool {
+	return d.CountInStock != d.LedgerStock || d.Reserved != d.LedgerReserved
+}
+
+// ReconcileStock recomputes every product's stock and held quantity from the
+// ledger and compares them with what is stored.
+func (obj ProductManager) ReconcileStock(db *gorm.DB) ([]StockDrift, error) {
+	var drifts []StockDrift
+	err := db.Table("products").
+		Select(`products.id AS product_id, products.name, products.count_in_stock,
+			COALESCE((SELECT SUM(delta) FROM stock_movements m WHERE m.product_id = products.id AND m.reason <> ?), 0) AS ledger_stock,
+			COALESCE((SELECT SUM(quantity) FROM stock_reservations r WHERE r.product_id = products.id AND r.status = ?), 0) AS reserved,
+			COALESCE((SELECT -SUM(delta) FROM stock_movements m WHERE m.product_id = products.id AND m.reason = ?), 0) AS ledger_reserved,
+			(SELECT COUNT(*) FROM stock_movements m WHERE m.product_id = products.id) AS movements`,
+			models.StockMovementReservation, models.ReservationActive, models.StockMovementReservation).
+		Where("products.deleted_at IS NULL").
+		Order("products.name").
+		Scan(&drifts).Error
+	return drifts, err
+}
+
+// BaselineStock opens the ledger of products that predate it with an
+// adjustment equal to their current stock.
+func (obj ProductManager) BaselineStock(db *gorm.DB, drift StockDrift) error {
+	if drift.Movements > 0 {
+		return nil
+	}
+	return recordStockMovement(db, drift.ProductId, drift.CountInStock, models.StockMovementAdjustment, nil, nil)
 }
 
 func (obj ProductManager) UpdateRating(db *gorm.DB, productId *uuid.UUID) (*models.Product, *int, *utils.ErrorResponse) {
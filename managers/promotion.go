@@ -0,0 +1,140 @@
+package managers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// PROMOTION MANAGEMENT
+// --------------------------------
+type PromotionManager struct{}
+
+func validatePromotion(data schemas.CreatePromotion) (*int, *utils.ErrorResponse) {
+	if data.Type == models.PromotionPercentage && data.Value > 100 {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Percentage promotions can't exceed 100")
+		return &statusCode, &errData
+	}
+	if data.TargetType == models.PromotionTargetProduct {
+		if _, err := uuid.Parse(data.Target); err != nil {
+			statusCode := 400
+			errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Product promotions must target a product id")
+			return &statusCode, &errData
+		}
+	}
+	return nil, nil
+}
+
+func (obj PromotionManager) Create(db *gorm.DB, data schemas.CreatePromotion, userId uuid.UUID) (*models.Promotion, *int, *utils.ErrorResponse) {
+	if errCode, errData := validatePromotion(data); errCode != nil {
+		return nil, errCode, errData
+	}
+
+	promotion := models.Promotion{
+		Name:        data.Name,
+		Type:        data.Type,
+		Value:       data.Value,
+		Currency:    models.NewMoney(0, data.Currency).Currency,
+		TargetType:  data.TargetType,
+		Target:      strings.TrimSpace(data.Target),
+		StartsAt:    data.StartsAt,
+		EndsAt:      data.EndsAt,
+		CreatedById: userId,
+	}
+	// Windows that have already opened go live straight away
+	promotion.Status = promotion.StatusAt(time.Now())
+
+	if err := db.Create(&promotion).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create promotion")
+		return nil, &statusCode, &errData
+	}
+	return &promotion, nil, nil
+}
+
+func (obj PromotionManager) GetAll(db *gorm.DB, status string) []*models.Promotion {
+	promotions := []*models.Promotion{}
+	query := db.Order("starts_at DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Find(&promotions)
+	return promotions
+}
+
+func (obj PromotionManager) GetById(db *gorm.DB, id uuid.UUID) (*models.Promotion, *int, *utils.ErrorResponse) {
+	promotion := models.Promotion{}
+	db.Take(&promotion, "id = ?", id)
+	if promotion.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Promotion does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &promotion, nil, nil
+}
+
+func (obj PromotionManager) Update(db *gorm.DB, promotion *models.Promotion, data schemas.CreatePromotion) (*models.Promotion, *int, *utils.ErrorResponse) {
+	if errCode, errData := validatePromotion(data); errCode != nil {
+		return nil, errCode, errData
+	}
+
+	promotion.Name = data.Name
+	promotion.Type = data.Type
+	promotion.Value = data.Value
+	promotion.Currency = models.NewMoney(0, data.Currency).Currency
+	promotion.TargetType = data.TargetType
+	promotion.Target = strings.TrimSpace(data.Target)
+	promotion.StartsAt = data.StartsAt
+	promotion.EndsAt = data.EndsAt
+	promotion.Status = promotion.StatusAt(time.Now())
+
+	if err := db.Save(promotion).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update promotion")
+		return nil, &statusCode, &errData
+	}
+	return promotion, nil, nil
+}
+
+func (obj PromotionManager) Delete(db *gorm.DB, promotion *models.Promotion) (*int, *utils.ErrorResponse) {
+	if err := db.Delete(promotion).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to delete promotion")
+		return &statusCode, &errData
+	}
+	return nil, nil
+}
+
+// Live returns the promotions that are active right now
+func (obj PromotionManager) Live(db *gorm.DB) []models.Promotion {
+	now := time.Now()
+	var promotions []models.Promotion
+	db.Where("status = ? AND starts_at <= ? AND ends_at > ?", models.PromotionActive, now, now).Find(&promotions)
+	return promotions
+}
+
+// SyncStatuses activates promotions whose window has opened and expires the
+// ones whose window has closed. It returns how many were activated and expired.
+func (obj PromotionManager) SyncStatuses(db *gorm.DB) (int64, int64) {
+	now := time.Now()
+	activated := db.Model(&models.Promotion{}).
+		Where("status = ? AND starts_at <= ? AND ends_at > ?", models.PromotionScheduled, now, now).
+		Update("status", models.PromotionActive)
+	expired := db.Model(&models.Promotion{}).
+		Where("status <> ? AND ends_at <= ?", models.PromotionExpired, now).
+		Update("status", models.PromotionExpired)
+
+	if activated.Error != nil || expired.Error != nil {
+		log.Printf("Failed to sync promotion statuses: %v %v", activated.Error, expired.Error)
+	}
+	return activated.RowsAffected, expired.RowsAffected
+}
@@ -0,0 +1,300 @@
+package managers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// RETURN MANAGEMENT
+// --------------------------------
+type ReturnManager struct{}
+
+func generateRMANumber() string {
+	token, _ := utils.GenerateRandomToken(10, true)
+	return fmt.Sprintf("RMA-%s", token)
+}
+
+// ReturnedQuantities is how many of each order line are already covered by
+// returns that haven't been rejected, keyed by order item
+func (obj ReturnManager) ReturnedQuantities(db *gorm.DB, orderId uuid.UUID) map[uuid.UUID]int {
+	var rows []struct {
+		OrderItemId uuid.UUID
+		Quantity    int
+	}
+	db.Model(&models.ReturnItem{}).
+		Select("return_items.order_item_id, SUM(return_items.quantity) AS quantity").
+		Joins("JOIN return_requests ON return_requests.id = return_items.return_id").
+		Where("return_requests.order_id = ? AND return_requests.status <> ?", orderId, models.ReturnStatusRejected).
+		Group("return_items.order_item_id").
+		Scan(&rows)
+
+	returned := map[uuid.UUID]int{}
+	for _, row := range rows {
+		returned[row.OrderItemId] = row.Quantity
+	}
+	return returned
+}
+
+// lineRefund is what the buyer paid for quantity units of an order line:
+// the line's share of any order discount comes off, and VAT charged on top
+// in exclusive mode goes back. Delivery isn't refunded.
+func lineRefund(order *models.Order, item models.OrderItem, quantity int) models.Money {
+	line := item.UnitPrice.Multiply(quantity)
+	amount := line.Amount
+	if order.Subtotal.Amount > 0 {
+		amount -= order.Discount.Amount * line.Amount / order.Subtotal.Amount
+	}
+	if order.TaxMode == models.TaxModeExclusive && item.Quantity > 0 {
+		amount += item.Tax.Amount * int64(quantity) / int64(item.Quantity)
+	}
+	return models.NewMoney(amount, line.Currency)
+}
+
+// Create opens a return for lines of one of the user's delivered orders.
+// The order row is locked while quantities are checked, so two requests
+// can't return the same units.
+func (obj ReturnManager) Create(db *gorm.DB, userId uuid.UUID, data schemas.CreateReturn) (*models.ReturnRequest, *int, *utils.ErrorResponse) {
+	rma := models.ReturnRequest{
+		RMANumber: generateRMANumber(),
+		UserId:    userId,
+		Status:    models.ReturnStatusRequested,
+		Reason:    data.Reason,
+		Details:   data.Details,
+	}
+
+	var statusCode int
+	var errData utils.ErrorResponse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		order := models.Order{}
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Take(&order, "id = ? AND user_id = ?", data.OrderId, userId)
+		if order.ID == uuid.Nil {
+			statusCode = 404
+			errData = utils.RequestErr(utils.ERR_NON_EXISTENT, "Order does not exist")
+			return &errData
+		}
+		if order.Status != models.OrderStatusDelivered || order.DeliveredAt == nil {
+			statusCode = 400
+			errData = utils.RequestErr(utils.ERR_NOT_ALLOWED, "Only delivered orders can be returned")
+			return &errData
+		}
+		if time.Since(*order.DeliveredAt) > models.ReturnWindow {
+			statusCode = 400
+			errData = utils.RequestErr(utils.ERR_NOT_ALLOWED, "The return window for this order has closed")
+			return &errData
+		}
+		tx.Where("order_id = ?", order.ID).Find(&order.Items)
+
+		// Merge repeated lines before checking what's left to return
+		requested := map[uuid.UUID]int{}
+		for _, item := range data.Items {
+			requested[item.OrderItemId] += item.Quantity
+		}
+		returned := obj.ReturnedQuantities(tx, order.ID)
+
+		rma.OrderId = order.ID
+		rma.Refund = models.NewMoney(0, order.Total.Currency)
+		for _, item := range order.Items {
+			quantity, ok := requested[item.ID]
+			if !ok {
+				continue
+			}
+			delete(requested, item.ID)
+			if quantity > item.Quantity-returned[item.ID] {
+				statusCode = 400
+				errData = utils.RequestErr(utils.ERR_INVALID_ENTRY, fmt.Sprintf("Only %d of %s can be returned", item.Quantity-returned[item.ID], item.Name))
+				return &errData
+			}
+			rma.Items = append(rma.Items, models.ReturnItem{
+				OrderItemId: item.ID,
+				ProductId:   item.ProductId,
+				Name:        item.Name,
+				Quantity:    quantity,
+			})
+			rma.Refund = rma.Refund.Add(lineRefund(&order, item, quantity))
+		}
+		if len(requested) > 0 {
+			statusCode = 400
+			errData = utils.RequestErr(utils.ERR_INVALID_ENTRY, "Items must belong to the order")
+			return &errData
+		}
+
+		rma.History = []models.ReturnStatusChange{{Status: models.ReturnStatusRequested, Note: data.Details}}
+		if err := tx.Create(&rma).Error; err != nil {
+			statusCode = 500
+			errData = utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create return")
+			return &errData
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &statusCode, &errData
+	}
+	return &rma, nil, nil
+}
+
+func (obj ReturnManager) preload(db *gorm.DB) *gorm.DB {
+	return db.Preload("Items").Preload("History", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	})
+}
+
+func (obj ReturnManager) GetById(db *gorm.DB, id uuid.UUID) (*models.ReturnRequest, *int, *utils.ErrorResponse) {
+	rma := models.ReturnRequest{}
+	obj.preload(db).Take(&rma, "id = ?", id)
+	if rma.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Return does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &rma, nil, nil
+}
+
+// GetForUser finds one of the user's own returns
+func (obj ReturnManager) GetForUser(db *gorm.DB, userId uuid.UUID, id uuid.UUID) (*models.ReturnRequest, *int, *utils.ErrorResponse) {
+	rma, errCode, errData := obj.GetById(db, id)
+	if errCode != nil {
+		return nil, errCode, errData
+	}
+	if rma.UserId != userId {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Return does not exist")
+		return nil, &statusCode, &errData
+	}
+	return rma, nil, nil
+}
+
+// GetAll lists returns, newest first. A nil userId lists everyone's and an
+// empty status lists every status.
+func (obj ReturnManager) GetAll(db *gorm.DB, userId *uuid.UUID, status string) []*models.ReturnRequest {
+	returns := []*models.ReturnRequest{}
+	query := obj.preload(db).Order("created_at DESC")
+	if userId != nil {
+		query = query.Where("user_id = ?", *userId)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Find(&returns)
+	return returns
+}
+
+// errReturnChanged means another request moved the return on first
+var errReturnChanged = errors.New("return was changed by another request")
+
+// transition moves the return on, saving its status with any other changed
+// columns and recording the step in its history. The update only applies
+// while the stored status is still the one the return was loaded with, so
+// of two concurrent requests only the first gets through.
+func (obj ReturnManager) transition(db *gorm.DB, rma *models.ReturnRequest, next models.ReturnStatus, actorId *uuid.UUID, note string, columns map[string]interface{}) error {
+	previous := rma.Status
+	change, err := rma.TransitionTo(next, actorId, note)
+	if err != nil {
+		return err
+	}
+	if columns == nil {
+		columns = map[string]interface{}{}
+	}
+	columns["status"] = next
+	result := db.Model(rma).Where("status = ?", previous).Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		rma.Status = previous
+		return errReturnChanged
+	}
+	if err := db.Create(change).Error; err != nil {
+		return err
+	}
+	rma.History = append(rma.History, *change)
+	return nil
+}
+
+// transitionFailed is the response for a transition that didn't go through
+func transitionFailed(err error, message string) (*int, *utils.ErrorResponse) {
+	if errors.Is(err, errReturnChanged) {
+		statusCode := 409
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Return was updated by someone else, reload it and try again")
+		return &statusCode, &errData
+	}
+	statusCode := 500
+	errData := utils.RequestErr(utils.ERR_SERVER_ERROR, message)
+	return &statusCode, &errData
+}
+
+// Approve accepts the return and, when asked, puts the items back into
+// stock. The refund itself goes through the payment provider afterwards.
+func (obj ReturnManager) Approve(db *gorm.DB, rma *models.ReturnRequest, actorId uuid.UUID, data schemas.ApproveReturn) (*models.ReturnRequest, *int, *utils.ErrorResponse) {
+	if !rma.Status.CanTransitionTo(models.ReturnStatusApproved) {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, fmt.Sprintf("Return is already %s", rma.Status))
+		return nil, &statusCode, &errData
+	}
+
+	var statusCode int
+	var errData utils.ErrorResponse
+	err := db.Transaction(func(tx *gorm.DB) error {
+		rma.Restock = data.Restock
+		if err := obj.transition(tx, rma, models.ReturnStatusApproved, &actorId, data.Note, map[string]interface{}{"restock": data.Restock}); err != nil {
+			errCode, transitionErr := transitionFailed(err, "Failed to approve return")
+			statusCode = *errCode
+			errData = *transitionErr
+			return &errData
+		}
+		if !data.Restock {
+			return nil
+		}
+		productManager := ProductManager{}
+		for _, item := range rma.Items {
+			if _, errCode, stockErr := productManager.UpdateStock(tx, item.ProductId, item.Quantity, models.StockMovementReturn, &actorId, &rma.OrderId); errCode != nil {
+				statusCode = *errCode
+				errData = *stockErr
+				return &errData
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &statusCode, &errData
+	}
+	return rma, nil, nil
+}
+
+func (obj ReturnManager) Reject(db *gorm.DB, rma *models.ReturnRequest, actorId uuid.UUID, note string) (*models.ReturnRequest, *int, *utils.ErrorResponse) {
+	if !rma.Status.CanTransitionTo(models.ReturnStatusRejected) {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, fmt.Sprintf("Return is already %s", rma.Status))
+		return nil, &statusCode, &errData
+	}
+	if err := obj.transition(db, rma, models.ReturnStatusRejected, &actorId, note, nil); err != nil {
+		errCode, errData := transitionFailed(err, "Failed to reject return")
+		return nil, errCode, errData
+	}
+	return rma, nil, nil
+}
+
+// MarkRefunded closes an approved return once the provider has taken the refund
+func (obj ReturnManager) MarkRefunded(db *gorm.DB, rma *models.ReturnRequest, refundId string, actorId uuid.UUID) (*models.ReturnRequest, *int, *utils.ErrorResponse) {
+	if !rma.Status.CanTransitionTo(models.ReturnStatusRefunded) {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, fmt.Sprintf("Return is %s and can't be refunded", rma.Status))
+		return nil, &statusCode, &errData
+	}
+	rma.RefundId = refundId
+	note := fmt.Sprintf("Refunded %s", rma.Refund)
+	if err := obj.transition(db, rma, models.ReturnStatusRefunded, &actorId, note, map[string]interface{}{"refund_id": refundId, "refund_amount": rma.Refund.Amount}); err != nil {
+		errCode, errData := transitionFailed(err, "Failed to update return")
+		return nil, errCode, errData
+	}
+	return rma, nil, nil
+}
@@ -0,0 +1,210 @@
+package managers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// SESSION MANAGEMENT
+// --------------------------------
+type SessionManager struct{}
+
+// sessionSeenInterval throttles last-seen writes, so an active device
+// doesn't update its session on every request
+const sessionSeenInterval = time.Minute
+
+func sessionExpiry() time.Time {
+	return time.Now().Add(time.Minute * time.Duration(config.GetConfig().RefreshTokenExpireMinutes))
+}
+
+// deviceLabel names a device from its user agent, e.g. "Firefox on macOS"
+func deviceLabel(userAgent string) string {
+	browser := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			browser = candidate.name
+			break
+		}
+	}
+	platform := ""
+	for _, candidate := range []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(userAgent, candidate.token) {
+			platform = candidate.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
+
+// Create starts a session for a device that has just signed in. The id is
+// chosen by the caller, since the first refresh token is bound to it.
+func (obj SessionManager) Create(db *gorm.DB, id uuid.UUID, userId uuid.UUID, ip string, userAgent string, refreshHash string) (*models.Session, *int, *utils.ErrorResponse) {
+	session := models.Session{
+		ID:          id,
+		UserId:      userId,
+		DeviceLabel: deviceLabel(userAgent),
+		IP:          truncate(ip, 45),
+		UserAgent:   truncate(userAgent, 500),
+		RefreshHash: refreshHash,
+		LastSeenAt:  time.Now(),
+		ExpiresAt:   sessionExpiry(),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to start session")
+		return nil, &statusCode, &errData
+	}
+	return &session, nil, nil
+}
+
+// GetActive returns the user's session if it hasn't been revoked or expired
+func (obj SessionManager) GetActive(db *gorm.DB, id uuid.UUID, userId uuid.UUID) *models.Session {
+	session := models.Session{}
+	db.Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", id, userId, time.Now()).Take(&session)
+	if session.ID == uuid.Nil {
+		return nil
+	}
+	return &session
+}
+
+// GetAll lists the user's live sessions, most recently used first
+func (obj SessionManager) GetAll(db *gorm.DB, userId uuid.UUID) []*models.Session {
+	sessions := []*models.Session{}
+	db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions)
+	return sessions
+}
+
+// GetForUser returns one of the user's live sessions, or a 404
+func (obj SessionManager) GetForUser(db *gorm.DB, userId uuid.UUID, id uuid.UUID) (*models.Session, *int, *utils.ErrorResponse) {
+	session := obj.GetActive(db, id, userId)
+	if session == nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Session does not exist")
+		return nil, &statusCode, &errData
+	}
+	return session, nil, nil
+}
+
+// Seen records that the session has just been used
+func (obj SessionManager) Seen(db *gorm.DB, session *models.Session, ip string) {
+	if time.Since(session.LastSeenAt) < sessionSeenInterval {
+		return
+	}
+	session.LastSeenAt = time.Now()
+	session.IP = truncate(ip, 45)
+	db.Model(session).Updates(map[string]interface{}{"last_seen_at": session.LastSeenAt, "ip": session.IP})
+}
+
+// Rotate swaps the presented refresh token for a newly issued one and
+// extends the session. Each session is a token family: a token that is
+// genuine but no longer current has already been rotated, so whoever sends
+// it is replaying a copy. The whole family is revoked and reused comes back
+// set, so the owner can be told.
+func (obj SessionManager) Rotate(db *gorm.DB, userId uuid.UUID, sessionId uuid.UUID, presentedHash string, refreshHash string) (*models.Session, bool, *int, *utils.ErrorResponse) {
+	session := models.Session{}
+	reused := false
+	var statusCode int
+	var errData utils.ErrorResponse
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Lock the session so two refreshes can't both rotate it
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", sessionId, userId).Take(&session)
+		if session.ID == uuid.Nil || !session.IsActive() {
+			statusCode = 401
+			errData = utils.RequestErr(utils.ERR_INVALID_TOKEN, "Refresh token is invalid or expired")
+			return &errData
+		}
+
+		if session.RefreshHash != presentedHash {
+			reused = true
+			now := time.Now()
+			session.RevokedAt = &now
+			tx.Model(&session).Update("revoked_at", now)
+			return nil
+		}
+
+		session.RefreshHash = refreshHash
+		session.LastSeenAt = time.Now()
+		session.ExpiresAt = sessionExpiry()
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"refresh_hash": session.RefreshHash,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+		}).Error
+	})
+	if err != nil {
+		if statusCode == 0 {
+			statusCode = 500
+			errData = utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to refresh session")
+		}
+		return nil, false, &statusCode, &errData
+	}
+	if reused {
+		statusCode = 401
+		errData = utils.RequestErr(utils.ERR_INVALID_TOKEN, "Refresh token has already been used. This session has been signed out")
+		return &session, true, &statusCode, &errData
+	}
+	return &session, false, nil, nil
+}
+
+// Revoke signs the session's device out
+func (obj SessionManager) Revoke(db *gorm.DB, session *models.Session) {
+	now := time.Now()
+	session.RevokedAt = &now
+	db.Model(session).Update("revoked_at", now)
+}
+
+// RevokeAll signs the user out on every device, apart from the session
+// passed as except, and returns how many were revoked
+func (obj SessionManager) RevokeAll(db *gorm.DB, userId uuid.UUID, except ...uuid.UUID) int64 {
+	query := db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userId)
+	if len(except) > 0 {
+		query = query.Where("id NOT IN ?", except)
+	}
+	return query.Update("revoked_at", time.Now()).RowsAffected
+}
+
+// PurgeExpired deletes sessions that can no longer be refreshed
+func (obj SessionManager) PurgeExpired(db *gorm.DB) int64 {
+	return db.Where("expires_at <= ?", time.Now()).Delete(&models.Session{}).RowsAffected
+}
@@ -0,0 +1,123 @@
+package managers
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// SHIPPING MANAGEMENT
+// --------------------------------
+type ShippingManager struct{}
+
+func applyShippingMethod(method *models.ShippingMethod, data schemas.CreateShippingMethod) {
+	countries := make([]string, 0, len(data.Countries))
+	for _, country := range data.Countries {
+		countries = append(countries, strings.ToUpper(country))
+	}
+
+	method.Name = data.Name
+	method.Description = data.Description
+	method.Price = models.NewMoney(data.Price, data.Currency)
+	method.FreeOver = data.FreeOver
+	method.MinDays = data.MinDays
+	method.MaxDays = data.MaxDays
+	method.Countries = strings.Join(countries, ",")
+	if data.IsActive != nil {
+		method.IsActive = *data.IsActive
+	}
+}
+
+func (obj ShippingManager) Create(db *gorm.DB, data schemas.CreateShippingMethod) (*models.ShippingMethod, *int, *utils.ErrorResponse) {
+	method := models.ShippingMethod{IsActive: true}
+	applyShippingMethod(&method, data)
+	if err := db.Create(&method).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create shipping method")
+		return nil, &statusCode, &errData
+	}
+	return &method, nil, nil
+}
+
+func (obj ShippingManager) Update(db *gorm.DB, method *models.ShippingMethod, data schemas.CreateShippingMethod) (*models.ShippingMethod, *int, *utils.ErrorResponse) {
+	applyShippingMethod(method, data)
+	if err := db.Save(method).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update shipping method")
+		return nil, &statusCode, &errData
+	}
+	return method, nil, nil
+}
+
+// GetAll lists shipping methods, optionally only the active ones that
+// deliver to a country
+func (obj ShippingManager) GetAll(db *gorm.DB, activeOnly bool, country string) []*models.ShippingMethod {
+	methods := []*models.ShippingMethod{}
+	query := db.Order("price_amount")
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	query.Find(&methods)
+
+	if country == "" {
+		return methods
+	}
+	available := []*models.ShippingMethod{}
+	for _, method := range methods {
+		if method.AllowsCountry(country) {
+			available = append(available, method)
+		}
+	}
+	return available
+}
+
+func (obj ShippingManager) GetById(db *gorm.DB, id uuid.UUID) (*models.ShippingMethod, *int, *utils.ErrorResponse) {
+	method := models.ShippingMethod{}
+	db.Take(&method, "id = ?", id)
+	if method.ID == uuid.Nil {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Shipping method does not exist")
+		return nil, &statusCode, &errData
+	}
+	return &method, nil, nil
+}
+
+func (obj ShippingManager) Delete(db *gorm.DB, method *models.ShippingMethod) (*int, *utils.ErrorResponse) {
+	if err := db.Delete(method).Error; err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to delete shipping method")
+		return &statusCode, &errData
+	}
+	return nil, nil
+}
+
+// Quote checks that the method can deliver to the address and prices it
+// for an order whose goods come to goodsTotal
+func (obj ShippingManager) Quote(db *gorm.DB, methodId uuid.UUID, address models.PostalAddress, goodsTotal models.Money) (*models.ShippingMethod, models.Money, *int, *utils.ErrorResponse) {
+	method, errCode, errData := obj.GetById(db, methodId)
+	if errCode != nil {
+		return nil, models.Money{}, errCode, errData
+	}
+	if !method.IsActive {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Shipping method is no longer available")
+		return nil, models.Money{}, &statusCode, &errData
+	}
+	if !method.AllowsCountry(address.Country) {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Shipping method doesn't deliver to "+address.Country)
+		return nil, models.Money{}, &statusCode, &errData
+	}
+	if !method.Price.SameCurrency(goodsTotal) {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Shipping method is priced in a different currency")
+		return nil, models.Money{}, &statusCode, &errData
+	}
+	return method, method.CostFor(goodsTotal), nil, nil
+}
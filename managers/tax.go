@@ -0,0 +1,164 @@
+package managers
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// TAX MANAGEMENT
+// --------------------------------
+type TaxManager struct{}
+
+// Mode is how catalogue prices are entered, set with TAX_MODE. Prices shown
+// to consumers include VAT, so inclusive is the default.
+func (obj TaxManager) Mode() models.TaxMode {
+	if models.TaxMode(strings.ToLower(config.GetConfig().TaxMode)) == models.TaxModeExclusive {
+		return models.TaxModeExclusive
+	}
+	return models.TaxModeInclusive
+}
+
+func (obj TaxManager) SetCategoryRate(db *gorm.DB, data schemas.SetTaxRate) (*models.TaxCategoryRate, *int, *utils.ErrorResponse) {
+	rate := models.TaxCategoryRate{Category: strings.ToLower(data.Category), Band: data.Band}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"band", "updated_at"}),
+	}).Create(&rate).Error
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to save tax rate")
+		return nil, &statusCode, &errData
+	}
+	db.Take(&rate, "category = ?", rate.Category)
+	return &rate, nil, nil
+}
+
+func (obj TaxManager) GetCategoryRates(db *gorm.DB) []*models.TaxCategoryRate {
+	rates := []*models.TaxCategoryRate{}
+	db.Order("category").Find(&rates)
+	return rates
+}
+
+func (obj TaxManager) DeleteCategoryRate(db *gorm.DB, category string) (*int, *utils.ErrorResponse) {
+	result := db.Where("category = ?", strings.ToLower(category)).Delete(&models.TaxCategoryRate{})
+	if result.Error != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to delete tax rate")
+		return &statusCode, &errData
+	}
+	if result.RowsAffected == 0 {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Tax rate does not exist")
+		return &statusCode, &errData
+	}
+	return nil, nil
+}
+
+// bands maps each of the given categories to its VAT band
+func (obj TaxManager) bands(db *gorm.DB, categories []string) map[string]models.VATBand {
+	bands := map[string]models.VATBand{}
+	if len(categories) == 0 {
+		return bands
+	}
+	rates := []models.TaxCategoryRate{}
+	db.Where("category IN ?", categories).Find(&rates)
+	for _, rate := range rates {
+		bands[rate.Category] = rate.Band
+	}
+	return bands
+}
+
+// Apply works out VAT for each line, for delivery and for the whole order.
+// Any order discount is spread over the lines the coupon covers in proportion
+// to their value, so VAT is charged on what the buyer actually pays. Orders
+// going outside the UK are zero rated exports. In exclusive mode the VAT is
+// added to the order total.
+func (obj TaxManager) Apply(db *gorm.DB, order *models.Order, mode models.TaxMode, coupon *models.Coupon) {
+	currency := order.Subtotal.Currency
+	order.TaxMode = mode
+	order.Tax = models.NewMoney(0, currency)
+	order.ShippingTax = models.NewMoney(0, currency)
+
+	productIds := []uuid.UUID{}
+	for _, item := range order.Items {
+		productIds = append(productIds, item.ProductId)
+	}
+	products := []models.Product{}
+	db.Unscoped().Select("id", "category").Where("id IN ?", productIds).Find(&products)
+	categoryOf := map[uuid.UUID]string{}
+	categories := []string{}
+	for _, product := range products {
+		category := strings.ToLower(product.Category)
+		categoryOf[product.ID] = category
+		categories = append(categories, category)
+	}
+	bands := obj.bands(db, categories)
+	export := !models.IsUKCountry(order.ShippingAddress.Country)
+
+	taxFor := func(amount models.Money, rate int64) models.Money {
+		if mode == models.TaxModeExclusive {
+			return models.TaxOn(amount, rate)
+		}
+		return models.TaxIncluded(amount, rate)
+	}
+
+	// Category limited coupons only discount the lines they cover
+	eligible := make([]bool, len(order.Items))
+	var eligibleSubtotal int64
+	lastEligible := -1
+	for i, item := range order.Items {
+		eligible[i] = coupon == nil || coupon.AppliesToCategory(categoryOf[item.ProductId])
+		if eligible[i] {
+			eligibleSubtotal += item.UnitPrice.Multiply(item.Quantity).Amount
+			lastEligible = i
+		}
+	}
+
+	var highestRate int64
+	discountLeft := order.Discount.Amount
+	for i := range order.Items {
+		item := &order.Items[i]
+		line := item.UnitPrice.Multiply(item.Quantity)
+
+		// The last eligible line takes what's left so the shares add up exactly
+		var share int64
+		if eligible[i] {
+			share = discountLeft
+			if i < lastEligible && eligibleSubtotal > 0 {
+				share = order.Discount.Amount * line.Amount / eligibleSubtotal
+			}
+			discountLeft -= share
+		}
+
+		band, ok := bands[categoryOf[item.ProductId]]
+		if !ok {
+			band = models.VATBandStandard
+		}
+		if export {
+			band = models.VATBandZero
+		}
+		item.TaxRate = band.Rate()
+		item.Tax = taxFor(models.NewMoney(line.Amount-share, currency), item.TaxRate)
+		order.Tax = order.Tax.Add(item.Tax)
+		if item.TaxRate > highestRate {
+			highestRate = item.TaxRate
+		}
+	}
+
+	// Delivery follows the goods, taking the highest rate in the order
+	order.ShippingTax = taxFor(order.Shipping, highestRate)
+	order.Tax = order.Tax.Add(order.ShippingTax)
+
+	if mode == models.TaxModeExclusive {
+		order.Total = order.Total.Add(order.Tax)
+	}
+}
@@ -0,0 +1,184 @@
+package managers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+)
+
+// ----------------------------------
+// TWO-FACTOR MANAGEMENT
+// --------------------------------
+type TotpManager struct{}
+
+// TotpIssuer is the account name shown in authenticator apps
+const TotpIssuer = "TechnoTrades"
+
+const recoveryCodeCount = 10
+
+// After this many wrong codes in a row two-factor is locked for a while, so
+// the six digits can't be guessed
+const (
+	totpMaxAttempts = 5
+	totpLockout     = 15 * time.Minute
+)
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// newRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func newRecoveryCode() string {
+	raw := make([]byte, 5)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	code := hex.EncodeToString(raw)
+	return code[:5] + "-" + code[5:]
+}
+
+// Setup gives the user a new secret to add to their authenticator. Two-factor
+// stays off until Confirm sees a code generated from it.
+func (obj TotpManager) Setup(db *gorm.DB, user *models.User) (string, *int, *utils.ErrorResponse) {
+	if user.TotpEnabled {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Two-factor authentication is already enabled")
+		return "", &statusCode, &errData
+	}
+	secret := utils.GenerateTotpSecret()
+	user.TotpSecret = &secret
+	user.TotpLastStep = 0
+	db.Model(user).Updates(map[string]interface{}{"totp_secret": secret, "totp_last_step": 0})
+	return secret, nil, nil
+}
+
+// Confirm turns two-factor on once the user proves their authenticator works,
+// and returns a fresh set of recovery codes. They are only ever shown here.
+func (obj TotpManager) Confirm(db *gorm.DB, user *models.User, code string) ([]string, *int, *utils.ErrorResponse) {
+	if user.TotpEnabled || user.TotpSecret == nil {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Start two-factor setup first")
+		return nil, &statusCode, &errData
+	}
+	step, ok := utils.ValidateTotp(*user.TotpSecret, code, user.TotpLastStep)
+	if !ok {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_INCORRECT_OTP, "Incorrect two-factor code")
+		return nil, &statusCode, &errData
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		for i := 0; i < recoveryCodeCount; i++ {
+			code := newRecoveryCode()
+			if err := tx.Create(&models.RecoveryCode{UserId: user.ID, CodeHash: hashRecoveryCode(code)}).Error; err != nil {
+				return err
+			}
+			codes = append(codes, code)
+		}
+		return tx.Model(user).Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error
+	})
+	if err != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to enable two-factor authentication")
+		return nil, &statusCode, &errData
+	}
+	user.TotpEnabled = true
+	user.TotpLastStep = step
+	return codes, nil, nil
+}
+
+// Verify accepts a code from the authenticator or one of the user's unused
+// recovery codes. Either can only be used once.
+func (obj TotpManager) Verify(db *gorm.DB, user *models.User, code string) (*int, *utils.ErrorResponse) {
+	if user.TotpEnabled && user.TotpSecret != nil {
+		if !obj.claimAttempt(db, user) {
+			statusCode := 429
+			errData := utils.RequestErr(utils.ERR_REQUEST_LIMIT, "Too many incorrect two-factor codes, try again later")
+			return &statusCode, &errData
+		}
+
+		if step, ok := utils.ValidateTotp(*user.TotpSecret, code, user.TotpLastStep); ok {
+			// Only the first request with this code gets to move the step on
+			result := db.Model(&models.User{}).
+				Where("id = ? AND totp_last_step < ?", user.ID, step).
+				Update("totp_last_step", step)
+			if result.RowsAffected == 1 {
+				user.TotpLastStep = step
+				obj.resetAttempts(db, user)
+				return nil, nil
+			}
+		}
+
+		result := db.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+			Update("used_at", time.Now())
+		if result.RowsAffected == 1 {
+			obj.resetAttempts(db, user)
+			return nil, nil
+		}
+	}
+	statusCode := 401
+	errData := utils.RequestErr(utils.ERR_INCORRECT_OTP, "Incorrect two-factor code")
+	return &statusCode, &errData
+}
+
+// claimAttempt counts the attempt before the code is checked, so guesses sent
+// in parallel can't get past the limit. It reports false while the user is
+// locked out.
+func (obj TotpManager) claimAttempt(db *gorm.DB, user *models.User) bool {
+	now := time.Now()
+
+	// A lockout that has run out starts the count again
+	db.Model(&models.User{}).Where("id = ? AND totp_locked_until <= ?", user.ID, now).
+		Updates(map[string]interface{}{"totp_attempts": 0, "totp_locked_until": nil})
+
+	var attempts int
+	db.Raw("UPDATE users SET totp_attempts = totp_attempts + 1 WHERE id = ? RETURNING totp_attempts", user.ID).Scan(&attempts)
+	user.TotpAttempts = attempts
+	if attempts > totpMaxAttempts {
+		return false
+	}
+	if attempts == totpMaxAttempts {
+		lockedUntil := now.Add(totpLockout)
+		user.TotpLockedUntil = &lockedUntil
+		db.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_locked_until", lockedUntil)
+	}
+	return true
+}
+
+func (obj TotpManager) resetAttempts(db *gorm.DB, user *models.User) {
+	user.TotpAttempts = 0
+	user.TotpLockedUntil = nil
+	db.Model(&models.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"totp_attempts": 0, "totp_locked_until": nil})
+}
+
+// Disable turns two-factor off and throws away the recovery codes
+func (obj TotpManager) Disable(db *gorm.DB, user *models.User, code string) (*int, *utils.ErrorResponse) {
+	if !user.TotpEnabled {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Two-factor authentication is not enabled")
+		return &statusCode, &errData
+	}
+	if errCode, errData := obj.Verify(db, user, code); errCode != nil {
+		return errCode, errData
+	}
+	db.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{})
+	db.Model(user).Updates(map[string]interface{}{"totp_enabled": false, "totp_secret": nil, "totp_last_step": 0, "totp_attempts": 0, "totp_locked_until": nil})
+	user.TotpEnabled = false
+	user.TotpSecret = nil
+	return nil, nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cart is a persisted basket. Prices aren't stored on it, they are resolved
+// when the cart is read or checked out. Guest carts have no user and are
+// found through a signed cookie until they're merged at login. UpdatedAt
+// moves whenever the items change, so it tells when the cart was last used.
+type Cart struct {
+	ID        uuid.UUID  `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	UserId    *uuid.UUID `json:"user_id" gorm:"type:uuid;unique"`
+	User      User       `json:"-" gorm:"foreignKey:UserId;constraint:OnDelete:CASCADE"`
+	Items     []CartItem `json:"items" gorm:"foreignKey:CartId;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"not null"`
+}
+
+type CartItem struct {
+	ID        uuid.UUID `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	CartId    uuid.UUID `json:"cart_id" gorm:"type:uuid;not null;uniqueIndex:idx_cart_product"`
+	ProductId uuid.UUID `json:"product_id" gorm:"type:uuid;not null;uniqueIndex:idx_cart_product"`
+	Product   Product   `json:"-" gorm:"foreignKey:ProductId;constraint:OnDelete:CASCADE"`
+	Quantity  int       `json:"quantity" gorm:"not null" example:"1"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null"`
+}
+
+// CartReminder is an abandoned cart email. It's marked converted, with the
+// order, when the customer goes on to pay within the recovery window.
+type CartReminder struct {
+	ID          uuid.UUID  `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	CartId      uuid.UUID  `json:"cart_id" gorm:"type:uuid;not null;index"`
+	Cart        Cart       `json:"-" gorm:"foreignKey:CartId;constraint:OnDelete:CASCADE"`
+	UserId      uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	OrderId     *uuid.UUID `json:"order_id" gorm:"type:uuid"`
+	ConvertedAt *time.Time `json:"converted_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"not null"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CouponType string
+
+const (
+	CouponPercentage CouponType = "percentage"
+	CouponFixed      CouponType = "fixed"
+)
+
+// Coupon is a promo code customers enter at checkout. Value is a whole
+// percentage for percentage coupons and an amount in minor units for fixed
+// ones. Zero limits mean unlimited and an empty category list means every
+// category is eligible.
+type Coupon struct {
+	ID             uuid.UUID  `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Code           string     `json:"code" gorm:"type:varchar(50);not null;unique" example:"WELCOME10"`
+	Type           CouponType `json:"type" gorm:"type:varchar(20);not null" example:"percentage"`
+	Value          int64      `json:"value" gorm:"not null" example:"10"`
+	Currency       string     `json:"currency" gorm:"type:varchar(3);not null;default:'gbp'" example:"gbp"`
+	MinBasket      int64      `json:"min_basket" gorm:"not null;default:0" example:"5000"`
+	MaxRedemptions int        `json:"max_redemptions" gorm:"not null;default:0" example:"100"`
+	MaxPerUser     int        `json:"max_per_user" gorm:"not null;default:0" example:"1"`
+	Redemptions    int        `json:"redemptions" gorm:"not null;default:0" example:"0"`
+	Categories     string     `json:"categories" gorm:"type:varchar(1000);not null;default:''" example:"consoles,laptops"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	IsActive       bool       `json:"is_active" gorm:"not null;default:true"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"not null"`
+}
+
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (c Coupon) IsExpired() bool {
+	return c.ExpiresAt != nil && time.Now().After(*c.ExpiresAt)
+}
+
+func (c Coupon) AppliesToCategory(category string) bool {
+	if c.Categories == "" {
+		return true
+	}
+	for _, eligible := range strings.Split(c.Categories, ",") {
+		if strings.EqualFold(strings.TrimSpace(eligible), category) {
+			return true
+		}
+	}
+	return false
+}
+
+type RedemptionStatus string
+
+const (
+	RedemptionRedeemed RedemptionStatus = "redeemed"
+	RedemptionReleased RedemptionStatus = "released"
+)
+
+// CouponRedemption ties a coupon use to the order it was applied to.
+// Released redemptions (from cancelled orders) don't count towards limits.
+type CouponRedemption struct {
+	ID        uuid.UUID        `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	CouponId  uuid.UUID        `json:"coupon_id" gorm:"type:uuid;not null;index"`
+	Coupon    Coupon           `json:"-" gorm:"foreignKey:CouponId;constraint:OnDelete:CASCADE"`
+	UserId    uuid.UUID        `json:"user_id" gorm:"type:uuid;not null;index"`
+	OrderId   uuid.UUID        `json:"order_id" gorm:"type:uuid;not null;unique"`
+	Order     Order            `json:"-" gorm:"foreignKey:OrderId;constraint:OnDelete:CASCADE"`
+	Discount  Money            `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Status    RedemptionStatus `json:"status" gorm:"type:varchar(20);not null;default:'redeemed';index" example:"redeemed"`
+	CreatedAt time.Time        `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time        `json:"updated_at" gorm:"not null"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyRecord keeps the first response to a request sent with an
+// Idempotency-Key header so a retry with the same key gets it replayed
+// instead of doing the work twice. StatusCode stays zero while the first
+// request is still running.
+type IdempotencyRecord struct {
+	ID          uuid.UUID `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	UserId      uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_scope"`
+	Key         string    `json:"key" gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_scope"`
+	Route       string    `json:"route" gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_scope" example:"POST /api/v1/stripe/create-checkout-session"`
+	RequestHash string    `json:"-" gorm:"type:varchar(64);not null"`
+	StatusCode  int       `json:"status_code" gorm:"not null;default:0"`
+	ContentType string    `json:"-" gorm:"type:varchar(255)"`
+	Body        []byte    `json:"-"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
+}
+
+func (r *IdempotencyRecord) IsCompleted() bool {
+	return r.StatusCode != 0
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type InvoiceType string
+
+const (
+	InvoiceTypeInvoice    InvoiceType = "invoice"
+	InvoiceTypeCreditNote InvoiceType = "credit_note"
+)
+
+// Prefix is what the type's numbers start with, each type has its own series
+func (t InvoiceType) Prefix() string {
+	if t == InvoiceTypeCreditNote {
+		return "CN"
+	}
+	return "INV"
+}
+
+// DocumentSequence hands out consecutive numbers for a series. The row is
+// locked while a number is taken and the document is written in the same
+// transaction, so a failed write gives its number back and the series has
+// no gaps.
+type DocumentSequence struct {
+	Series string `gorm:"type:varchar(20);primarykey"`
+	Next   int64  `gorm:"not null;default:1"`
+}
+
+// Invoice is an issued invoice or credit note. Amount and Tax are the gross
+// total and the VAT it contains; for credit notes they are what was given back.
+// An order has at most one invoice. The PDF is stored under FileKey once
+// StoredAt is set.
+type Invoice struct {
+	ID        uuid.UUID   `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Number    string      `json:"number" gorm:"type:varchar(20);not null;unique" example:"INV-000042"`
+	Type      InvoiceType `json:"type" gorm:"type:varchar(20);not null;index" example:"invoice"`
+	OrderId   uuid.UUID   `json:"order_id" gorm:"type:uuid;not null;index;uniqueIndex:idx_invoices_one_per_order,where:type = 'invoice'"`
+	Order     Order       `json:"-" gorm:"foreignKey:OrderId;constraint:OnDelete:CASCADE"`
+	UserId    uuid.UUID   `json:"user_id" gorm:"type:uuid;not null;index"`
+	Amount    Money       `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	Tax       Money       `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	FileKey   string      `json:"-" gorm:"type:varchar(255);not null"`
+	StoredAt  *time.Time  `json:"-"`
+	IssuedAt  time.Time   `json:"issued_at" gorm:"not null"`
+	CreatedAt time.Time   `json:"created_at" gorm:"not null"`
+}
+
+func FormatDocumentNumber(invoiceType InvoiceType, sequence int64) string {
+	return fmt.Sprintf("%s-%06d", invoiceType.Prefix(), sequence)
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MagicLink is an emailed sign-in link. It works once, until ExpiresAt, and
+// only in the browser holding the nonce it was requested with. Only hashes
+// of the token and nonce are stored.
+type MagicLink struct {
+	ID        uuid.UUID  `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	UserId    uuid.UUID  `json:"-" gorm:"type:uuid;not null;index"`
+	User      *User      `json:"-" gorm:"foreignKey:UserId;constraint:OnDelete:CASCADE;"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	NonceHash string     `json:"-" gorm:"type:varchar(64);not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DefaultCurrency = "gbp"
+
+// Money is an amount in the currency's minor unit (pence for gbp) together
+// with its lowercase ISO 4217 code. Embed it with a gorm prefix, e.g.
+// `gorm:"embedded;embeddedPrefix:price_"`, to get price_amount and
+// price_currency columns.
+type Money struct {
+	Amount   int64  `json:"amount" gorm:"not null;default:0" example:"39999"`
+	Currency string `json:"currency" gorm:"type:varchar(3);not null;default:'gbp'" example:"gbp"`
+}
+
+func NewMoney(amount int64, currency string) Money {
+	currency = strings.ToLower(currency)
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: currency}
+}
+
+func (m Money) Multiply(quantity int) Money {
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.Currency}
+}
+
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}
+}
+
+// Portion is numerator/denominator of the amount, rounded to the nearest minor unit
+func (m Money) Portion(numerator int64, denominator int64) Money {
+	return Money{Amount: divideRounded(m.Amount*numerator, denominator), Currency: m.Currency}
+}
+
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == other.Currency
+}
+
+// Decimal formats the amount in major units, e.g. 1999 -> "19.99"
+func (m Money) Decimal() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%s %s", m.Decimal(), strings.ToUpper(m.Currency))
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OrderStatus string
+
+const (
+	OrderStatusPending   OrderStatus = "pending"
+	OrderStatusPaid      OrderStatus = "paid"
+	OrderStatusFulfilled OrderStatus = "fulfilled"
+	OrderStatusShipped   OrderStatus = "shipped"
+	OrderStatusDelivered OrderStatus = "delivered"
+	OrderStatusCancelled OrderStatus = "cancelled"
+	OrderStatusRefunded  OrderStatus = "refunded"
+)
+
+// Allowed lifecycle transitions. Cancelled and Refunded are terminal.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:   {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:      {OrderStatusFulfilled, OrderStatusCancelled, OrderStatusRefunded},
+	OrderStatusFulfilled: {OrderStatusShipped, OrderStatusRefunded},
+	OrderStatusShipped:   {OrderStatusDelivered, OrderStatusRefunded},
+	OrderStatusDelivered: {OrderStatusRefunded},
+}
+
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusFulfilled, OrderStatusShipped,
+		OrderStatusDelivered, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+type PaymentStatus string
+
+const (
+	PaymentStatusUnpaid            PaymentStatus = "unpaid"
+	PaymentStatusFailed            PaymentStatus = "failed"
+	PaymentStatusPaid              PaymentStatus = "paid"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+)
+
+type Order struct {
+	ID                      uuid.UUID     `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	OrderNumber             string        `json:"order_number" gorm:"type:varchar(20);not null;unique" example:"TT-8F3A1C9B2E"`
+	UserId                  uuid.UUID     `json:"user_id" gorm:"type:uuid;not null;index"`
+	User                    User          `json:"-" gorm:"foreignKey:UserId"`
+	Status                  OrderStatus   `json:"status" gorm:"type:varchar(20);not null;default:'pending';index" example:"pending"`
+	Items                   []OrderItem   `json:"items" gorm:"foreignKey:OrderId;constraint:OnDelete:CASCADE"`
+	Subtotal                Money         `json:"subtotal" gorm:"embedded;embeddedPrefix:subtotal_"`
+	Discount                Money         `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	CouponCode              string        `json:"coupon_code,omitempty" gorm:"type:varchar(50)"`
+	ShippingMethodId        *uuid.UUID    `json:"shipping_method_id" gorm:"type:uuid"`
+	ShippingMethodName      string        `json:"shipping_method_name" gorm:"type:varchar(100)" example:"Next day"`
+	Shipping                Money         `json:"shipping" gorm:"embedded;embeddedPrefix:shipping_"`
+	ShippingAddress         PostalAddress `json:"shipping_address" gorm:"embedded;embeddedPrefix:ship_to_"`
+	ShippingTax             Money         `json:"shipping_tax" gorm:"embedded;embeddedPrefix:shipping_tax_"`
+	Tax                     Money         `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	TaxMode                 TaxMode       `json:"tax_mode" gorm:"type:varchar(20);not null;default:'inclusive'" example:"inclusive"`
+	BuyerCompany            string        `json:"buyer_company,omitempty" gorm:"type:varchar(255)"`
+	BuyerVATNumber          string        `json:"buyer_vat_number,omitempty" gorm:"type:varchar(20)"`
+	Total                   Money         `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+	StripeCheckoutSessionId *string       `json:"-" gorm:"type:varchar(255);index"`
+	StripePaymentIntentId   *string       `json:"-" gorm:"type:varchar(255);index"`
+	Refunded                Money         `json:"refunded" gorm:"embedded;embeddedPrefix:refunded_"`
+	LastPaymentError        string        `json:"last_payment_error,omitempty" gorm:"type:varchar(1000)"`
+	Carrier                 string        `json:"carrier,omitempty" gorm:"type:varchar(100)" example:"Royal Mail"`
+	TrackingNumber          string        `json:"tracking_number,omitempty" gorm:"type:varchar(100)" example:"RM123456785GB"`
+	ReservedUntil           *time.Time    `json:"reserved_until"`
+	PaidAt                  *time.Time    `json:"paid_at"`
+	FulfilledAt             *time.Time    `json:"fulfilled_at"`
+	ShippedAt               *time.Time    `json:"shipped_at"`
+	DeliveredAt             *time.Time    `json:"delivered_at"`
+	CancelledAt             *time.Time    `json:"cancelled_at"`
+	RefundedAt              *time.Time    `json:"refunded_at"`
+	CreatedAt               time.Time     `json:"created_at" gorm:"not null"`
+	UpdatedAt               time.Time     `json:"updated_at" gorm:"not null"`
+}
+
+type OrderItem struct {
+	ID        uuid.UUID `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	OrderId   uuid.UUID `json:"order_id" gorm:"type:uuid;not null;index"`
+	ProductId uuid.UUID `json:"product_id" gorm:"type:uuid;not null"`
+	Product   Product   `json:"-" gorm:"foreignKey:ProductId"`
+	Name      string    `json:"name" gorm:"type:varchar(255);not null" example:"Sony PlayStation 5"`
+	UnitPrice Money     `json:"unit_price" gorm:"embedded;embeddedPrefix:unit_price_"`
+	Quantity  int       `json:"quantity" gorm:"not null" example:"1"`
+	TaxRate   int64     `json:"tax_rate" gorm:"not null;default:0" example:"2000"` // basis points
+	Tax       Money     `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null"`
+}
+
+// OrderNote is an internal staff remark on an order, never shown to the customer
+type OrderNote struct {
+	ID        uuid.UUID `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	OrderId   uuid.UUID `json:"order_id" gorm:"type:uuid;not null;index"`
+	AuthorId  uuid.UUID `json:"author_id" gorm:"type:uuid;not null"`
+	Body      string    `json:"body" gorm:"type:varchar(2000);not null" example:"Customer asked for a signed-for delivery"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+}
+
+// PaymentStatus sums up where the order's money is
+func (o *Order) PaymentStatus() PaymentStatus {
+	switch {
+	case o.PaidAt != nil && o.Refunded.Amount > 0 && o.Refunded.Amount >= o.Total.Amount:
+		return PaymentStatusRefunded
+	case o.PaidAt != nil && o.Refunded.Amount > 0:
+		return PaymentStatusPartiallyRefunded
+	case o.PaidAt != nil:
+		return PaymentStatusPaid
+	case o.LastPaymentError != "":
+		return PaymentStatusFailed
+	}
+	return PaymentStatusUnpaid
+}
+
+// IsCancellable tells whether the buyer can still cancel, which is until the
+// order starts being fulfilled
+func (o *Order) IsCancellable() bool {
+	return o.Status == OrderStatusPending || o.Status == OrderStatusPaid
+}
+
+// TransitionTo moves the order to the next status if the lifecycle allows it
+// and stamps the matching timestamp.
+func (o *Order) TransitionTo(next OrderStatus) error {
+	if !o.Status.CanTransitionTo(next) {
+		return fmt.Errorf("cannot move order from %s to %s", o.Status, next)
+	}
+
+	now := time.Now()
+	switch next {
+	case OrderStatusPaid:
+		o.PaidAt = &now
+	case OrderStatusFulfilled:
+		o.FulfilledAt = &now
+	case OrderStatusShipped:
+		o.ShippedAt = &now
+	case OrderStatusDelivered:
+		o.DeliveredAt = &now
+	case OrderStatusCancelled:
+		o.CancelledAt = &now
+	case OrderStatusRefunded:
+		o.RefundedAt = &now
+	}
+	o.Status = next
+	return nil
+}
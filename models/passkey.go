@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Passkey is a WebAuthn credential a user can sign in with instead of a
+// password. SignCount is the authenticator's counter from the last use, so
+// a cloned key replaying an older count can be spotted.
+type Passkey struct {
+	ID              uuid.UUID  `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	UserId          uuid.UUID  `json:"-" gorm:"type:uuid;not null;index"`
+	User            *User      `json:"-" gorm:"foreignKey:UserId;constraint:OnDelete:CASCADE;"`
+	Name            string     `json:"name" gorm:"type:varchar(100);not null" example:"MacBook Touch ID"`
+	CredentialID    []byte     `json:"-" gorm:"not null;uniqueIndex"`
+	PublicKey       []byte     `json:"-" gorm:"not null"`
+	AttestationType string     `json:"-" gorm:"type:varchar(50)"`
+	AAGUID          []byte     `json:"-"`
+	Transports      string     `json:"-" gorm:"type:varchar(255)"`
+	SignCount       uint32     `json:"-" gorm:"not null;default:0"`
+	BackupEligible  bool       `json:"backup_eligible" gorm:"default:false"`
+	BackupState     bool       `json:"backup_state" gorm:"default:false"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"not null"`
+}
+
+type PasskeyCeremonyKind string
+
+const (
+	PasskeyCeremonyRegistration PasskeyCeremonyKind = "registration"
+	PasskeyCeremonySignup       PasskeyCeremonyKind = "signup"
+	PasskeyCeremonyLogin        PasskeyCeremonyKind = "login"
+)
+
+// PasskeyCeremony holds the challenge of a registration or login between
+// its begin and finish requests. Each one can only be finished once. For
+// signups, Signup keeps the new account's details until the passkey is made.
+type PasskeyCeremony struct {
+	ID        uuid.UUID           `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Kind      PasskeyCeremonyKind `json:"kind" gorm:"type:varchar(20);not null"`
+	UserId    *uuid.UUID          `json:"-" gorm:"type:uuid"`
+	Session   []byte              `json:"-" gorm:"not null"`
+	Signup    []byte              `json:"-"`
+	ExpiresAt time.Time           `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time           `json:"created_at" gorm:"not null"`
+}
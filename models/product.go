@@ -23,10 +23,11 @@ type Product struct {
 	Category        string    `gorm:"size:255;not null"`
 	Description     string    `gorm:"type:text"`
 	Rating          float64   `gorm:"default:0;not null"`
-	Price           float64   `gorm:"not null"`
+	Price           Money     `gorm:"embedded;embeddedPrefix:price_"`
 	CountInStock    int       `gorm:"not null"`
 	IsDiscounted    bool      `gorm:"default:false;not null"`
-	DiscountedPrice float64   `gorm:"default:0"`
+	DiscountedPrice Money     `gorm:"embedded;embeddedPrefix:discounted_price_"`
+	EffectivePrice  Money     `gorm:"-"` // What a unit costs right now, set by the pricing manager
 	UserID          uuid.UUID `gorm:"type:uuid;not null"`
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PromotionType string
+
+const (
+	PromotionPercentage PromotionType = "percentage"
+	PromotionFixed      PromotionType = "fixed"
+)
+
+type PromotionTarget string
+
+const (
+	PromotionTargetProduct  PromotionTarget = "product"
+	PromotionTargetBrand    PromotionTarget = "brand"
+	PromotionTargetCategory PromotionTarget = "category"
+)
+
+type PromotionStatus string
+
+const (
+	PromotionScheduled PromotionStatus = "scheduled"
+	PromotionActive    PromotionStatus = "active"
+	PromotionExpired   PromotionStatus = "expired"
+)
+
+// Promotion is a time-boxed price rule. Value is a whole percentage for
+// percentage promotions and an amount in minor units for fixed ones.
+type Promotion struct {
+	ID          uuid.UUID       `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Name        string          `json:"name" gorm:"type:varchar(255);not null" example:"Black Friday"`
+	Type        PromotionType   `json:"type" gorm:"type:varchar(20);not null" example:"percentage"`
+	Value       int64           `json:"value" gorm:"not null" example:"20"`
+	Currency    string          `json:"currency" gorm:"type:varchar(3);not null;default:'gbp'" example:"gbp"`
+	TargetType  PromotionTarget `json:"target_type" gorm:"type:varchar(20);not null;index:idx_promotion_target" example:"category"`
+	Target      string          `json:"target" gorm:"type:varchar(255);not null;index:idx_promotion_target" example:"consoles"`
+	Status      PromotionStatus `json:"status" gorm:"type:varchar(20);not null;default:'scheduled';index" example:"scheduled"`
+	StartsAt    time.Time       `json:"starts_at" gorm:"not null;index" example:"2024-11-29T00:00:00Z"`
+	EndsAt      time.Time       `json:"ends_at" gorm:"not null;index" example:"2024-12-02T23:59:59Z"`
+	CreatedById uuid.UUID       `json:"created_by_id" gorm:"type:uuid;not null"`
+	CreatedAt   time.Time       `json:"created_at" gorm:"not null"`
+	UpdatedAt   time.Time       `json:"updated_at" gorm:"not null"`
+}
+
+// StatusAt is the status the promotion should have at the given time
+func (p Promotion) StatusAt(now time.Time) PromotionStatus {
+	switch {
+	case now.Before(p.StartsAt):
+		return PromotionScheduled
+	case now.Before(p.EndsAt):
+		return PromotionActive
+	}
+	return PromotionExpired
+}
+
+func (p Promotion) AppliesTo(product *Product) bool {
+	switch p.TargetType {
+	case PromotionTargetProduct:
+		return p.Target == product.ID.String()
+	case PromotionTargetBrand:
+		return strings.EqualFold(p.Target, product.Brand)
+	case PromotionTargetCategory:
+		return strings.EqualFold(p.Target, product.Category)
+	}
+	return false
+}
+
+// Apply returns the price after the promotion, never below zero. Fixed
+// promotions only apply to prices in their own currency.
+func (p Promotion) Apply(price Money) Money {
+	var off int64
+	switch p.Type {
+	case PromotionPercentage:
+		off = price.Amount * p.Value / 100
+	case PromotionFixed:
+		if p.Currency != price.Currency {
+			return price
+		}
+		off = p.Value
+	}
+	if off > price.Amount {
+		off = price.Amount
+	}
+	return Money{Amount: price.Amount - off, Currency: price.Currency}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"
+	ReservationConverted ReservationStatus = "converted"
+	ReservationReleased  ReservationStatus = "released"
+)
+
+// StockReservation holds stock for a pending order until it is paid,
+// cancelled or the hold expires.
+type StockReservation struct {
+	ID        uuid.UUID         `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	ProductId uuid.UUID         `json:"product_id" gorm:"type:uuid;not null;index"`
+	Product   Product           `json:"-" gorm:"foreignKey:ProductId;constraint:OnDelete:CASCADE"`
+	OrderId   uuid.UUID         `json:"order_id" gorm:"type:uuid;not null;index"`
+	Order     Order             `json:"-" gorm:"foreignKey:OrderId;constraint:OnDelete:CASCADE"`
+	Quantity  int               `json:"quantity" gorm:"not null" example:"1"`
+	Status    ReservationStatus `json:"status" gorm:"type:varchar(20);not null;default:'active';index" example:"active"`
+	ExpiresAt time.Time         `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time         `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time         `json:"updated_at" gorm:"not null"`
+}
+
+func (obj StockReservation) CheckExpiration() bool {
+	return time.Now().After(obj.ExpiresAt)
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReturnWindow is how long after delivery a customer can ask to return items
+const ReturnWindow = 30 * 24 * time.Hour
+
+type ReturnStatus string
+
+const (
+	ReturnStatusRequested ReturnStatus = "requested"
+	ReturnStatusApproved  ReturnStatus = "approved"
+	ReturnStatusRejected  ReturnStatus = "rejected"
+	ReturnStatusRefunded  ReturnStatus = "refunded"
+)
+
+// Approved returns stay approved until the refund goes through, so a failed
+// refund can be retried. Rejected and Refunded are terminal.
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnStatusRequested: {ReturnStatusApproved, ReturnStatusRejected},
+	ReturnStatusApproved:  {ReturnStatusRefunded},
+}
+
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range returnTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+type ReturnReason string
+
+const (
+	ReturnReasonDamaged        ReturnReason = "damaged"
+	ReturnReasonDefective      ReturnReason = "defective"
+	ReturnReasonWrongItem      ReturnReason = "wrong_item"
+	ReturnReasonNotAsDescribed ReturnReason = "not_as_described"
+	ReturnReasonNoLongerNeeded ReturnReason = "no_longer_needed"
+	ReturnReasonOther          ReturnReason = "other"
+)
+
+// ReturnRequest is a return merchandise authorisation (RMA) for some of the
+// lines of a delivered order
+type ReturnRequest struct {
+	ID        uuid.UUID            `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	RMANumber string               `json:"rma_number" gorm:"type:varchar(20);not null;unique" example:"RMA-8F3A1C9B2E"`
+	OrderId   uuid.UUID            `json:"order_id" gorm:"type:uuid;not null;index"`
+	Order     Order                `json:"-" gorm:"foreignKey:OrderId;constraint:OnDelete:CASCADE"`
+	UserId    uuid.UUID            `json:"user_id" gorm:"type:uuid;not null;index"`
+	User      User                 `json:"-" gorm:"foreignKey:UserId;constraint:OnDelete:CASCADE"`
+	Status    ReturnStatus         `json:"status" gorm:"type:varchar(20);not null;default:'requested';index" example:"requested"`
+	Reason    ReturnReason         `json:"reason" gorm:"type:varchar(30);not null" example:"damaged"`
+	Details   string               `json:"details" gorm:"type:varchar(1000)" example:"The box arrived crushed"`
+	Items     []ReturnItem         `json:"items" gorm:"foreignKey:ReturnId;constraint:OnDelete:CASCADE"`
+	History   []ReturnStatusChange `json:"history" gorm:"foreignKey:ReturnId;constraint:OnDelete:CASCADE"`
+	Refund    Money                `json:"refund" gorm:"embedded;embeddedPrefix:refund_"`
+	RefundId  string               `json:"refund_id,omitempty" gorm:"type:varchar(255)"`
+	Restock   bool                 `json:"restock" gorm:"not null;default:false"`
+	CreatedAt time.Time            `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time            `json:"updated_at" gorm:"not null"`
+}
+
+type ReturnItem struct {
+	ID          uuid.UUID `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	ReturnId    uuid.UUID `json:"return_id" gorm:"type:uuid;not null;index"`
+	OrderItemId uuid.UUID `json:"order_item_id" gorm:"type:uuid;not null;index"`
+	ProductId   uuid.UUID `json:"product_id" gorm:"type:uuid;not null"`
+	Name        string    `json:"name" gorm:"type:varchar(255);not null" example:"Sony PlayStation 5"`
+	Quantity    int       `json:"quantity" gorm:"not null" example:"1"`
+}
+
+// ReturnStatusChange is one step in a return's history. ActorId is empty
+// for changes the customer made.
+type ReturnStatusChange struct {
+	ID        uuid.UUID    `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	ReturnId  uuid.UUID    `json:"return_id" gorm:"type:uuid;not null;index"`
+	Status    ReturnStatus `json:"status" gorm:"type:varchar(20);not null" example:"approved"`
+	ActorId   *uuid.UUID   `json:"actor_id" gorm:"type:uuid"`
+	Note      string       `json:"note" gorm:"type:varchar(1000)"`
+	CreatedAt time.Time    `json:"created_at" gorm:"not null"`
+}
+
+// TransitionTo moves the return on and returns the history entry to store
+func (r *ReturnRequest) TransitionTo(next ReturnStatus, actorId *uuid.UUID, note string) (*ReturnStatusChange, error) {
+	if !r.Status.CanTransitionTo(next) {
+		return nil, fmt.Errorf("cannot move return from %s to %s", r.Status, next)
+	}
+	r.Status = next
+	return &ReturnStatusChange{ReturnId: r.ID, Status: next, ActorId: actorId, Note: note}, nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is one signed in device. Access tokens carry the session id, so
+// revoking a session signs that device out without touching the others.
+// Only a hash of the current refresh token is kept.
+type Session struct {
+	ID          uuid.UUID  `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	UserId      uuid.UUID  `json:"-" gorm:"type:uuid;not null;index"`
+	User        *User      `json:"-" gorm:"foreignKey:UserId;constraint:OnDelete:CASCADE;"`
+	DeviceLabel string     `json:"device_label" gorm:"type:varchar(255);not null" example:"Chrome on Windows"`
+	IP          string     `json:"ip" gorm:"type:varchar(45)" example:"203.0.113.7"`
+	UserAgent   string     `json:"user_agent" gorm:"type:varchar(500)"`
+	RefreshHash string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	LastSeenAt  time.Time  `json:"last_seen_at" gorm:"not null"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null;index"`
+	RevokedAt   *time.Time `json:"-"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"not null"`
+}
+
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShippingMethod is a delivery option staff offer at checkout. Countries is
+// a comma separated list of ISO 3166-1 alpha-2 codes. A non-zero FreeOver
+// makes the method free once the goods total reaches it.
+type ShippingMethod struct {
+	ID          uuid.UUID `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Name        string    `json:"name" gorm:"type:varchar(100);not null" example:"Next day"`
+	Description string    `json:"description" gorm:"type:varchar(500)" example:"Order before 2pm"`
+	Price       Money     `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	FreeOver    int64     `json:"free_over" gorm:"not null;default:0" example:"5000"`
+	MinDays     int       `json:"min_days" gorm:"not null" example:"1"`
+	MaxDays     int       `json:"max_days" gorm:"not null" example:"1"`
+	Countries   string    `json:"countries" gorm:"type:varchar(1000);not null" example:"GB,IE"`
+	IsActive    bool      `json:"is_active" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"not null"`
+}
+
+func (m ShippingMethod) AllowsCountry(country string) bool {
+	for _, allowed := range strings.Split(m.Countries, ",") {
+		if strings.EqualFold(strings.TrimSpace(allowed), country) {
+			return true
+		}
+	}
+	return false
+}
+
+// CostFor is the shipping charge on an order whose goods come to goodsTotal
+func (m ShippingMethod) CostFor(goodsTotal Money) Money {
+	if m.FreeOver > 0 && goodsTotal.SameCurrency(m.Price) && goodsTotal.Amount >= m.FreeOver {
+		return NewMoney(0, m.Price.Currency)
+	}
+	return m.Price
+}
+
+// PostalAddress is the part of an address a parcel needs. Orders keep a copy
+// so later edits to the address book don't rewrite past deliveries.
+type PostalAddress struct {
+	FullName   string `json:"full_name" gorm:"type:varchar(255)" example:"John Doe"`
+	Line1      string `json:"line1" gorm:"type:varchar(255)" example:"221B Baker Street"`
+	Line2      string `json:"line2" gorm:"type:varchar(255)" example:"Flat 2"`
+	City       string `json:"city" gorm:"type:varchar(100)" example:"London"`
+	Region     string `json:"region" gorm:"type:varchar(100)" example:"Greater London"`
+	PostalCode string `json:"postal_code" gorm:"type:varchar(20)" example:"NW1 6XE"`
+	Country    string `json:"country" gorm:"type:varchar(2)" example:"GB"`
+	Phone      string `json:"phone" gorm:"type:varchar(30)" example:"+447700900123"`
+}
+
+type Address struct {
+	ID            uuid.UUID `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	UserId        uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	User          User      `json:"-" gorm:"foreignKey:UserId;constraint:OnDelete:CASCADE"`
+	PostalAddress `gorm:"embedded"`
+	IsDefault     bool      `json:"is_default" gorm:"not null;default:false"`
+	CreatedAt     time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"not null"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type StockMovementReason string
+
+const (
+	StockMovementRestock     StockMovementReason = "restock"
+	StockMovementSale        StockMovementReason = "sale"
+	StockMovementReturn      StockMovementReason = "return"
+	StockMovementAdjustment  StockMovementReason = "adjustment"
+	StockMovementReservation StockMovementReason = "reservation"
+)
+
+// StockMovement is an append-only ledger entry for a product's stock.
+// Reservation entries track held stock and don't change CountInStock.
+type StockMovement struct {
+	ID        uuid.UUID           `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	ProductId uuid.UUID           `json:"product_id" gorm:"type:uuid;not null;index"`
+	Product   Product             `json:"-" gorm:"foreignKey:ProductId;constraint:OnDelete:CASCADE"`
+	Delta     int                 `json:"delta" gorm:"not null" example:"-2"`
+	Reason    StockMovementReason `json:"reason" gorm:"type:varchar(20);not null;index" example:"sale"`
+	ActorId   *uuid.UUID          `json:"actor_id" gorm:"type:uuid"`
+	OrderId   *uuid.UUID          `json:"order_id" gorm:"type:uuid;index"`
+	CreatedAt time.Time           `json:"created_at" gorm:"not null;index"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// StripeEvent records webhook events that have already been processed so
+// that Stripe's at-least-once delivery doesn't apply the same event twice.
+type StripeEvent struct {
+	ID        string    `json:"id" gorm:"type:varchar(255);primarykey" example:"evt_1NG8Du2eZvKYlo2CUI79vXWy"`
+	Type      string    `json:"type" gorm:"type:varchar(255);not null" example:"checkout.session.completed"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+}
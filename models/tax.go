@@ -0,0 +1,91 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VATBand is a UK VAT rate band. Rates are held in basis points so tax is
+// worked out in whole pence without floats.
+type VATBand string
+
+const (
+	VATBandStandard VATBand = "standard"
+	VATBandReduced  VATBand = "reduced"
+	VATBandZero     VATBand = "zero"
+)
+
+func (b VATBand) IsValid() bool {
+	switch b {
+	case VATBandStandard, VATBandReduced, VATBandZero:
+		return true
+	}
+	return false
+}
+
+// Rate is the band's rate in basis points, 2000 being 20%
+func (b VATBand) Rate() int64 {
+	switch b {
+	case VATBandReduced:
+		return 500
+	case VATBandZero:
+		return 0
+	}
+	return 2000
+}
+
+type TaxMode string
+
+const (
+	// Prices already include VAT, which is extracted for the breakdown
+	TaxModeInclusive TaxMode = "inclusive"
+	// Prices are net and VAT is added on top
+	TaxModeExclusive TaxMode = "exclusive"
+)
+
+// TaxCategoryRate puts a product category in a VAT band. Categories without
+// a row are charged at the standard rate.
+type TaxCategoryRate struct {
+	ID        uuid.UUID `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Category  string    `json:"category" gorm:"type:varchar(255);not null;unique" example:"books"`
+	Band      VATBand   `json:"band" gorm:"type:varchar(20);not null" example:"zero"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"not null"`
+}
+
+// TaxIncluded is the VAT contained in a gross amount
+func TaxIncluded(gross Money, rate int64) Money {
+	return NewMoney(divideRounded(gross.Amount*rate, 10000+rate), gross.Currency)
+}
+
+// TaxOn is the VAT due on top of a net amount
+func TaxOn(net Money, rate int64) Money {
+	return NewMoney(divideRounded(net.Amount*rate, 10000), net.Currency)
+}
+
+// divideRounded divides rounding halves away from zero, as HMRC allows for
+// line level VAT
+func divideRounded(numerator int64, denominator int64) int64 {
+	if numerator < 0 {
+		return -divideRounded(-numerator, denominator)
+	}
+	return (numerator*2 + denominator) / (denominator * 2)
+}
+
+// NormalizeVATNumber stores VAT numbers the way HMRC prints them, without
+// spacing, e.g. "gb 123 4567 89" -> "GB123456789"
+func NormalizeVATNumber(number string) string {
+	return strings.ToUpper(strings.ReplaceAll(number, " ", ""))
+}
+
+// IsUKCountry tells whether goods shipped to the country are domestic
+// supplies. Anything else leaves the UK as a zero rated export.
+func IsUKCountry(country string) bool {
+	switch strings.ToUpper(country) {
+	case "", "GB", "UK", "IM":
+		return true
+	}
+	return false
+}
@@ -13,6 +13,7 @@ type AuthType string
 const (
 	AuthTypePassword AuthType = "Password"
 	AuthTypeGoogle   AuthType = "Google"
+	AuthTypePasskey  AuthType = "Passkey"
 )
 
 type AccountType string
@@ -23,22 +24,35 @@ const (
 )
 
 type User struct {
-	ID              uuid.UUID      `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
-	FirstName       string         `json:"first_name" gorm:"type: varchar(255);not null" example:"John"`
-	LastName        string         `json:"last_name" gorm:"type: varchar(255);not null" example:"Doe"`
-	Email           string         `json:"email" gorm:"not null;unique;" example:"johndoe@email.com"`
-	Avatar          *string        `json:"avatar" gorm:"nullable"`
-	Password        string         `json:"password" gorm:"not null"`
-	IsEmailVerified bool           `json:"-" gorm:"default:false"`
-	AuthType        AuthType       `json:"authType" gorm:"type:varchar(50);default:'Password'"`
-	AccountType     AccountType    `json:"accountType" gorm:"type:varchar(50);default:'Buyer'"`
-	Active          bool           `json:"-" gorm:"default:true"`
-	Access          *string        `gorm:"type:varchar(1000);null;" json:"-"`
-	Refresh         *string        `gorm:"type:varchar(1000);null;" json:"-"`
-	Products        []Product      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;"`
-	CreatedAt       time.Time      `json:"created_at" gorm:"not null"`
-	UpdatedAt       time.Time      `json:"updated_at" gorm:"not null"`
-	DeletedAt       gorm.DeletedAt `gorm:"index"`
+	ID               uuid.UUID      `json:"id,omitempty" gorm:"type:uuid;primarykey;not null;default:uuid_generate_v4()" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	FirstName        string         `json:"first_name" gorm:"type: varchar(255);not null" example:"John"`
+	LastName         string         `json:"last_name" gorm:"type: varchar(255);not null" example:"Doe"`
+	Email            string         `json:"email" gorm:"not null;unique;" example:"johndoe@email.com"`
+	Avatar           *string        `json:"avatar" gorm:"nullable"`
+	Password         string         `json:"password" gorm:"not null"`
+	IsEmailVerified  bool           `json:"-" gorm:"default:false"`
+	AuthType         AuthType       `json:"authType" gorm:"type:varchar(50);default:'Password'"`
+	AccountType      AccountType    `json:"accountType" gorm:"type:varchar(50);default:'Buyer'"`
+	CompanyName      string         `json:"company_name" gorm:"type:varchar(255);default:''"`
+	VATNumber        string         `json:"vat_number" gorm:"type:varchar(20);default:''"`
+	StripeCustomerID *string        `json:"-" gorm:"type:varchar(255);unique"`
+	MarketingOptOut  bool           `json:"marketing_opt_out" gorm:"default:false"`
+	Active           bool           `json:"-" gorm:"default:true"`
+	TotpSecret       *string        `json:"-" gorm:"type:varchar(64)"`
+	TotpEnabled      bool           `json:"totp_enabled" gorm:"default:false"`
+	TotpLastStep     int64          `json:"-" gorm:"not null;default:0"`
+	TotpAttempts     int            `json:"-" gorm:"not null;default:0"`
+	TotpLockedUntil  *time.Time     `json:"-"`
+	Products         []Product      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;"`
+	CreatedAt        time.Time      `json:"created_at" gorm:"not null"`
+	UpdatedAt        time.Time      `json:"updated_at" gorm:"not null"`
+	DeletedAt        gorm.DeletedAt `gorm:"index"`
+}
+
+// RequiresTotp reports whether the account has to have two-factor
+// authentication turned on. Staff can be made to when STAFF_REQUIRE_2FA is set.
+func (u *User) RequiresTotp(staffRequired bool) bool {
+	return staffRequired && u.AccountType == AccountTypeStaff
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
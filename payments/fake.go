@@ -0,0 +1,159 @@
+package payments
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/webhook"
+)
+
+// FakeWebhookSecret signs webhook fixtures accepted by FakeProvider
+const FakeWebhookSecret = "whsec_fake"
+
+// FakeProvider is an in-process PaymentProvider for tests. It hands out
+// sequential IDs and records every call so assertions can inspect them.
+type FakeProvider struct {
+	mu               sync.Mutex
+	counter          int
+	customerKeys     map[string]string
+	refundKeys       map[string]*Refund
+	Customers        []CustomerParams
+	CustomerUpdates  map[string]CustomerParams
+	PaymentMethods   map[string][]PaymentMethod
+	Checkouts        []CheckoutParams
+	Intents          []IntentParams
+	CancelledIntents []string
+	// Intents that went through, so they can't be cancelled
+	SucceededIntents map[string]bool
+	Refunds          []RefundParams
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		customerKeys:     map[string]string{},
+		refundKeys:       map[string]*Refund{},
+		CustomerUpdates:  map[string]CustomerParams{},
+		PaymentMethods:   map[string][]PaymentMethod{},
+		SucceededIntents: map[string]bool{},
+	}
+}
+
+func (p *FakeProvider) nextId(prefix string) string {
+	p.counter++
+	return fmt.Sprintf("%s_fake_%d", prefix, p.counter)
+}
+
+func (p *FakeProvider) CreateCustomer(params CustomerParams) (*Customer, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id, ok := p.customerKeys[params.IdempotencyKey]; ok && params.IdempotencyKey != "" {
+		return &Customer{ID: id}, nil
+	}
+	p.Customers = append(p.Customers, params)
+	id := p.nextId("cus")
+	if params.IdempotencyKey != "" {
+		p.customerKeys[params.IdempotencyKey] = id
+	}
+	return &Customer{ID: id}, nil
+}
+
+func (p *FakeProvider) UpdateCustomer(customerID string, params CustomerParams) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.CustomerUpdates[customerID] = params
+	return nil
+}
+
+// AddPaymentMethod saves a card against the customer, as Stripe would after a payment
+func (p *FakeProvider) AddPaymentMethod(customerID string, brand string, last4 string) PaymentMethod {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	method := PaymentMethod{ID: p.nextId("pm"), Type: "card", Brand: brand, Last4: last4, ExpMonth: 12, ExpYear: 2030}
+	p.PaymentMethods[customerID] = append(p.PaymentMethods[customerID], method)
+	return method
+}
+
+func (p *FakeProvider) ListPaymentMethods(customerID string) ([]PaymentMethod, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PaymentMethod{}, p.PaymentMethods[customerID]...), nil
+}
+
+func (p *FakeProvider) DetachPaymentMethod(customerID string, paymentMethodID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	methods := p.PaymentMethods[customerID]
+	for i, method := range methods {
+		if method.ID == paymentMethodID {
+			p.PaymentMethods[customerID] = append(methods[:i], methods[i+1:]...)
+			return nil
+		}
+	}
+	return ErrPaymentMethodNotFound
+}
+
+func (p *FakeProvider) CreateCheckout(params CheckoutParams) (*Checkout, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Checkouts = append(p.Checkouts, params)
+	id := p.nextId("cs")
+	return &Checkout{ID: id, URL: fmt.Sprintf("https://checkout.fake/%s", id)}, nil
+}
+
+func (p *FakeProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Intents = append(p.Intents, params)
+	id := p.nextId("pi")
+	return &Intent{ID: id, ClientSecret: fmt.Sprintf("%s_secret", id)}, nil
+}
+
+func (p *FakeProvider) CancelIntent(paymentIntentID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.SucceededIntents[paymentIntentID] {
+		return ErrIntentNotCancellable
+	}
+	p.CancelledIntents = append(p.CancelledIntents, paymentIntentID)
+	return nil
+}
+
+// SucceedIntent marks the intent as paid, as Stripe would once the customer pays
+func (p *FakeProvider) SucceedIntent(paymentIntentID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.SucceededIntents[paymentIntentID] = true
+}
+
+func (p *FakeProvider) Refund(params RefundParams) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if refund, ok := p.refundKeys[params.IdempotencyKey]; ok && params.IdempotencyKey != "" {
+		return refund, nil
+	}
+	p.Refunds = append(p.Refunds, params)
+	refund := &Refund{ID: p.nextId("re"), Amount: params.Amount, Status: "succeeded"}
+	if params.IdempotencyKey != "" {
+		p.refundKeys[params.IdempotencyKey] = refund
+	}
+	return refund, nil
+}
+
+func (p *FakeProvider) VerifyWebhook(payload []byte, signature string) (stripe.Event, error) {
+	return webhook.ConstructEvent(payload, signature, FakeWebhookSecret)
+}
+
+// LastCheckout returns the parameters of the most recent checkout
+func (p *FakeProvider) LastCheckout() CheckoutParams {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Checkouts[len(p.Checkouts)-1]
+}
+
+// LastIntent returns the parameters of the most recent payment intent
+func (p *FakeProvider) LastIntent() IntentParams {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Intents[len(p.Intents)-1]
+}
@@ -0,0 +1,150 @@
+package payments
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stripe/stripe-go/v81"
+)
+
+type CustomerParams struct {
+	Name     string
+	Email    string
+	Metadata map[string]string
+	// Retries with the same key get the customer made the first time
+	IdempotencyKey string
+}
+
+type Customer struct {
+	ID string
+}
+
+// PaymentMethod is a card or wallet saved against a customer
+type PaymentMethod struct {
+	ID       string
+	Type     string
+	Brand    string
+	Last4    string
+	ExpMonth int64
+	ExpYear  int64
+}
+
+// ErrIntentNotCancellable is returned when a payment intent can no longer be cancelled
+var ErrIntentNotCancellable = errors.New("payment intent can no longer be cancelled")
+
+// ErrPaymentMethodNotFound is returned when a payment method doesn't belong to the customer
+var ErrPaymentMethodNotFound = errors.New("payment method not found")
+
+type LineItem struct {
+	Name       string
+	UnitAmount int64
+	Quantity   int64
+	Metadata   map[string]string
+}
+
+// ShippingAddress is where the order is delivered to
+type ShippingAddress struct {
+	Name       string
+	Phone      string
+	Line1      string
+	Line2      string
+	City       string
+	State      string
+	PostalCode string
+	Country    string
+}
+
+// ShippingRate is the delivery charge the buyer picked before checkout
+type ShippingRate struct {
+	Name    string
+	Amount  int64
+	MinDays int
+	MaxDays int
+}
+
+// Stripe only accepts checkout expiries between 30 minutes and 24 hours out.
+// The lower bound keeps a minute spare for the time the request takes to arrive.
+const (
+	MinCheckoutExpiry = 31 * time.Minute
+	MaxCheckoutExpiry = 24 * time.Hour
+)
+
+// CheckoutExpiry clamps the wanted expiry into the window Stripe accepts
+func CheckoutExpiry(expiresAt time.Time) time.Time {
+	now := time.Now()
+	if earliest := now.Add(MinCheckoutExpiry); expiresAt.Before(earliest) {
+		return earliest
+	}
+	if latest := now.Add(MaxCheckoutExpiry); expiresAt.After(latest) {
+		return latest
+	}
+	return expiresAt
+}
+
+type CheckoutParams struct {
+	CustomerID        string
+	Currency          string
+	LineItems         []LineItem
+	SuccessURL        string
+	CancelURL         string
+	ClientReferenceID string
+	Metadata          map[string]string
+	PaymentMetadata   map[string]string
+	// The session stops accepting payment once the stock hold lapses
+	ExpiresAt time.Time
+	// Order-level discount in minor units, shown against the line items
+	DiscountAmount int64
+	DiscountName   string
+	Shipping       *ShippingAddress
+	ShippingRate   *ShippingRate
+}
+
+type Checkout struct {
+	ID  string
+	URL string
+}
+
+type IntentParams struct {
+	CustomerID         string
+	Amount             int64
+	Currency           string
+	PaymentMethodTypes []string
+	Metadata           map[string]string
+	Shipping           *ShippingAddress
+}
+
+type Intent struct {
+	ID           string
+	ClientSecret string
+}
+
+type RefundParams struct {
+	PaymentIntentID string
+	// Amount in minor units, zero refunds the full charge
+	Amount   int64
+	Metadata map[string]string
+	// Retries with the same key get the refund made the first time
+	IdempotencyKey string
+}
+
+type Refund struct {
+	ID     string
+	Amount int64
+	Status string
+}
+
+// PaymentProvider is everything checkout needs from the payment processor.
+// Webhook events keep Stripe's event shape since that's what we receive.
+type PaymentProvider interface {
+	CreateCustomer(params CustomerParams) (*Customer, error)
+	UpdateCustomer(customerID string, params CustomerParams) error
+	ListPaymentMethods(customerID string) ([]PaymentMethod, error)
+	DetachPaymentMethod(customerID string, paymentMethodID string) error
+	CreateCheckout(params CheckoutParams) (*Checkout, error)
+	CreateIntent(params IntentParams) (*Intent, error)
+	// CancelIntent stops a payment intent from being paid. It fails once
+	// the payment has gone through or is still processing.
+	CancelIntent(paymentIntentID string) error
+	Refund(params RefundParams) (*Refund, error)
+	VerifyWebhook(payload []byte, signature string) (stripe.Event, error)
+}
@@ -0,0 +1,244 @@
+package payments
+
+import (
+	"errors"
+
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/client"
+	"github.com/stripe/stripe-go/v81/webhook"
+)
+
+type StripeProvider struct {
+	api           *client.API
+	webhookSecret string
+}
+
+func NewStripeProvider(secretKey string, webhookSecret string) *StripeProvider {
+	return &StripeProvider{
+		api:           client.New(secretKey, nil),
+		webhookSecret: webhookSecret,
+	}
+}
+
+func (p *StripeProvider) CreateCustomer(params CustomerParams) (*Customer, error) {
+	customerParams := &stripe.CustomerParams{
+		Name:     stripe.String(params.Name),
+		Email:    stripe.String(params.Email),
+		Metadata: params.Metadata,
+	}
+	if params.IdempotencyKey != "" {
+		customerParams.SetIdempotencyKey(params.IdempotencyKey)
+	}
+	customer, err := p.api.Customers.New(customerParams)
+	if err != nil {
+		return nil, err
+	}
+	return &Customer{ID: customer.ID}, nil
+}
+
+func (p *StripeProvider) UpdateCustomer(customerID string, params CustomerParams) error {
+	_, err := p.api.Customers.Update(customerID, &stripe.CustomerParams{
+		Name:     stripe.String(params.Name),
+		Email:    stripe.String(params.Email),
+		Metadata: params.Metadata,
+	})
+	return err
+}
+
+func (p *StripeProvider) ListPaymentMethods(customerID string) ([]PaymentMethod, error) {
+	methods := []PaymentMethod{}
+	iter := p.api.Customers.ListPaymentMethods(&stripe.CustomerListPaymentMethodsParams{
+		Customer: stripe.String(customerID),
+	})
+	for iter.Next() {
+		methods = append(methods, paymentMethodFromStripe(iter.PaymentMethod()))
+	}
+	return methods, iter.Err()
+}
+
+func (p *StripeProvider) DetachPaymentMethod(customerID string, paymentMethodID string) error {
+	// Only the customer's own methods may be removed
+	pm, err := p.api.PaymentMethods.Get(paymentMethodID, nil)
+	if err != nil {
+		var stripeErr *stripe.Error
+		if errors.As(err, &stripeErr) && stripeErr.HTTPStatusCode == 404 {
+			return ErrPaymentMethodNotFound
+		}
+		return err
+	}
+	if pm.Customer == nil || pm.Customer.ID != customerID {
+		return ErrPaymentMethodNotFound
+	}
+	_, err = p.api.PaymentMethods.Detach(paymentMethodID, nil)
+	return err
+}
+
+func paymentMethodFromStripe(pm *stripe.PaymentMethod) PaymentMethod {
+	method := PaymentMethod{ID: pm.ID, Type: string(pm.Type)}
+	if pm.Card != nil {
+		method.Brand = string(pm.Card.Brand)
+		method.Last4 = pm.Card.Last4
+		method.ExpMonth = pm.Card.ExpMonth
+		method.ExpYear = pm.Card.ExpYear
+	}
+	return method
+}
+
+func (p *StripeProvider) CreateCheckout(params CheckoutParams) (*Checkout, error) {
+	var lineItems []*stripe.CheckoutSessionLineItemParams
+	for _, item := range params.LineItems {
+		lineItems = append(lineItems, &stripe.CheckoutSessionLineItemParams{
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency: stripe.String(params.Currency),
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name:     stripe.String(item.Name),
+					Metadata: item.Metadata,
+				},
+				UnitAmount: stripe.Int64(item.UnitAmount),
+			},
+			Quantity: stripe.Int64(item.Quantity),
+		})
+	}
+
+	sessionParams := &stripe.CheckoutSessionParams{
+		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
+		LineItems:          lineItems,
+		Mode:               stripe.String(string(stripe.CheckoutSessionModePayment)),
+		Customer:           stripe.String(params.CustomerID),
+		SuccessURL:         stripe.String(params.SuccessURL),
+		CancelURL:          stripe.String(params.CancelURL),
+		ClientReferenceID:  stripe.String(params.ClientReferenceID),
+		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+			Metadata: params.PaymentMetadata,
+		},
+		Metadata: params.Metadata,
+	}
+	if !params.ExpiresAt.IsZero() {
+		sessionParams.ExpiresAt = stripe.Int64(params.ExpiresAt.Unix())
+	}
+	if params.DiscountAmount > 0 {
+		// Our coupons are resolved on our side, Stripe gets a single use
+		// coupon for the exact amount so the session total matches the order
+		coupon, err := p.api.Coupons.New(&stripe.CouponParams{
+			Name:           stripe.String(params.DiscountName),
+			AmountOff:      stripe.Int64(params.DiscountAmount),
+			Currency:       stripe.String(params.Currency),
+			Duration:       stripe.String(string(stripe.CouponDurationOnce)),
+			MaxRedemptions: stripe.Int64(1),
+		})
+		if err != nil {
+			return nil, err
+		}
+		sessionParams.Discounts = []*stripe.CheckoutSessionDiscountParams{{Coupon: stripe.String(coupon.ID)}}
+	}
+
+	if params.Shipping != nil {
+		sessionParams.PaymentIntentData.Shipping = &stripe.ShippingDetailsParams{
+			Name:    stripe.String(params.Shipping.Name),
+			Phone:   stripe.String(params.Shipping.Phone),
+			Address: stripeAddress(params.Shipping),
+		}
+	}
+	if params.ShippingRate != nil {
+		rate := &stripe.CheckoutSessionShippingOptionShippingRateDataParams{
+			DisplayName: stripe.String(params.ShippingRate.Name),
+			Type:        stripe.String("fixed_amount"),
+			FixedAmount: &stripe.CheckoutSessionShippingOptionShippingRateDataFixedAmountParams{
+				Amount:   stripe.Int64(params.ShippingRate.Amount),
+				Currency: stripe.String(params.Currency),
+			},
+		}
+		if params.ShippingRate.MaxDays > 0 {
+			rate.DeliveryEstimate = &stripe.CheckoutSessionShippingOptionShippingRateDataDeliveryEstimateParams{
+				Minimum: &stripe.CheckoutSessionShippingOptionShippingRateDataDeliveryEstimateMinimumParams{
+					Unit:  stripe.String("business_day"),
+					Value: stripe.Int64(int64(params.ShippingRate.MinDays)),
+				},
+				Maximum: &stripe.CheckoutSessionShippingOptionShippingRateDataDeliveryEstimateMaximumParams{
+					Unit:  stripe.String("business_day"),
+					Value: stripe.Int64(int64(params.ShippingRate.MaxDays)),
+				},
+			}
+		}
+		sessionParams.ShippingOptions = []*stripe.CheckoutSessionShippingOptionParams{{ShippingRateData: rate}}
+	}
+
+	session, err := p.api.CheckoutSessions.New(sessionParams)
+	if err != nil {
+		return nil, err
+	}
+	return &Checkout{ID: session.ID, URL: session.URL}, nil
+}
+
+func stripeAddress(address *ShippingAddress) *stripe.AddressParams {
+	return &stripe.AddressParams{
+		Line1:      stripe.String(address.Line1),
+		Line2:      stripe.String(address.Line2),
+		City:       stripe.String(address.City),
+		State:      stripe.String(address.State),
+		PostalCode: stripe.String(address.PostalCode),
+		Country:    stripe.String(address.Country),
+	}
+}
+
+func (p *StripeProvider) CreateIntent(params IntentParams) (*Intent, error) {
+	intentParams := &stripe.PaymentIntentParams{
+		Amount:             stripe.Int64(params.Amount),
+		Customer:           stripe.String(params.CustomerID),
+		Currency:           stripe.String(params.Currency),
+		PaymentMethodTypes: stripe.StringSlice(params.PaymentMethodTypes),
+		Metadata:           params.Metadata,
+	}
+	if params.Shipping != nil {
+		intentParams.Shipping = &stripe.ShippingDetailsParams{
+			Name:    stripe.String(params.Shipping.Name),
+			Phone:   stripe.String(params.Shipping.Phone),
+			Address: stripeAddress(params.Shipping),
+		}
+	}
+	pi, err := p.api.PaymentIntents.New(intentParams)
+	if err != nil {
+		return nil, err
+	}
+	return &Intent{ID: pi.ID, ClientSecret: pi.ClientSecret}, nil
+}
+
+func (p *StripeProvider) CancelIntent(paymentIntentID string) error {
+	_, err := p.api.PaymentIntents.Cancel(paymentIntentID, nil)
+	var stripeErr *stripe.Error
+	if err == nil || !errors.As(err, &stripeErr) || stripeErr.Code != stripe.ErrorCodePaymentIntentUnexpectedState {
+		return err
+	}
+
+	// Already cancelled is what we wanted, paid or processing isn't
+	pi, err := p.api.PaymentIntents.Get(paymentIntentID, nil)
+	if err != nil {
+		return err
+	}
+	if pi.Status != stripe.PaymentIntentStatusCanceled {
+		return ErrIntentNotCancellable
+	}
+	return nil
+}
+
+func (p *StripeProvider) Refund(params RefundParams) (*Refund, error) {
+	refundParams := &stripe.RefundParams{
+		PaymentIntent: stripe.String(params.PaymentIntentID),
+		Metadata:      params.Metadata,
+	}
+	if params.Amount > 0 {
+		refundParams.Amount = stripe.Int64(params.Amount)
+	}
+	if params.IdempotencyKey != "" {
+		refundParams.SetIdempotencyKey(params.IdempotencyKey)
+	}
+	refund, err := p.api.Refunds.New(refundParams)
+	if err != nil {
+		return nil, err
+	}
+	return &Refund{ID: refund.ID, Amount: refund.Amount, Status: string(refund.Status)}, nil
+}
+
+func (p *StripeProvider) VerifyWebhook(payload []byte, signature string) (stripe.Event, error) {
+	return webhook.ConstructEvent(payload, signature, p.webhookSecret)
+}
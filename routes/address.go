@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	addressManager = managers.AddressManager{}
+)
+
+func (endpoint Endpoint) GetMyAddresses(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	addresses := addressManager.GetAll(db, user.ID)
+
+	response := schemas.AddressesResponseSchema{
+		ResponseSchema: SuccessResponse("Addresses fetched successfully"),
+		Data:           schemas.AddressesDataSchema{Addresses: addresses, Length: len(addresses)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) CreateAddress(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.AddressSchema{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	address, errCode, errData := addressManager.Create(db, user.ID, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.AddressResponseSchema{
+		ResponseSchema: SuccessResponse("Address created successfully"),
+		Data:           schemas.AddressDataSchema{Address: address},
+	}
+	return c.Status(201).JSON(response)
+}
+
+func (endpoint Endpoint) UpdateAddress(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.AddressSchema{}
+
+	addressId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	address, errCode, errData := addressManager.GetForUser(db, user.ID, *addressId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	address, errCode, errData = addressManager.Update(db, address, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.AddressResponseSchema{
+		ResponseSchema: SuccessResponse("Address updated successfully"),
+		Data:           schemas.AddressDataSchema{Address: address},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) DeleteAddress(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	addressId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	address, errCode, errData := addressManager.GetForUser(db, user.ID, *addressId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if errCode, errData := addressManager.Delete(db, address); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	return c.Status(200).JSON(SuccessResponse("Address deleted successfully"))
+}
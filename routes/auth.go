@@ -10,8 +10,53 @@ import (
 	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// completeLogin is where every login method ends up once the user has proved
+// who they are. Users with two-factor turned on get a short-lived mfa token
+// to trade for their tokens at /auth/2fa/verify instead.
+func completeLogin(c *fiber.Ctx, db *gorm.DB, user *models.User, message string) error {
+	if user.TotpEnabled {
+		response := schemas.MfaPendingResponseSchema{
+			ResponseSchema: SuccessResponse("Two-factor code required"),
+			Data:           schemas.MfaPendingSchema{MfaRequired: true, MfaToken: auth.GenerateMfaToken(user.ID)},
+		}
+		return c.Status(200).JSON(response)
+	}
+	return startSession(c, db, user, message)
+}
+
+// startSession signs in a fully authenticated user: it starts a session for
+// the device, issues its tokens and claims any guest cart the browser was
+// building
+func startSession(c *fiber.Ctx, db *gorm.DB, user *models.User, message string) error {
+	// Create Auth Tokens
+	sessionId := uuid.New()
+	refresh := auth.GenerateRefreshToken(user.ID, sessionId)
+	access := auth.GenerateAccessToken(user.ID, sessionId)
+	// The tokens are only good while their session exists, so none are handed out without it
+	if _, errCode, errData := sessionManager.Create(db, sessionId, user.ID, c.IP(), c.Get(fiber.HeaderUserAgent), auth.HashRefreshToken(refresh)); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	// Set the access token and refresh token cookies
+	auth.SetAuthCookie(c, auth.AccessToken, access)
+	auth.SetAuthCookie(c, auth.RefreshToken, refresh)
+
+	// Bring the guest cart over into the user's own
+	if cartId, ok := auth.DecodeGuestCartToken(c.Cookies(string(auth.GuestCart))); ok {
+		cartManager.MergeGuest(db, *cartId, user.ID)
+		auth.RemoveGuestCartCookie(c)
+	}
+
+	response := schemas.LoginResponseSchema{
+		ResponseSchema: SuccessResponse(message),
+		Data:           schemas.TokensResponseSchema{User: user, Access: access, Refresh: refresh},
+	}
+	return c.Status(201).JSON(response)
+}
+
 func (endpoint Endpoint) Login(c *fiber.Ctx) error {
 	db := endpoint.DB
 	reqData := schemas.LoginSchema{}
@@ -31,31 +76,13 @@ func (endpoint Endpoint) Login(c *fiber.Ctx) error {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_UNVERIFIED_USER, "Verify your email first"))
 	}
 
-	// Create Auth Tokens
-	access := auth.GenerateAccessToken(user.ID)
-	refresh := auth.GenerateRefreshToken()
-
-	user.Access = &access
-	user.Refresh = &refresh
-	db.Save(&user)
-
-	// Set the access token and refresh token cookies
-	auth.SetAuthCookie(c, auth.AccessToken, access)
-	auth.SetAuthCookie(c, auth.RefreshToken, refresh)
-
-	response := schemas.LoginResponseSchema{
-		ResponseSchema: SuccessResponse("Login successful"),
-		Data:           schemas.TokensResponseSchema{User: &user, Access: access, Refresh: refresh},
-	}
-	return c.Status(201).JSON(response)
+	return completeLogin(c, db, &user, "Login successful")
 }
 
 func (endpoint Endpoint) Logout(c *fiber.Ctx) error {
 	db := endpoint.DB
-	user := RequestUser(c)
-	user.Access = nil
-	user.Refresh = nil
-	db.Save(user)
+	// Only this device is signed out
+	sessionManager.Revoke(db, RequestSession(c))
 
 	// Remove the access token cookie
 	auth.RemoveAuthCookie(c, auth.AccessToken)
@@ -176,7 +203,7 @@ func (endpoint Endpoint) ValidateMe(c *fiber.Ctx) error {
 		return c.Status(404).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, "Invalid Token"))
 	}
 
-	user, err := auth.DecodeAccessToken(accessToken, db)
+	user, _, err := auth.DecodeAccessToken(accessToken, db)
 	if err != nil {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_CREDENTIALS, "Invalid Credentials"))
 	}
@@ -191,9 +218,10 @@ func (endpoint Endpoint) ValidateMe(c *fiber.Ctx) error {
 	return c.Status(200).JSON(response)
 }
 
+// Refresh trades a refresh token for a new pair. It needs no access token,
+// since the access token has usually expired by the time this is called.
 func (endpoint Endpoint) Refresh(c *fiber.Ctx) error {
 	db := endpoint.DB
-	user := RequestUser(c)
 	reqData := schemas.RefreshTokenRequestSchema{}
 
 	// Validate request
@@ -201,18 +229,30 @@ func (endpoint Endpoint) Refresh(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
-	token := reqData.Refresh
-	if !auth.DecodeRefreshToken(token) {
+	claims, ok := auth.DecodeRefreshToken(reqData.Refresh)
+	if !ok {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, "Refresh token is invalid or expired"))
+	}
+
+	user := models.User{ID: claims.UserId}
+	db.Take(&user, user)
+	if user.ID == uuid.Nil {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, "Refresh token is invalid or expired"))
 	}
 
 	// Create Auth Tokens
-	access := auth.GenerateAccessToken(user.ID)
-	refresh := auth.GenerateRefreshToken()
+	access := auth.GenerateAccessToken(user.ID, claims.SessionId)
+	refresh := auth.GenerateRefreshToken(user.ID, claims.SessionId)
 
-	user.Access = &access
-	user.Refresh = &refresh
-	db.Save(&user)
+	session, reused, errCode, errData := sessionManager.Rotate(db, user.ID, claims.SessionId, auth.HashRefreshToken(reqData.Refresh), auth.HashRefreshToken(refresh))
+	if reused {
+		go senders.SendSessionAlert(&user, session)
+	}
+	if errCode != nil {
+		auth.RemoveAuthCookie(c, auth.AccessToken)
+		auth.RemoveAuthCookie(c, auth.RefreshToken)
+		return c.Status(*errCode).JSON(errData)
+	}
 
 	// Set the access token and refresh token cookies
 	auth.SetAuthCookie(c, auth.AccessToken, access)
@@ -220,7 +260,7 @@ func (endpoint Endpoint) Refresh(c *fiber.Ctx) error {
 
 	response := schemas.LoginResponseSchema{
 		ResponseSchema: SuccessResponse("Tokens refresh successful"),
-		Data:           schemas.TokensResponseSchema{User: user, Access: access, Refresh: refresh},
+		Data:           schemas.TokensResponseSchema{User: &user, Access: access, Refresh: refresh},
 	}
 	return c.Status(201).JSON(response)
 }
@@ -276,9 +316,17 @@ func (endpoint Endpoint) SetNewPassword(c *fiber.Ctx) error {
 	}
 
 	// Update Users Password & Delete Otp
-	db.Model(&user).Updates(map[string]interface{}{"Password": data.Password})
+	updates := map[string]interface{}{"Password": data.Password}
+	if user.AuthType == models.AuthTypePasskey {
+		// Passkey accounts can sign in with the password from now on
+		updates["AuthType"] = models.AuthTypePassword
+	}
+	db.Model(&user).Updates(updates)
 	db.Delete(&otp)
 
+	// Whoever had the old password is signed out everywhere
+	sessionManager.RevokeAll(db, user.ID)
+
 	go senders.SendEmail(&user, senders.EmailResetPasswordSuccess, nil)
 
 	return c.Status(200).JSON(SuccessResponse("Password reset successful"))
@@ -338,21 +386,5 @@ func (endpoint Endpoint) LoginWithOtp(c *fiber.Ctx) error {
 
 	db.Delete(&otp)
 
-	// Create Auth Tokens
-	access := auth.GenerateAccessToken(user.ID)
-	refresh := auth.GenerateRefreshToken()
-
-	user.Access = &access
-	user.Refresh = &refresh
-	db.Save(&user)
-
-	// Set the access token and refresh token cookies
-	auth.SetAuthCookie(c, auth.AccessToken, access)
-	auth.SetAuthCookie(c, auth.RefreshToken, refresh)
-
-	response := schemas.LoginResponseSchema{
-		ResponseSchema: SuccessResponse("Logged in successfully"),
-		Data:           schemas.TokensResponseSchema{User: &user, Access: access, Refresh: refresh},
-	}
-	return c.Status(201).JSON(response)
+	return completeLogin(c, db, &user, "Logged in successfully")
 }
@@ -0,0 +1,138 @@
+package routes
+
+import (
+	auth "github.com/DanSmirnov48/techno-trades-go-backend/authentication"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	cartManager = managers.CartManager{}
+)
+
+// requestCart finds the cart of the signed in user, or the guest cart named
+// by the signed cookie. A guest cart is only started when create is set.
+func requestCart(c *fiber.Ctx, db *gorm.DB, create bool) (*models.Cart, *int, *utils.ErrorResponse) {
+	if user := OptionalRequestUser(c); user != nil {
+		return cartManager.GetOrCreate(db, user.ID)
+	}
+
+	if cartId, ok := auth.DecodeGuestCartToken(c.Cookies(string(auth.GuestCart))); ok {
+		if cart := cartManager.GetGuest(db, *cartId); cart != nil {
+			return cart, nil, nil
+		}
+	}
+	if !create {
+		return &models.Cart{Items: []models.CartItem{}}, nil, nil
+	}
+
+	cart, errCode, errData := cartManager.CreateGuest(db)
+	if errCode == nil {
+		auth.SetGuestCartCookie(c, cart.ID)
+	}
+	return cart, errCode, errData
+}
+
+func cartResponse(c *fiber.Ctx, db *gorm.DB, cart *models.Cart, message string) error {
+	response := schemas.CartResponseSchema{
+		ResponseSchema: SuccessResponse(message),
+		Data:           cartManager.Revalidate(db, cart),
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) GetCart(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	cart, errCode, errData := requestCart(c, db, false)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	return cartResponse(c, db, cart, "Cart fetched successfully")
+}
+
+func (endpoint Endpoint) AddCartItem(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.AddCartItem{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	productId, err := utils.ParseUUID(reqData.ProductID)
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	cart, errCode, errData := requestCart(c, db, true)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	cart, errCode, errData = cartManager.AddItem(db, cart, *productId, reqData.Quantity)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	return cartResponse(c, db, cart, "Item added to cart")
+}
+
+func (endpoint Endpoint) UpdateCartItem(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.UpdateCartItem{}
+
+	productId, err := utils.ParseUUID(c.Params("productId"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	cart, errCode, errData := requestCart(c, db, false)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	cart, errCode, errData = cartManager.SetQuantity(db, cart, *productId, reqData.Quantity)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	return cartResponse(c, db, cart, "Cart updated successfully")
+}
+
+func (endpoint Endpoint) RemoveCartItem(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	productId, err := utils.ParseUUID(c.Params("productId"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	cart, errCode, errData := requestCart(c, db, false)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	cart, errCode, errData = cartManager.RemoveItem(db, cart, *productId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	return cartResponse(c, db, cart, "Item removed from cart")
+}
+
+func (endpoint Endpoint) ClearCart(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	cart, errCode, errData := requestCart(c, db, false)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	cart = cartManager.Clear(db, cart)
+	return cartResponse(c, db, cart, "Cart cleared successfully")
+}
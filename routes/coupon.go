@@ -0,0 +1,148 @@
+package routes
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	couponManager = managers.CouponManager{}
+)
+
+// pricedItems resolves the requested products into order items at their
+// current effective price
+func pricedItems(db *gorm.DB, items []schemas.CartItemSchema) ([]models.OrderItem, *int, *utils.ErrorResponse) {
+	var orderItems []models.OrderItem
+	for _, item := range items {
+		productId, err := utils.ParseUUID(item.ProductID)
+		if err != nil {
+			statusCode := 400
+			return nil, &statusCode, err
+		}
+
+		product, errCode, errData := productManager.GetById(db, *productId)
+		if errCode != nil {
+			return nil, errCode, errData
+		}
+
+		orderItems = append(orderItems, models.OrderItem{
+			ProductId: product.ID,
+			Name:      product.Name,
+			UnitPrice: product.EffectivePrice,
+			Quantity:  item.Quantity,
+		})
+	}
+	return orderItems, nil, nil
+}
+
+func (endpoint Endpoint) CreateCoupon(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.CreateCoupon{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	coupon, errCode, errData := couponManager.Create(db, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.CouponResponseSchema{
+		ResponseSchema: SuccessResponse("Coupon created successfully"),
+		Data:           schemas.CouponDataSchema{Coupon: coupon},
+	}
+	return c.Status(201).JSON(response)
+}
+
+func (endpoint Endpoint) GetAllCoupons(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	coupons := couponManager.GetAll(db)
+
+	response := schemas.CouponsResponseSchema{
+		ResponseSchema: SuccessResponse("Coupons fetched successfully"),
+		Data:           schemas.CouponsDataSchema{Coupons: coupons, Length: len(coupons)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) DeactivateCoupon(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	couponId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	coupon, errCode, errData := couponManager.GetById(db, *couponId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	coupon, errCode, errData = couponManager.Deactivate(db, coupon)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.CouponResponseSchema{
+		ResponseSchema: SuccessResponse("Coupon deactivated successfully"),
+		Data:           schemas.CouponDataSchema{Coupon: coupon},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) ValidateCoupon(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.ValidateCoupon{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	var items []models.OrderItem
+	var errCode *int
+	var errData *utils.ErrorResponse
+	if len(reqData.Items) > 0 {
+		items, errCode, errData = pricedItems(db, reqData.Items)
+	} else {
+		var cart *models.Cart
+		if cart, errCode, errData = cartManager.GetOrCreate(db, user.ID); errCode == nil {
+			items, errCode, errData = cartManager.OrderItems(db, cart)
+		}
+	}
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	coupon, errCode, errData := couponManager.GetByCode(db, reqData.Code)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	discount, errCode, errData := couponManager.Evaluate(db, coupon, user.ID, items)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	subtotal := models.NewMoney(0, discount.Currency)
+	for _, item := range items {
+		subtotal = subtotal.Add(item.UnitPrice.Multiply(item.Quantity))
+	}
+
+	response := schemas.CouponValidationResponseSchema{
+		ResponseSchema: SuccessResponse("Coupon is valid"),
+		Data: schemas.CouponValidationDataSchema{
+			Code:     coupon.Code,
+			Subtotal: subtotal,
+			Discount: discount,
+			Total:    models.NewMoney(subtotal.Amount-discount.Amount, subtotal.Currency),
+		},
+	}
+	return c.Status(200).JSON(response)
+}
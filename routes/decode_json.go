@@ -100,3 +100,26 @@ func SuccessResponse(message string) schemas.ResponseSchema {
 func RequestUser(c *fiber.Ctx) *models.User {
 	return c.Locals("user").(*models.User)
 }
+
+// RequestSession is the signed in device the request came from
+func RequestSession(c *fiber.Ctx) *models.Session {
+	return c.Locals("session").(*models.Session)
+}
+
+// OptionalRequestUser is the signed in user on routes guests can use too
+func OptionalRequestUser(c *fiber.Ctx) *models.User {
+	user, _ := c.Locals("user").(*models.User)
+	return user
+}
+
+// Pagination reads the page and limit query params, defaulting to the
+// first page of ten
+func Pagination(c *fiber.Ctx) (int, int, *utils.ErrorResponse) {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 10)
+	if page < 1 || limit < 1 || limit > 50 {
+		errData := utils.RequestErr(utils.ERR_INVALID_PAGE, "Page must be at least 1 and limit between 1 and 50")
+		return 0, 0, &errData
+	}
+	return page, limit, nil
+}
@@ -6,7 +6,6 @@ import (
 
 	auth "github.com/DanSmirnov48/techno-trades-go-backend/authentication"
 	"github.com/DanSmirnov48/techno-trades-go-backend/config"
-	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
 	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/oauth2"
@@ -46,22 +45,5 @@ func (endpoint Endpoint) GoogleCallback(c *fiber.Ctx) error {
 		return c.Status(500).JSON(utils.RequestErr(utils.ERR_INVALID_AUTH, err.Error()))
 	}
 
-	// Generate tokens
-	access := auth.GenerateAccessToken(user.ID)
-	refresh := auth.GenerateRefreshToken()
-
-	// Update user tokens
-	user.Access = &access
-	user.Refresh = &refresh
-	db.Save(&user)
-
-	// Set cookies
-	auth.SetAuthCookie(c, auth.AccessToken, access)
-	auth.SetAuthCookie(c, auth.RefreshToken, refresh)
-
-	response := schemas.LoginResponseSchema{
-		ResponseSchema: SuccessResponse("Login successful"),
-		Data:           schemas.TokensResponseSchema{User: user, Access: access, Refresh: refresh},
-	}
-	return c.Status(201).JSON(response)
+	return completeLogin(c, db, user, "Login successful")
 }
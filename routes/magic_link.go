@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"os"
+	"strings"
+
+	auth "github.com/DanSmirnov48/techno-trades-go-backend/authentication"
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/senders"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var (
+	magicLinkManager = managers.MagicLinkManager{}
+)
+
+func (endpoint Endpoint) SendMagicLink(c *fiber.Ctx) error {
+	db := endpoint.DB
+	data := schemas.EmailRequestSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &data); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	user := models.User{Email: data.Email}
+	db.Take(&user, user)
+	if user.ID == uuid.Nil {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_CREDENTIALS, "Invalid Credentials"))
+	}
+
+	if !user.IsEmailVerified {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_UNVERIFIED_USER, "Verify your email first"))
+	}
+
+	// The link only works in this browser, which holds the nonce
+	token := auth.GenerateMagicLinkToken()
+	nonce := auth.SetMagicLinkCookie(c, managers.MagicLinkExpireMins())
+	magicLinkManager.Create(db, user.ID, auth.HashMagicLinkValue(token), auth.HashMagicLinkValue(nonce))
+
+	// Built from config, never from the Host header, so a spoofed request can't
+	// point the emailed link at someone else's server
+	link := strings.TrimRight(config.GetConfig().ServerURL, "/") + "/api/v1/auth/magic-link/" + token
+	go senders.SendMagicLink(&user, link)
+
+	response := schemas.MagicLinkLoginResponseSchema{ResponseSchema: SuccessResponse("Sign-in link has been sent")}
+	if os.Getenv("ENVIRONMENT") == "TESTING" {
+		// Nothing is emailed while testing
+		response.Data.Link = link
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) LoginWithMagicLink(c *fiber.Ctx) error {
+	db := endpoint.DB
+	token := c.Params("token")
+	if !auth.VerifyMagicLinkToken(token) {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, "Sign-in link is invalid or has expired"))
+	}
+
+	nonce := c.Cookies(string(auth.MagicLink))
+	if nonce == "" {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_AUTH, "Open the sign-in link in the browser you requested it from"))
+	}
+
+	user, errCode, errData := magicLinkManager.Redeem(db, auth.HashMagicLinkValue(token), auth.HashMagicLinkValue(nonce))
+	if errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	auth.RemoveMagicLinkCookie(c)
+
+	if !user.IsEmailVerified {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_UNVERIFIED_USER, "Verify your email first"))
+	}
+	return completeLogin(c, db, user, "Logged in successfully")
+}
@@ -0,0 +1,460 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	invoiceManager = managers.InvoiceManager{}
+)
+
+// requestOrder loads the order in the path. Customers only get their own
+// orders, staff get any of them.
+func requestOrder(c *fiber.Ctx, db *gorm.DB) (*models.Order, *int, *utils.ErrorResponse) {
+	user := RequestUser(c)
+	orderId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		statusCode := 400
+		return nil, &statusCode, err
+	}
+
+	order, errCode, errData := orderManager.GetById(db, *orderId)
+	if errCode != nil {
+		return nil, errCode, errData
+	}
+	if user.AccountType == models.AccountTypeBuyer && order.UserId != user.ID {
+		statusCode := 404
+		errData := utils.RequestErr(utils.ERR_NON_EXISTENT, "Order does not exist")
+		return nil, &statusCode, &errData
+	}
+	return order, nil, nil
+}
+
+func sendInvoicePDF(c *fiber.Ctx, db *gorm.DB, invoice *models.Invoice) error {
+	content, errCode, errData := invoiceManager.Download(db, invoice)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.pdf"`, invoice.Number))
+	return c.Status(200).Send(content)
+}
+
+func (endpoint Endpoint) GetOrderInvoice(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	order, errCode, errData := requestOrder(c, db)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if order.PaidAt == nil {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Invoices are issued once the order is paid"))
+	}
+
+	// Raised here if it couldn't be when the payment came in
+	invoice, errCode, errData := invoiceManager.IssueInvoice(db, order)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	return sendInvoicePDF(c, db, invoice)
+}
+
+func (endpoint Endpoint) GetOrderInvoices(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	order, errCode, errData := requestOrder(c, db)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	invoices := invoiceManager.GetForOrder(db, order.ID)
+
+	response := schemas.InvoicesResponseSchema{
+		ResponseSchema: SuccessResponse("Invoices fetched successfully"),
+		Data:           schemas.InvoicesDataSchema{Invoices: invoices, Length: len(invoices)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// GetOrderInvoiceDocument downloads any of the order's invoices or credit notes by number
+func (endpoint Endpoint) GetOrderInvoiceDocument(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	order, errCode, errData := requestOrder(c, db)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	invoice, errCode, errData := invoiceManager.GetByNumber(db, order.ID, c.Params("number"))
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	return sendInvoicePDF(c, db, invoice)
+}
+
+// ----------------------------------
+// CUSTOMER ORDER HISTORY
+// --------------------------------
+
+// orderStatuses reads a comma separated status filter, e.g. ?status=paid,shipped
+func orderStatuses(c *fiber.Ctx) ([]models.OrderStatus, *utils.ErrorResponse) {
+	statuses := []models.OrderStatus{}
+	filter := c.Query("status")
+	if filter == "" {
+		return statuses, nil
+	}
+	for _, value := range strings.Split(filter, ",") {
+		status := models.OrderStatus(strings.TrimSpace(value))
+		if !status.IsValid() {
+			errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Invalid order status")
+			return nil, &errData
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (endpoint Endpoint) GetMyOrders(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	page, limit, errData := Pagination(c)
+	if errData != nil {
+		return c.Status(400).JSON(errData)
+	}
+
+	statuses, errData := orderStatuses(c)
+	if errData != nil {
+		return c.Status(400).JSON(errData)
+	}
+
+	orders, total := orderManager.GetForUser(db, user.ID, statuses, page, limit)
+
+	response := schemas.OrdersResponseSchema{
+		ResponseSchema: SuccessResponse("Orders fetched successfully"),
+		Data: schemas.OrdersDataSchema{
+			Pagination: schemas.PaginationSchema{Page: page, Limit: limit, Total: total},
+		}.Init(orders),
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) GetMyOrder(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	orderId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	order, errCode, errData := orderManager.GetById(db, *orderId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if order.UserId != user.ID {
+		return c.Status(404).JSON(utils.RequestErr(utils.ERR_NON_EXISTENT, "Order does not exist"))
+	}
+
+	response := schemas.OrderResponseSchema{
+		ResponseSchema: SuccessResponse("Order fetched successfully"),
+		Data:           schemas.OrderDataSchema{Order: schemas.OrderDetailSchema{}.Init(order)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// CancelMyOrder lets the buyer call off an order that hasn't started being
+// fulfilled. Paid orders are refunded in full before they are cancelled.
+func (endpoint Endpoint) CancelMyOrder(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	orderId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	var order *models.Order
+	var statusCode int
+	var errData utils.ErrorResponse
+	txErr := db.Transaction(func(tx *gorm.DB) error {
+		// Locked so a webhook or a second request can't move it meanwhile
+		locked, errCode, errResp := orderManager.GetForUpdate(tx, *orderId)
+		if errCode != nil {
+			statusCode, errData = *errCode, *errResp
+			return &errData
+		}
+		if locked.UserId != user.ID {
+			statusCode = 404
+			errData = utils.RequestErr(utils.ERR_NON_EXISTENT, "Order does not exist")
+			return &errData
+		}
+		if !locked.IsCancellable() {
+			statusCode = 400
+			errData = utils.RequestErr(utils.ERR_NOT_ALLOWED, "Order can no longer be cancelled")
+			return &errData
+		}
+
+		if locked.Status == models.OrderStatusPaid {
+			if locked.StripePaymentIntentId == nil {
+				statusCode = 400
+				errData = utils.RequestErr(utils.ERR_NOT_ALLOWED, "Order has no payment to refund")
+				return &errData
+			}
+			amount := locked.Total.Amount - locked.Refunded.Amount
+			if amount > 0 {
+				_, err := endpoint.Payments.Refund(payments.RefundParams{
+					PaymentIntentID: *locked.StripePaymentIntentId,
+					Amount:          amount,
+					Metadata:        map[string]string{"orderId": locked.ID.String(), "reason": "cancelled"},
+					// The refund can't be rolled back with the transaction, so a
+					// retry after a failed commit gets the same refund back
+					IdempotencyKey: fmt.Sprintf("cancel-%s", locked.ID),
+				})
+				if err != nil {
+					log.Printf("Refund for cancelled order %s failed: %v", locked.OrderNumber, err)
+					statusCode = 502
+					errData = utils.RequestErr(utils.ERR_SERVER_ERROR, "The refund failed so the order was not cancelled, please try again")
+					return &errData
+				}
+			}
+		}
+
+		order, errCode, errResp = orderManager.UpdateStatus(tx, locked, models.OrderStatusCancelled)
+		if errCode != nil {
+			statusCode, errData = *errCode, *errResp
+			return &errData
+		}
+		return nil
+	})
+	if txErr != nil {
+		if statusCode == 0 {
+			return c.Status(500).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to cancel order"))
+		}
+		return c.Status(statusCode).JSON(errData)
+	}
+
+	response := schemas.OrderResponseSchema{
+		ResponseSchema: SuccessResponse("Order cancelled successfully"),
+		Data:           schemas.OrderDataSchema{Order: schemas.OrderDetailSchema{}.Init(order)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// ----------------------------------
+// STAFF ORDER MANAGEMENT
+// --------------------------------
+
+// orderFilter reads the staff search from the query string. Dates are
+// YYYY-MM-DD and both ends of the range are inclusive.
+func orderFilter(c *fiber.Ctx) (managers.OrderFilter, *utils.ErrorResponse) {
+	filter := managers.OrderFilter{
+		Email:       strings.TrimSpace(c.Query("email")),
+		OrderNumber: strings.TrimSpace(c.Query("order_number")),
+	}
+
+	statuses, errData := orderStatuses(c)
+	if errData != nil {
+		return filter, errData
+	}
+	filter.Statuses = statuses
+
+	if value := c.Query("from"); value != "" {
+		from, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Invalid from date, use YYYY-MM-DD")
+			return filter, &errData
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			errData := utils.RequestErr(utils.ERR_INVALID_VALUE, "Invalid to date, use YYYY-MM-DD")
+			return filter, &errData
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+	return filter, nil
+}
+
+func (endpoint Endpoint) GetAllOrders(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	filter, errData := orderFilter(c)
+	if errData != nil {
+		return c.Status(400).JSON(errData)
+	}
+	page, limit, errData := Pagination(c)
+	if errData != nil {
+		return c.Status(400).JSON(errData)
+	}
+
+	orders, total := orderManager.Search(db, filter, page, limit)
+
+	response := schemas.OrdersResponseSchema{
+		ResponseSchema: SuccessResponse("Orders fetched successfully"),
+		Data: schemas.OrdersDataSchema{
+			Pagination: schemas.PaginationSchema{Page: page, Limit: limit, Total: total},
+		}.Init(orders),
+	}
+	return c.Status(200).JSON(response)
+}
+
+// ExportOrders downloads every order matching the search as CSV, one row per order
+func (endpoint Endpoint) ExportOrders(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	filter, errData := orderFilter(c)
+	if errData != nil {
+		return c.Status(400).JSON(errData)
+	}
+
+	orders := orderManager.SearchAll(db, filter)
+
+	buf := bytes.Buffer{}
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{
+		"order_number", "created_at", "status", "payment_status", "customer_email", "items",
+		"subtotal", "discount", "shipping", "tax", "total", "refunded", "currency",
+		"shipping_method", "carrier", "tracking_number",
+	})
+	for _, order := range orders {
+		items := 0
+		for _, item := range order.Items {
+			items += item.Quantity
+		}
+		writer.Write([]string{
+			order.OrderNumber,
+			order.CreatedAt.Format(time.RFC3339),
+			string(order.Status),
+			string(order.PaymentStatus()),
+			order.User.Email,
+			strconv.Itoa(items),
+			order.Subtotal.Decimal(),
+			order.Discount.Decimal(),
+			order.Shipping.Decimal(),
+			order.Tax.Decimal(),
+			order.Total.Decimal(),
+			order.Refunded.Decimal(),
+			strings.ToUpper(order.Total.Currency),
+			order.ShippingMethodName,
+			order.Carrier,
+			order.TrackingNumber,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return c.Status(500).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to export orders"))
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="orders-%s.csv"`, time.Now().Format("20060102-150405")))
+	return c.Status(200).Send(buf.Bytes())
+}
+
+func (endpoint Endpoint) GetOrder(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	order, errCode, errData := requestOrder(c, db)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	db.Take(&order.User, "id = ?", order.UserId)
+
+	response := schemas.StaffOrderResponseSchema{
+		ResponseSchema: SuccessResponse("Order fetched successfully"),
+		Data: schemas.StaffOrderDataSchema{
+			Order: schemas.OrderDetailSchema{}.Init(order),
+			Notes: orderManager.GetNotes(db, order.ID),
+		},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) BulkUpdateOrderStatus(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.BulkOrderStatus{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	orders, failed := orderManager.BulkUpdateStatus(db, reqData.OrderIds, reqData.Status)
+
+	updated := make([]schemas.OrderDetailSchema, 0, len(orders))
+	for _, order := range orders {
+		updated = append(updated, schemas.OrderDetailSchema{}.Init(order))
+	}
+	response := schemas.BulkOrderStatusResponseSchema{
+		ResponseSchema: SuccessResponse(fmt.Sprintf("%d of %d orders updated", len(updated), len(reqData.OrderIds))),
+		Data:           schemas.BulkOrderStatusDataSchema{Updated: updated, Failed: failed},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) AddOrderNote(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.CreateOrderNote{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	order, errCode, errData := requestOrder(c, db)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	note, errCode, errData := orderManager.AddNote(db, order.ID, user.ID, reqData.Body)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.OrderNoteResponseSchema{
+		ResponseSchema: SuccessResponse("Note added successfully"),
+		Data:           schemas.OrderNoteDataSchema{Note: note},
+	}
+	return c.Status(201).JSON(response)
+}
+
+func (endpoint Endpoint) ShipOrder(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.ShipOrder{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	order, errCode, errData := requestOrder(c, db)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	order, errCode, errData = orderManager.Ship(db, order, strings.TrimSpace(reqData.Carrier), strings.TrimSpace(reqData.TrackingNumber))
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.OrderResponseSchema{
+		ResponseSchema: SuccessResponse("Order marked as shipped"),
+		Data:           schemas.OrderDataSchema{Order: schemas.OrderDetailSchema{}.Init(order)},
+	}
+	return c.Status(200).JSON(response)
+}
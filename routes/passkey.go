@@ -0,0 +1,201 @@
+package routes
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/senders"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	passkeyManager = managers.PasskeyManager{}
+)
+
+func (endpoint Endpoint) BeginPasskeyRegistration(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	options, ceremonyId, errCode, errData := passkeyManager.BeginRegistration(db, user)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.PasskeyOptionsResponseSchema{
+		ResponseSchema: SuccessResponse("Passkey registration started"),
+		Data:           schemas.PasskeyOptionsSchema{CeremonyId: *ceremonyId, Options: options},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) FinishPasskeyRegistration(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.FinishPasskeyRegistrationSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	passkey, errCode, errData := passkeyManager.FinishRegistration(db, user, reqData, c.Get(fiber.HeaderUserAgent))
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.PasskeyResponseSchema{
+		ResponseSchema: SuccessResponse("Passkey added successfully"),
+		Data:           schemas.PasskeyDataSchema{Passkey: passkey},
+	}
+	return c.Status(201).JSON(response)
+}
+
+func (endpoint Endpoint) BeginPasskeySignup(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.PasskeySignupSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	options, ceremonyId, errCode, errData := passkeyManager.BeginSignup(db, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.PasskeyOptionsResponseSchema{
+		ResponseSchema: SuccessResponse("Passkey registration started"),
+		Data:           schemas.PasskeyOptionsSchema{CeremonyId: *ceremonyId, Options: options},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) FinishPasskeySignup(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.FinishPasskeySchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	user, errCode, errData := passkeyManager.FinishSignup(db, reqData, c.Get(fiber.HeaderUserAgent))
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	// Create Otp
+	otp := models.Otp{UserId: user.ID}
+	db.Take(&otp, otp)
+	db.Create(&otp)
+
+	go senders.SendEmail(user, senders.EmailActivate, &otp.Code)
+
+	response := schemas.RegisterResponseSchema{
+		ResponseSchema: SuccessResponse("Registration successful"),
+		Data:           schemas.EmailRequestSchema{Email: user.Email},
+	}
+	return c.Status(201).JSON(response)
+}
+
+func (endpoint Endpoint) BeginPasskeyLogin(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	options, ceremonyId, errCode, errData := passkeyManager.BeginLogin(db)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.PasskeyOptionsResponseSchema{
+		ResponseSchema: SuccessResponse("Passkey login started"),
+		Data:           schemas.PasskeyOptionsSchema{CeremonyId: *ceremonyId, Options: options},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// FinishPasskeyLogin signs the user straight in. User verification is
+// required, so the passkey proves both something they have and something
+// they are or know, and no two-factor code is asked for.
+func (endpoint Endpoint) FinishPasskeyLogin(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.FinishPasskeySchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	user, errCode, errData := passkeyManager.FinishLogin(db, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if !user.IsEmailVerified {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_UNVERIFIED_USER, "Verify your email first"))
+	}
+
+	return startSession(c, db, user, "Login successful")
+}
+
+func (endpoint Endpoint) GetMyPasskeys(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	passkeys := passkeyManager.GetAll(db, user.ID)
+
+	response := schemas.PasskeysResponseSchema{
+		ResponseSchema: SuccessResponse("Passkeys fetched successfully"),
+		Data:           schemas.PasskeysDataSchema{Passkeys: passkeys, Length: len(passkeys)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) RenamePasskey(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.RenamePasskeySchema{}
+
+	passkeyId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	passkey, errCode, errData := passkeyManager.GetForUser(db, user.ID, *passkeyId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	passkey = passkeyManager.Rename(db, passkey, reqData.Name)
+
+	response := schemas.PasskeyResponseSchema{
+		ResponseSchema: SuccessResponse("Passkey updated successfully"),
+		Data:           schemas.PasskeyDataSchema{Passkey: passkey},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) DeletePasskey(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	passkeyId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	passkey, errCode, errData := passkeyManager.GetForUser(db, user.ID, *passkeyId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if errCode, errData := passkeyManager.Delete(db, user, passkey); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	return c.Status(200).JSON(SuccessResponse("Passkey deleted successfully"))
+}
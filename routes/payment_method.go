@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func stripeCustomerParams(user *models.User) payments.CustomerParams {
+	return payments.CustomerParams{
+		Name:     fmt.Sprintf("%s %s", user.FirstName, user.LastName),
+		Email:    user.Email,
+		Metadata: map[string]string{"userId": user.ID.String()},
+	}
+}
+
+// stripeCustomer returns the user's payment provider customer, creating it
+// the first time it's needed. The idempotency key keeps two checkouts racing
+// each other from making two customers.
+func (endpoint Endpoint) stripeCustomer(db *gorm.DB, user *models.User) (string, *int, *utils.ErrorResponse) {
+	if user.StripeCustomerID != nil {
+		return *user.StripeCustomerID, nil, nil
+	}
+
+	params := stripeCustomerParams(user)
+	params.IdempotencyKey = fmt.Sprintf("customer-%s", user.ID)
+	customer, err := endpoint.Payments.CreateCustomer(params)
+	if err != nil {
+		log.Printf("Stripe customer creation error: %v", err)
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create customer")
+		return "", &statusCode, &errData
+	}
+
+	result := db.Model(&models.User{}).
+		Where("id = ? AND stripe_customer_id IS NULL", user.ID).
+		Update("stripe_customer_id", customer.ID)
+	if result.Error != nil {
+		statusCode := 500
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to save customer")
+		return "", &statusCode, &errData
+	}
+	if result.RowsAffected == 0 {
+		// Another request got there first, keep theirs
+		stored := models.User{}
+		db.Select("stripe_customer_id").Take(&stored, "id = ?", user.ID)
+		if stored.StripeCustomerID != nil {
+			user.StripeCustomerID = stored.StripeCustomerID
+			return *user.StripeCustomerID, nil, nil
+		}
+	}
+	user.StripeCustomerID = &customer.ID
+	return customer.ID, nil, nil
+}
+
+// syncStripeCustomer copies the user's name and email over to their payment
+// provider customer. A failure is only logged, the next change tries again.
+func (endpoint Endpoint) syncStripeCustomer(user *models.User) {
+	if user.StripeCustomerID == nil {
+		return
+	}
+	if err := endpoint.Payments.UpdateCustomer(*user.StripeCustomerID, stripeCustomerParams(user)); err != nil {
+		log.Printf("Stripe customer %s update error: %v", *user.StripeCustomerID, err)
+	}
+}
+
+func (endpoint Endpoint) GetMyPaymentMethods(c *fiber.Ctx) error {
+	user := RequestUser(c)
+
+	methods := []payments.PaymentMethod{}
+	if user.StripeCustomerID != nil {
+		var err error
+		methods, err = endpoint.Payments.ListPaymentMethods(*user.StripeCustomerID)
+		if err != nil {
+			log.Printf("Stripe payment methods error: %v", err)
+			return c.Status(502).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to fetch payment methods"))
+		}
+	}
+
+	response := schemas.PaymentMethodsResponseSchema{
+		ResponseSchema: SuccessResponse("Payment methods fetched successfully"),
+		Data:           schemas.PaymentMethodsDataSchema{}.Init(methods),
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) DeletePaymentMethod(c *fiber.Ctx) error {
+	user := RequestUser(c)
+
+	if user.StripeCustomerID == nil {
+		return c.Status(404).JSON(utils.RequestErr(utils.ERR_NON_EXISTENT, "Payment method does not exist"))
+	}
+	err := endpoint.Payments.DetachPaymentMethod(*user.StripeCustomerID, c.Params("id"))
+	if errors.Is(err, payments.ErrPaymentMethodNotFound) {
+		return c.Status(404).JSON(utils.RequestErr(utils.ERR_NON_EXISTENT, "Payment method does not exist"))
+	}
+	if err != nil {
+		log.Printf("Stripe payment method detach error: %v", err)
+		return c.Status(502).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to remove payment method"))
+	}
+
+	return c.Status(200).JSON(SuccessResponse("Payment method removed successfully"))
+}
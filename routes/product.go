@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
 	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
 	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
 	"github.com/gofiber/fiber/v2"
@@ -109,6 +110,7 @@ func (endpoint Endpoint) SetProductDiscount(c *fiber.Ctx) error {
 
 func (endpoint Endpoint) UpdateProductStock(c *fiber.Ctx) error {
 	db := endpoint.DB
+	user := RequestUser(c)
 	reqData := schemas.UpdateStockSchema{}
 
 	productId, err := utils.ParseUUID(c.Params("id"))
@@ -120,7 +122,12 @@ func (endpoint Endpoint) UpdateProductStock(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
-	updatedProduct, errCode, errData := productManager.UpdateStock(db, *productId, reqData.StockChange)
+	reason := models.StockMovementReason(reqData.Reason)
+	if reason == "" {
+		reason = models.StockMovementAdjustment
+	}
+
+	updatedProduct, errCode, errData := productManager.UpdateStock(db, *productId, reqData.StockChange, reason, &user.ID, nil)
 	if errCode != nil {
 		return c.Status(*errCode).JSON(errData)
 	}
@@ -134,6 +141,7 @@ func (endpoint Endpoint) UpdateProductStock(c *fiber.Ctx) error {
 
 func (endpoint Endpoint) UpdateProductDetails(c *fiber.Ctx) error {
 	db := endpoint.DB
+	user := RequestUser(c)
 	reqData := schemas.UpdateProduct{}
 
 	productId, err := utils.ParseUUID(c.Params("id"))
@@ -150,10 +158,31 @@ func (endpoint Endpoint) UpdateProductDetails(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
-	if reqData.Name != product.Name {
+	// Stock changes go through the ledger rather than being overwritten
+	if reqData.CountInStock != 0 && reqData.CountInStock != product.CountInStock {
+		product, errCode, errData = productManager.UpdateStock(db, *productId, reqData.CountInStock-product.CountInStock, models.StockMovementAdjustment, &user.ID, nil)
+		if errCode != nil {
+			return c.Status(*errCode).JSON(errData)
+		}
+	}
+
+	if reqData.Name != "" && reqData.Name != product.Name {
+		product.Name = reqData.Name
 		product.Slug = slug.Make(reqData.Name)
 	}
-	db.Model(&product).Updates(reqData)
+	if reqData.Brand != "" {
+		product.Brand = reqData.Brand
+	}
+	if reqData.Category != "" {
+		product.Category = reqData.Category
+	}
+	if reqData.Description != "" {
+		product.Description = reqData.Description
+	}
+	if reqData.Price != 0 {
+		product.Price = models.NewMoney(reqData.Price, product.Price.Currency)
+	}
+	db.Omit("CountInStock", "Images").Save(product)
 
 	response := schemas.ProductCreateResponseSchema{
 		ResponseSchema: SuccessResponse("Product updated successfully"),
@@ -162,6 +191,26 @@ func (endpoint Endpoint) UpdateProductDetails(c *fiber.Ctx) error {
 	return c.Status(200).JSON(response)
 }
 
+func (endpoint Endpoint) GetProductStockMovements(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	productId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	movements, errCode, errData := productManager.GetStockMovements(db, *productId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.StockMovementsResponseSchema{
+		ResponseSchema: SuccessResponse("Stock movements fetched successfully"),
+		Data:           schemas.StockMovementsDataSchema{StockMovements: movements, Length: len(movements)},
+	}
+	return c.Status(200).JSON(response)
+}
+
 func (endpoint Endpoint) DeleteProduct(c *fiber.Ctx) error {
 	db := endpoint.DB
 
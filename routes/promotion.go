@@ -0,0 +1,115 @@
+package routes
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	promotionManager = managers.PromotionManager{}
+)
+
+func (endpoint Endpoint) CreatePromotion(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.CreatePromotion{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	promotion, errCode, errData := promotionManager.Create(db, reqData, user.ID)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.PromotionResponseSchema{
+		ResponseSchema: SuccessResponse("Promotion created successfully"),
+		Data:           schemas.PromotionDataSchema{Promotion: promotion},
+	}
+	return c.Status(201).JSON(response)
+}
+
+func (endpoint Endpoint) GetAllPromotions(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	promotions := promotionManager.GetAll(db, c.Query("status"))
+
+	response := schemas.PromotionsResponseSchema{
+		ResponseSchema: SuccessResponse("Promotions fetched successfully"),
+		Data:           schemas.PromotionsDataSchema{Promotions: promotions, Length: len(promotions)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) GetPromotion(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	promotionId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	promotion, errCode, errData := promotionManager.GetById(db, *promotionId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.PromotionResponseSchema{
+		ResponseSchema: SuccessResponse("Promotion fetched successfully"),
+		Data:           schemas.PromotionDataSchema{Promotion: promotion},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) UpdatePromotion(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.CreatePromotion{}
+
+	promotionId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	promotion, errCode, errData := promotionManager.GetById(db, *promotionId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	promotion, errCode, errData = promotionManager.Update(db, promotion, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.PromotionResponseSchema{
+		ResponseSchema: SuccessResponse("Promotion updated successfully"),
+		Data:           schemas.PromotionDataSchema{Promotion: promotion},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) DeletePromotion(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	promotionId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	promotion, errCode, errData := promotionManager.GetById(db, *promotionId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if errCode, errData := promotionManager.Delete(db, promotion); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	return c.Status(200).JSON(SuccessResponse("Promotion deleted successfully"))
+}
@@ -0,0 +1,251 @@
+package routes
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/senders"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	returnManager = managers.ReturnManager{}
+)
+
+// emailReturnCustomer lets the customer who opened the return know about its latest step
+func emailReturnCustomer(db *gorm.DB, rma *models.ReturnRequest, emailType senders.EmailType) {
+	customer := models.User{}
+	db.Take(&customer, "id = ?", rma.UserId)
+	go senders.SendReturnEmail(&customer, emailType, rma)
+}
+
+// refundReturn sends an approved return's refund to the payment provider and
+// closes the return. On failure the return stays approved so it can be retried.
+func (endpoint Endpoint) refundReturn(db *gorm.DB, rma *models.ReturnRequest, actor *models.User) (*models.ReturnRequest, *int, *utils.ErrorResponse) {
+	order, errCode, errData := orderManager.GetById(db, rma.OrderId)
+	if errCode != nil {
+		return nil, errCode, errData
+	}
+	if order.StripePaymentIntentId == nil {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Order has no payment to refund")
+		return nil, &statusCode, &errData
+	}
+
+	// Never refund more than is left on the order
+	amount := rma.Refund.Amount
+	if remaining := order.Total.Amount - order.Refunded.Amount; amount > remaining {
+		amount = remaining
+	}
+	if amount <= 0 {
+		statusCode := 400
+		errData := utils.RequestErr(utils.ERR_NOT_ALLOWED, "Order has already been refunded in full")
+		return nil, &statusCode, &errData
+	}
+
+	refund, err := endpoint.Payments.Refund(payments.RefundParams{
+		PaymentIntentID: *order.StripePaymentIntentId,
+		Amount:          amount,
+		Metadata: map[string]string{
+			"orderId":   order.ID.String(),
+			"returnId":  rma.ID.String(),
+			"rmaNumber": rma.RMANumber,
+		},
+		// A retry after the refund went through but the return wasn't
+		// closed gets the same refund back instead of a second one
+		IdempotencyKey: fmt.Sprintf("return-%s", rma.ID),
+	})
+	if err != nil {
+		log.Printf("Refund for return %s failed: %v", rma.RMANumber, err)
+		statusCode := 502
+		errData := utils.RequestErr(utils.ERR_SERVER_ERROR, "Return approved but the refund failed, please retry it")
+		return nil, &statusCode, &errData
+	}
+
+	rma.Refund = models.NewMoney(amount, rma.Refund.Currency)
+	return returnManager.MarkRefunded(db, rma, refund.ID, actor.ID)
+}
+
+func (endpoint Endpoint) CreateReturn(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.CreateReturn{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	rma, errCode, errData := returnManager.Create(db, user.ID, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	go senders.SendReturnEmail(user, senders.EmailReturnRequested, rma)
+
+	response := schemas.ReturnResponseSchema{
+		ResponseSchema: SuccessResponse("Return requested successfully"),
+		Data:           schemas.ReturnDataSchema{Return: rma},
+	}
+	return c.Status(201).JSON(response)
+}
+
+func (endpoint Endpoint) GetMyReturns(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	returns := returnManager.GetAll(db, &user.ID, c.Query("status"))
+
+	response := schemas.ReturnsResponseSchema{
+		ResponseSchema: SuccessResponse("Returns fetched successfully"),
+		Data:           schemas.ReturnsDataSchema{Returns: returns, Length: len(returns)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) GetAllReturns(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	returns := returnManager.GetAll(db, nil, c.Query("status"))
+
+	response := schemas.ReturnsResponseSchema{
+		ResponseSchema: SuccessResponse("Returns fetched successfully"),
+		Data:           schemas.ReturnsDataSchema{Returns: returns, Length: len(returns)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) GetReturn(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	returnId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	// Customers only see their own returns, staff see all of them
+	var rma *models.ReturnRequest
+	var errCode *int
+	var errData *utils.ErrorResponse
+	if user.AccountType == models.AccountTypeBuyer {
+		rma, errCode, errData = returnManager.GetForUser(db, user.ID, *returnId)
+	} else {
+		rma, errCode, errData = returnManager.GetById(db, *returnId)
+	}
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.ReturnResponseSchema{
+		ResponseSchema: SuccessResponse("Return fetched successfully"),
+		Data:           schemas.ReturnDataSchema{Return: rma},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) ApproveReturn(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.ApproveReturn{}
+
+	returnId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	rma, errCode, errData := returnManager.GetById(db, *returnId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	rma, errCode, errData = returnManager.Approve(db, rma, user.ID, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	emailReturnCustomer(db, rma, senders.EmailReturnApproved)
+
+	refunded, errCode, errData := endpoint.refundReturn(db, rma, user)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	emailReturnCustomer(db, refunded, senders.EmailReturnRefunded)
+
+	response := schemas.ReturnResponseSchema{
+		ResponseSchema: SuccessResponse("Return approved and refunded successfully"),
+		Data:           schemas.ReturnDataSchema{Return: refunded},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) RejectReturn(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.RejectReturn{}
+
+	returnId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	rma, errCode, errData := returnManager.GetById(db, *returnId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	rma, errCode, errData = returnManager.Reject(db, rma, user.ID, reqData.Note)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	emailReturnCustomer(db, rma, senders.EmailReturnRejected)
+
+	response := schemas.ReturnResponseSchema{
+		ResponseSchema: SuccessResponse("Return rejected successfully"),
+		Data:           schemas.ReturnDataSchema{Return: rma},
+	}
+	return c.Status(200).JSON(response)
+}
+
+// RetryReturnRefund sends the refund again for an approved return whose
+// first attempt failed
+func (endpoint Endpoint) RetryReturnRefund(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	returnId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	rma, errCode, errData := returnManager.GetById(db, *returnId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	if rma.Status != models.ReturnStatusApproved {
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Only approved returns can be refunded"))
+	}
+
+	rma, errCode, errData = endpoint.refundReturn(db, rma, user)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	emailReturnCustomer(db, rma, senders.EmailReturnRefunded)
+
+	response := schemas.ReturnResponseSchema{
+		ResponseSchema: SuccessResponse("Return refunded successfully"),
+		Data:           schemas.ReturnDataSchema{Return: rma},
+	}
+	return c.Status(200).JSON(response)
+}
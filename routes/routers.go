@@ -2,40 +2,70 @@ package routes
 
 import (
 	midw "github.com/DanSmirnov48/techno-trades-go-backend/authentication"
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
 	"gorm.io/gorm"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type Endpoint struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Payments payments.PaymentProvider
 }
 
-func SetupRoutes(app *fiber.App, db *gorm.DB) {
+func SetupRoutes(app *fiber.App, db *gorm.DB, provider ...payments.PaymentProvider) {
 	midw := midw.Middleware{DB: db}
 	endpoint := Endpoint{DB: db}
 
+	// When a provider is passed (main and tests), use it instead of a new Stripe one
+	if len(provider) > 0 {
+		endpoint.Payments = provider[0]
+	} else {
+		cfg := config.GetConfig()
+		endpoint.Payments = payments.NewStripeProvider(cfg.StripeSecretKey, cfg.StripeWebhookSecret)
+	}
+
 	api := app.Group("/api/v1")
 
 	// HealthCheck Route (1)
 	api.Get("/healthcheck", HealthCheck)
 
-	// Auth Routes (7)
+	// Auth Routes (16)
 	authRouter := api.Group("/auth")
 	authRouter.Post("/register", endpoint.Register)
 	authRouter.Post("/login", midw.RateLimiter, endpoint.Login)
 	authRouter.Get("/logout", midw.AuthMiddleware, endpoint.Logout)
+	authRouter.Get("/logout-all", midw.AuthMiddleware, endpoint.LogoutEverywhere)
 	authRouter.Post("/verify-account", endpoint.VerifyAccount)
 	authRouter.Post("/resend-verification-email", endpoint.ResendVerificationEmail)
 	authRouter.Get("/validate", endpoint.ValidateMe)
-	authRouter.Post("/refresh", midw.AuthMiddleware, endpoint.Refresh)
+	authRouter.Post("/refresh", endpoint.Refresh)
 	authRouter.Post("/forgot-password", midw.RateLimiter, endpoint.SendPasswordResetOtp)
 	authRouter.Post("/set-new-password", endpoint.SetNewPassword)
 	authRouter.Get("/send-login-otp", endpoint.SendLoginOtp)
 	authRouter.Post("/login/:otp", endpoint.LoginWithOtp)
+	authRouter.Post("/magic-link", midw.RateLimiter, endpoint.SendMagicLink)
+	authRouter.Get("/magic-link/:token", midw.RateLimiter, endpoint.LoginWithMagicLink)
 	authRouter.Get("/google", endpoint.GoogleLogin)
 	authRouter.Get("/google/callback", endpoint.GoogleCallback)
 
+	// Two-factor Routes (4)
+	totp := authRouter.Group("/2fa")
+	totp.Post("/setup", midw.AuthMiddleware, endpoint.SetupTotp)
+	totp.Post("/confirm", midw.AuthMiddleware, endpoint.ConfirmTotp)
+	totp.Post("/disable", midw.AuthMiddleware, endpoint.DisableTotp)
+	totp.Post("/verify", midw.RateLimiter, endpoint.VerifyTotpLogin)
+
+	// Passkey Routes (6)
+	passkeys := authRouter.Group("/passkeys")
+	passkeys.Post("/register/begin", midw.AuthMiddleware, endpoint.BeginPasskeyRegistration)
+	passkeys.Post("/register/finish", midw.AuthMiddleware, endpoint.FinishPasskeyRegistration)
+	passkeys.Post("/signup/begin", endpoint.BeginPasskeySignup)
+	passkeys.Post("/signup/finish", endpoint.FinishPasskeySignup)
+	passkeys.Post("/login/begin", endpoint.BeginPasskeyLogin)
+	passkeys.Post("/login/finish", midw.RateLimiter, endpoint.FinishPasskeyLogin)
+
 	// Users profile routes (5) for AUTHORIZED users
 	users := api.Group("/users")
 	users.Patch("/update-my-password", midw.AuthMiddleware, endpoint.UpdateSignedInUserPassword)
@@ -43,31 +73,128 @@ func SetupRoutes(app *fiber.App, db *gorm.DB) {
 	users.Delete("/deactivate-me", midw.AuthMiddleware, endpoint.DeleteMe)
 	users.Get("/send-email-change-otp", midw.AuthMiddleware, endpoint.SendUserEmailChangeOtp)
 	users.Patch("/update-my-email", midw.AuthMiddleware, endpoint.UpdateUserEmail)
+
+	// Address book routes (4)
+	addresses := users.Group("/me/addresses", midw.AuthMiddleware)
+	addresses.Get("/", endpoint.GetMyAddresses)
+	addresses.Post("/", endpoint.CreateAddress)
+	addresses.Patch("/:id", endpoint.UpdateAddress)
+	addresses.Delete("/:id", endpoint.DeleteAddress)
+
+	// Order history routes (3)
+	myOrders := users.Group("/me/orders", midw.AuthMiddleware)
+	myOrders.Get("/", endpoint.GetMyOrders)
+	myOrders.Get("/:id", endpoint.GetMyOrder)
+	myOrders.Post("/:id/cancel", endpoint.CancelMyOrder)
+
+	// Saved payment method routes (2)
+	paymentMethods := users.Group("/me/payment-methods", midw.AuthMiddleware)
+	paymentMethods.Get("/", endpoint.GetMyPaymentMethods)
+	paymentMethods.Delete("/:id", endpoint.DeletePaymentMethod)
+
+	// Signed in device routes (2)
+	sessions := users.Group("/me/sessions", midw.AuthMiddleware)
+	sessions.Get("/", endpoint.GetMySessions)
+	sessions.Delete("/:id", endpoint.RevokeSession)
+
+	// Saved passkey routes (3)
+	myPasskeys := users.Group("/me/passkeys", midw.AuthMiddleware)
+	myPasskeys.Get("/", endpoint.GetMyPasskeys)
+	myPasskeys.Patch("/:id", endpoint.RenamePasskey)
+	myPasskeys.Delete("/:id", endpoint.DeletePasskey)
+
 	users.Get("/:id", endpoint.GetUserByParamsID)
 	users.Get("/", midw.AuthMiddleware, midw.Admin, endpoint.GetAllUsers)
 
 	// ### -----------------------PRODUCTS-----------------------
-	// Product Routes (8)
+	// Product Routes (9)
 	products := api.Group("/products")
 	products.Get("/:slug", endpoint.FindProductBySlug)
 	products.Get("/:id", endpoint.FindProductById)
 	products.Get("/", endpoint.GetAllProducts)
 
 	admin_products := api.Group("/products", midw.AuthMiddleware, midw.Admin)
-	admin_products.Post("/new", endpoint.CreateNewProduct)
+	admin_products.Post("/new", midw.Idempotency, endpoint.CreateNewProduct)
 	admin_products.Patch("/:id/update", endpoint.UpdateProductDetails)
 	admin_products.Delete("/:id/delete", endpoint.DeleteProduct)
 	admin_products.Patch("/:id/update-discount", endpoint.SetProductDiscount)
 	admin_products.Patch("/:id/update-stock", endpoint.UpdateProductStock)
+	admin_products.Get("/:id/stock-movements", endpoint.GetProductStockMovements)
+
+	// ### -----------------------PROMOTIONS-----------------------
+	// Promotion Routes (5)
+	promotions := api.Group("/promotions", midw.AuthMiddleware, midw.Admin)
+	promotions.Post("/", endpoint.CreatePromotion)
+	promotions.Get("/", endpoint.GetAllPromotions)
+	promotions.Get("/:id", endpoint.GetPromotion)
+	promotions.Put("/:id", endpoint.UpdatePromotion)
+	promotions.Delete("/:id", endpoint.DeletePromotion)
+
+	// ### -----------------------CART-----------------------
+	// Cart Routes (5)
+	cart := api.Group("/cart", midw.OptionalAuth)
+	cart.Get("/", endpoint.GetCart)
+	cart.Post("/items", endpoint.AddCartItem)
+	cart.Patch("/items/:productId", endpoint.UpdateCartItem)
+	cart.Delete("/items/:productId", endpoint.RemoveCartItem)
+	cart.Delete("/", endpoint.ClearCart)
+
+	// ### -----------------------SHIPPING-----------------------
+	// Shipping Method Routes (4)
+	shipping := api.Group("/shipping-methods")
+	shipping.Get("/", midw.OptionalAuth, endpoint.GetShippingMethods)
+	shipping.Post("/", midw.AuthMiddleware, midw.Admin, endpoint.CreateShippingMethod)
+	shipping.Put("/:id", midw.AuthMiddleware, midw.Admin, endpoint.UpdateShippingMethod)
+	shipping.Delete("/:id", midw.AuthMiddleware, midw.Admin, endpoint.DeleteShippingMethod)
+
+	// ### -----------------------TAX-----------------------
+	// Tax Rate Routes (3)
+	taxRates := api.Group("/tax-rates", midw.AuthMiddleware, midw.Admin)
+	taxRates.Get("/", endpoint.GetTaxRates)
+	taxRates.Put("/", endpoint.SetTaxRate)
+	taxRates.Delete("/:category", endpoint.DeleteTaxRate)
+
+	// ### -----------------------COUPONS-----------------------
+	// Coupon Routes (4)
+	coupons := api.Group("/coupons")
+	coupons.Post("/validate", midw.AuthMiddleware, endpoint.ValidateCoupon)
+	coupons.Post("/", midw.AuthMiddleware, midw.Admin, endpoint.CreateCoupon)
+	coupons.Get("/", midw.AuthMiddleware, midw.Admin, endpoint.GetAllCoupons)
+	coupons.Delete("/:id", midw.AuthMiddleware, midw.Admin, endpoint.DeactivateCoupon)
+
+	// ### -----------------------ORDERS-----------------------
+	// Order Routes (9)
+	orders := api.Group("/orders", midw.AuthMiddleware)
+	orders.Get("/", midw.Admin, endpoint.GetAllOrders)
+	orders.Get("/export", midw.Admin, endpoint.ExportOrders)
+	orders.Patch("/status", midw.Admin, endpoint.BulkUpdateOrderStatus)
+	orders.Get("/:id", midw.Admin, endpoint.GetOrder)
+	orders.Post("/:id/notes", midw.Admin, endpoint.AddOrderNote)
+	orders.Patch("/:id/ship", midw.Admin, endpoint.ShipOrder)
+	orders.Get("/:id/invoice", endpoint.GetOrderInvoice)
+	orders.Get("/:id/invoices", endpoint.GetOrderInvoices)
+	orders.Get("/:id/invoices/:number", endpoint.GetOrderInvoiceDocument)
+
+	// ### -----------------------RETURNS-----------------------
+	// Return Routes (7)
+	returns := api.Group("/returns", midw.AuthMiddleware)
+	returns.Post("/", endpoint.CreateReturn)
+	returns.Get("/", endpoint.GetMyReturns)
+	returns.Get("/all", midw.Admin, endpoint.GetAllReturns)
+	returns.Get("/:id", endpoint.GetReturn)
+	returns.Patch("/:id/approve", midw.Admin, endpoint.ApproveReturn)
+	returns.Patch("/:id/reject", midw.Admin, endpoint.RejectReturn)
+	returns.Post("/:id/refund", midw.Admin, endpoint.RetryReturnRefund)
 
 	// ### -----------------------REVIEWS-----------------------
 	// Reviews Routes (1)
 	reviews := api.Group("/reviews")
-	reviews.Post("/product/:id/new", midw.AuthMiddleware, endpoint.CreateNewReview)
+	reviews.Post("/product/:id/new", midw.AuthMiddleware, midw.Idempotency, endpoint.CreateNewReview)
 
 	// ### -----------------------STRIPE-----------------------
-	// Reviews Routes (1)
+	// Stripe Routes (3)
 	stripe := api.Group("/stripe")
-	stripe.Post("/create-checkout-session", midw.AuthMiddleware, endpoint.CreateCheckoutSession)
-	stripe.Post("/create-payment-intent", midw.AuthMiddleware, endpoint.CreatePaymentIntent)
+	stripe.Post("/create-checkout-session", midw.AuthMiddleware, midw.Idempotency, endpoint.CreateCheckoutSession)
+	stripe.Post("/create-payment-intent", midw.AuthMiddleware, midw.Idempotency, endpoint.CreatePaymentIntent)
+	stripe.Post("/webhook", endpoint.HandleStripeWebhook)
 }
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	auth "github.com/DanSmirnov48/techno-trades-go-backend/authentication"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	sessionManager = managers.SessionManager{}
+)
+
+func (endpoint Endpoint) GetMySessions(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	sessions := sessionManager.GetAll(db, user.ID)
+
+	response := schemas.SessionsResponseSchema{
+		ResponseSchema: SuccessResponse("Sessions fetched successfully"),
+		Data:           schemas.SessionsDataSchema{}.Init(sessions, RequestSession(c).ID),
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) RevokeSession(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	sessionId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	session, errCode, errData := sessionManager.GetForUser(db, user.ID, *sessionId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	sessionManager.Revoke(db, session)
+
+	// Revoking the current session is the same as logging out
+	if session.ID == RequestSession(c).ID {
+		auth.RemoveAuthCookie(c, auth.AccessToken)
+		auth.RemoveAuthCookie(c, auth.RefreshToken)
+	}
+
+	return c.Status(200).JSON(SuccessResponse("Session revoked successfully"))
+}
+
+// LogoutEverywhere signs the user out on every device, this one included
+func (endpoint Endpoint) LogoutEverywhere(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	sessionManager.RevokeAll(db, user.ID)
+
+	auth.RemoveAuthCookie(c, auth.AccessToken)
+	auth.RemoveAuthCookie(c, auth.RefreshToken)
+
+	return c.Status(200).JSON(SuccessResponse("Logged out of all sessions"))
+}
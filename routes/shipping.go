@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"strings"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	shippingManager = managers.ShippingManager{}
+)
+
+func (endpoint Endpoint) GetShippingMethods(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	// Buyers only see what they can pick, staff can ask for everything
+	activeOnly := true
+	user := OptionalRequestUser(c)
+	if c.Query("all") == "true" && user != nil && user.AccountType != models.AccountTypeBuyer {
+		activeOnly = false
+	}
+	methods := shippingManager.GetAll(db, activeOnly, strings.ToUpper(c.Query("country")))
+
+	response := schemas.ShippingMethodsResponseSchema{
+		ResponseSchema: SuccessResponse("Shipping methods fetched successfully"),
+		Data:           schemas.ShippingMethodsDataSchema{ShippingMethods: methods, Length: len(methods)},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) CreateShippingMethod(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.CreateShippingMethod{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	method, errCode, errData := shippingManager.Create(db, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.ShippingMethodResponseSchema{
+		ResponseSchema: SuccessResponse("Shipping method created successfully"),
+		Data:           schemas.ShippingMethodDataSchema{ShippingMethod: method},
+	}
+	return c.Status(201).JSON(response)
+}
+
+func (endpoint Endpoint) UpdateShippingMethod(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.CreateShippingMethod{}
+
+	methodId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	method, errCode, errData := shippingManager.GetById(db, *methodId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	method, errCode, errData = shippingManager.Update(db, method, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.ShippingMethodResponseSchema{
+		ResponseSchema: SuccessResponse("Shipping method updated successfully"),
+		Data:           schemas.ShippingMethodDataSchema{ShippingMethod: method},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) DeleteShippingMethod(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	methodId, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(err)
+	}
+
+	method, errCode, errData := shippingManager.GetById(db, *methodId)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if errCode, errData := shippingManager.Delete(db, method); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	return c.Status(200).JSON(SuccessResponse("Shipping method deleted successfully"))
+}
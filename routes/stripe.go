@@ -2,36 +2,64 @@ package routes
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 
 	"github.com/DanSmirnov48/techno-trades-go-backend/config"
 	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
 	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
 	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/stripe/stripe-go/v81"
-	"github.com/stripe/stripe-go/v81/checkout/session"
-	"github.com/stripe/stripe-go/v81/customer"
-	"github.com/stripe/stripe-go/v81/paymentintent"
-	"github.com/stripe/stripe-go/v81/webhook"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// Initialize Stripe
-func init() {
-	stripe.Key = config.GetConfig().StripeSecretKey
-	productManager = managers.ProductManager{}
+var (
+	orderManager = managers.OrderManager{}
+)
+
+type CreateCheckOutSchema struct {
+	CouponCode       string    `json:"coupon_code" validate:"omitempty,max=50" example:"WELCOME10"`
+	AddressId        uuid.UUID `json:"address_id" validate:"required" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	ShippingMethodId uuid.UUID `json:"shipping_method_id" validate:"required" example:"3e1ad5a4-35a6-4cc8-8cfa-3d2f0b2f4e71"`
 }
 
-type OrderItem struct {
-	ProductID string `json:"product_id"`
-	Quantity  int    `json:"quantity"`
+// shippingDetails hands the order's delivery snapshot to the payment provider
+func shippingDetails(order *models.Order) *payments.ShippingAddress {
+	address := order.ShippingAddress
+	return &payments.ShippingAddress{
+		Name:       address.FullName,
+		Phone:      address.Phone,
+		Line1:      address.Line1,
+		Line2:      address.Line2,
+		City:       address.City,
+		State:      address.Region,
+		PostalCode: address.PostalCode,
+		Country:    address.Country,
+	}
 }
 
-type CreateCheckOutSchema struct {
-	Orders []OrderItem `json:"orders"`
+// orderFromCart turns the user's stored cart into a pending order with its
+// stock reserved. Prices come from the pricing manager, never the client.
+func orderFromCart(db *gorm.DB, user *models.User, data CreateCheckOutSchema) (*models.Order, *int, *utils.ErrorResponse) {
+	cart, errCode, errData := cartManager.GetOrCreate(db, user.ID)
+	if errCode != nil {
+		return nil, errCode, errData
+	}
+
+	orderItems, errCode, errData := cartManager.OrderItems(db, cart)
+	if errCode != nil {
+		return nil, errCode, errData
+	}
+
+	return orderManager.Create(db, user.ID, orderItems, managers.OrderOptions{
+		CouponCode:       data.CouponCode,
+		AddressId:        &data.AddressId,
+		ShippingMethodId: &data.ShippingMethodId,
+	})
 }
 
 func (endpoint Endpoint) CreateCheckoutSession(c *fiber.Ctx) error {
@@ -42,107 +70,93 @@ func (endpoint Endpoint) CreateCheckoutSession(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
-	// Validate that orders array is not empty
-	if len(data.Orders) == 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "Orders cannot be empty"})
-	}
-
-	// Create a Stripe customer with metadata
-	params := &stripe.CustomerParams{
-		Name:  stripe.String(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
-		Email: stripe.String(user.Email),
-		Metadata: map[string]string{
-			"userId": user.ID.String(),
-			"cart":   serializeOrders(data.Orders),
-		},
+	// Persist the order and reserve its stock before handing over to the payment provider
+	order, errCode, errData := orderFromCart(db, user, data)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
 	}
 
-	customer, err := customer.New(params)
-	if err != nil {
-		log.Printf("Stripe customer creation error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create customer"})
+	shippingMethod, errCode, errData := shippingManager.GetById(db, *order.ShippingMethodId)
+	if errCode != nil {
+		orderManager.UpdateStatus(db, order, models.OrderStatusCancelled)
+		return c.Status(*errCode).JSON(errData)
 	}
 
-	// Calculate total amount for verification
-	var totalAmount int64 = 0
-
 	// Prepare line items for each product in the order
-	var lineItems []*stripe.CheckoutSessionLineItemParams
-	for _, item := range data.Orders {
-		// Validate quantity
-		if item.Quantity <= 0 {
-			return c.Status(400).JSON(fiber.Map{"error": "Quantity must be greater than 0"})
-		}
-
-		// Fetch product details from your database
-		var dbProduct models.Product
-		if err := db.First(&dbProduct, "id = ?", item.ProductID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return c.Status(404).JSON(fiber.Map{"error": fmt.Sprintf("Product not found for ID: %s", item.ProductID)})
-			}
-			log.Printf("Database error when fetching product: %v", err)
-			return c.Status(500).JSON(fiber.Map{"error": "Internal server error"})
-		}
+	var lineItems []payments.LineItem
+	for _, item := range order.Items {
+		lineItems = append(lineItems, payments.LineItem{
+			Name:       item.Name,
+			UnitAmount: item.UnitPrice.Amount,
+			Quantity:   int64(item.Quantity),
+			Metadata: map[string]string{
+				"id": item.ProductId.String(),
+			},
+		})
+	}
 
-		// Check stock availability
-		if item.Quantity > dbProduct.CountInStock {
-			return c.Status(400).JSON(fiber.Map{
-				"error": fmt.Sprintf("Insufficient stock for product: %s. Available: %d, Requested: %d",
-					dbProduct.Name, dbProduct.CountInStock, item.Quantity),
-			})
-		}
+	// Net prices need the VAT charged as its own line
+	if order.TaxMode == models.TaxModeExclusive && order.Tax.Amount > 0 {
+		lineItems = append(lineItems, payments.LineItem{
+			Name:       "VAT",
+			UnitAmount: order.Tax.Amount,
+			Quantity:   1,
+		})
+	}
 
-		// Add to total amount for verification
-		totalAmount += int64(dbProduct.Price*100) * int64(item.Quantity)
-
-		// Define line item with product data and quantity
-		lineItem := &stripe.CheckoutSessionLineItemParams{
-			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-				Currency: stripe.String("gbp"),
-				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-					Name: stripe.String(dbProduct.Name),
-					Metadata: map[string]string{
-						"id":             dbProduct.ID.String(),
-						"original_price": fmt.Sprintf("%d", dbProduct.Price),
-					},
-				},
-				UnitAmount: stripe.Int64(int64(dbProduct.Price * 100)),
-			},
-			Quantity: stripe.Int64(int64(item.Quantity)),
-		}
-		lineItems = append(lineItems, lineItem)
+	// The user's customer is reused across checkouts
+	customerId, errCode, errData := endpoint.stripeCustomer(db, user)
+	if errCode != nil {
+		orderManager.UpdateStatus(db, order, models.OrderStatusCancelled)
+		return c.Status(*errCode).JSON(errData)
 	}
 
 	// Create the checkout session
-	sessionParams := &stripe.CheckoutSessionParams{
-		PaymentMethodTypes: stripe.StringSlice([]string{"card"}),
-		LineItems:          lineItems,
-		Mode:               stripe.String(string(stripe.CheckoutSessionModePayment)),
-		Customer:           stripe.String(customer.ID),
-		SuccessURL:         stripe.String(fmt.Sprintf("%s/checkout/success?session_id={CHECKOUT_SESSION_ID}", config.GetConfig().FrontendURL)),
-		CancelURL:          stripe.String(fmt.Sprintf("%s/checkout/cancel", config.GetConfig().FrontendURL)),
-		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
-			Metadata: map[string]string{
-				"userId":      user.ID.String(),
-				"totalAmount": fmt.Sprintf("%d", totalAmount),
-				"orderCount":  fmt.Sprintf("%d", len(data.Orders)),
-			},
+	checkout, err := endpoint.Payments.CreateCheckout(payments.CheckoutParams{
+		CustomerID:        customerId,
+		Currency:          order.Total.Currency,
+		LineItems:         lineItems,
+		SuccessURL:        fmt.Sprintf("%s/checkout/success?session_id={CHECKOUT_SESSION_ID}", config.GetConfig().FrontendURL),
+		CancelURL:         fmt.Sprintf("%s/checkout/cancel", config.GetConfig().FrontendURL),
+		ClientReferenceID: order.ID.String(),
+		ExpiresAt:         payments.CheckoutExpiry(*order.ReservedUntil),
+		DiscountAmount:    order.Discount.Amount,
+		DiscountName:      order.CouponCode,
+		Shipping:          shippingDetails(order),
+		ShippingRate: &payments.ShippingRate{
+			Name:    order.ShippingMethodName,
+			Amount:  order.Shipping.Amount,
+			MinDays: shippingMethod.MinDays,
+			MaxDays: shippingMethod.MaxDays,
+		},
+		PaymentMetadata: map[string]string{
+			"userId":      user.ID.String(),
+			"orderId":     order.ID.String(),
+			"totalAmount": fmt.Sprintf("%d", order.Total.Amount),
+			"orderCount":  fmt.Sprintf("%d", len(order.Items)),
 		},
 		Metadata: map[string]string{
 			"userId":     user.ID.String(),
-			"totalItems": fmt.Sprintf("%d", len(data.Orders)),
+			"orderId":    order.ID.String(),
+			"totalItems": fmt.Sprintf("%d", len(order.Items)),
 		},
-	}
-
-	session, err := session.New(sessionParams)
+	})
 	if err != nil {
 		log.Printf("Stripe session creation error: %v", err)
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create checkout session"})
+		orderManager.UpdateStatus(db, order, models.OrderStatusCancelled)
+		return c.Status(500).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create checkout session"))
+	}
+
+	if _, errCode, errData := orderManager.SetCheckoutSession(db, order, checkout.ID); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
 	}
 
 	return c.Status(200).JSON(fiber.Map{
-		"success": true,
-		"url":     session.URL,
+		"success":        true,
+		"url":            checkout.URL,
+		"order_id":       order.ID,
+		"order_number":   order.OrderNumber,
+		"reserved_until": order.ReservedUntil,
 	})
 }
 
@@ -154,136 +168,177 @@ func (endpoint Endpoint) CreatePaymentIntent(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
-	customer, err := customer.New(&stripe.CustomerParams{
-		Name:  stripe.String(fmt.Sprintf("%s %s", user.FirstName, user.LastName)),
-		Email: stripe.String(user.Email),
-		Metadata: map[string]string{
-			"userId": user.ID.String(),
-			"cart":   serializeOrders(data.Orders),
-		},
-	})
-
-	if err != nil {
-		log.Printf("Stripe customer creation error: %v", err)
-		return c.Status(500).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create customer"))
+	// Persist the order and reserve its stock before handing over to the payment provider
+	order, errCode, errData := orderFromCart(db, user, data)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
 	}
 
-	// Calculate total amount
-	var totalAmount int64 = 0
-	for _, item := range data.Orders {
-
-		productId, err := utils.ParseUUID(item.ProductID)
-		if err != nil {
-			return c.Status(400).JSON(err)
-		}
-
-		product, errCode, errData := productManager.GetById(db, *productId)
-		if errCode != nil {
-			return c.Status(*errCode).JSON(errData)
-		}
-
-		if item.Quantity > product.CountInStock {
-			return c.Status(404).JSON(utils.RequestErr(utils.ERR_NON_EXISTENT, "Insufficient product stock"))
-		}
-
-		// Add to total amount
-		totalAmount += int64(product.Price*100) * int64(item.Quantity)
+	customerId, errCode, errData := endpoint.stripeCustomer(db, user)
+	if errCode != nil {
+		orderManager.UpdateStatus(db, order, models.OrderStatusCancelled)
+		return c.Status(*errCode).JSON(errData)
 	}
 
 	// Create a PaymentIntent with amount and currency
-	pi, err := paymentintent.New(&stripe.PaymentIntentParams{
-		Amount:             stripe.Int64(totalAmount),
-		Customer:           stripe.String(customer.ID),
-		Currency:           stripe.String(string(stripe.CurrencyGBP)),
-		PaymentMethodTypes: stripe.StringSlice([]string{"card", "paypal"}),
+	pi, err := endpoint.Payments.CreateIntent(payments.IntentParams{
+		Amount:             order.Total.Amount,
+		CustomerID:         customerId,
+		Currency:           order.Total.Currency,
+		PaymentMethodTypes: []string{"card", "paypal"},
+		Shipping:           shippingDetails(order),
 		Metadata: map[string]string{
 			"userId":      user.ID.String(),
-			"cart":        serializeOrders(data.Orders),
-			"totalAmount": fmt.Sprintf("%d", totalAmount),
+			"orderId":     order.ID.String(),
+			"orderNumber": order.OrderNumber,
+			"totalAmount": fmt.Sprintf("%d", order.Total.Amount),
 		},
 	})
 	if err != nil {
 		log.Printf("Stripe session creation error: %v", err)
+		orderManager.UpdateStatus(db, order, models.OrderStatusCancelled)
 		return c.Status(500).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create checkout session"))
 	}
 
+	if _, errCode, errData := orderManager.SetPaymentIntent(db, order, pi.ID); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
 	return c.Status(200).JSON(fiber.Map{
-		"success":      true,
-		"clientSecret": pi.ClientSecret,
+		"success":        true,
+		"clientSecret":   pi.ClientSecret,
+		"order_id":       order.ID,
+		"order_number":   order.OrderNumber,
+		"reserved_until": order.ReservedUntil,
 	})
 }
 
-func serializeOrders(orders []OrderItem) string {
-	data, _ := json.Marshal(orders)
-	return string(data)
-}
-
 func (endpoint Endpoint) HandleStripeWebhook(c *fiber.Ctx) error {
-	// Read the request body
-	webhookSecret := config.GetConfig().StripeTestKey
+	db := endpoint.DB
 	stripeSignature := c.Get("Stripe-Signature")
 	body := c.Body()
 
 	// Verify webhook signature
-	event, err := webhook.ConstructEvent(body, stripeSignature, webhookSecret)
+	event, err := endpoint.Payments.VerifyWebhook(body, stripeSignature)
 	if err != nil {
 		log.Printf("Webhook signature verification failed: %v", err)
-		return c.Status(400).JSON(fiber.Map{"error": "Webhook signature verification failed"})
+		return c.Status(400).JSON(utils.RequestErr(utils.ERR_INVALID_REQUEST, "Webhook signature verification failed"))
+	}
+
+	// Record the event and apply it in one transaction, so a failure lets Stripe retry
+	// and a replay of an already processed event is a no-op.
+	duplicate := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.StripeEvent{ID: event.ID, Type: string(event.Type)})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			duplicate = true
+			return nil
+		}
+		return processStripeEvent(tx, event)
+	})
+	if err != nil {
+		log.Printf("Webhook %s (%s) processing failed: %v", event.ID, event.Type, err)
+		return c.Status(500).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to process webhook"))
 	}
 
-	// Handle the checkout.session.completed event
-	if event.Type == "checkout.session.completed" {
+	return c.Status(200).JSON(fiber.Map{"received": true, "duplicate": duplicate})
+}
+
+func processStripeEvent(db *gorm.DB, event stripe.Event) error {
+	switch event.Type {
+	case stripe.EventTypeCheckoutSessionCompleted:
 		var session stripe.CheckoutSession
-		err := json.Unmarshal(event.Data.Raw, &session)
-		if err != nil {
-			log.Printf("Error parsing webhook JSON: %v", err)
-			return c.Status(400).JSON(fiber.Map{"error": "Error parsing webhook data"})
+		if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
+			return err
 		}
+		order := findWebhookOrder(db, session.Metadata, func() (*models.Order, *int, *utils.ErrorResponse) {
+			return orderManager.GetByCheckoutSession(db, session.ID)
+		})
+		// Delayed payment methods complete the session unpaid; payment_intent.succeeded follows
+		if order == nil || session.PaymentStatus != stripe.CheckoutSessionPaymentStatusPaid {
+			return nil
+		}
+		paymentIntentId := ""
+		if session.PaymentIntent != nil {
+			paymentIntentId = session.PaymentIntent.ID
+		}
+		return webhookErr(orderManager.MarkPaid(db, order, paymentIntentId))
 
-		// Print the full session object for inspection
-		prettyJSON, _ := json.MarshalIndent(session, "", "    ")
-		log.Printf("Full Checkout Session:\n%s\n", string(prettyJSON))
-
-		// Print specific important fields
-		log.Printf("\n=== Checkout Session Summary ===")
-		log.Printf("Session ID: %s", session.ID)
-		log.Printf("Customer ID: %s", session.Customer.ID)
-		log.Printf("Customer Email: %s", session.CustomerEmail)
-		log.Printf("Payment Status: %s", session.PaymentStatus)
-		log.Printf("Amount Total: %d", session.AmountTotal)
-		log.Printf("Currency: %s", session.Currency)
-
-		// Print metadata
-		log.Printf("\n=== Metadata ===")
-		for key, value := range session.Metadata {
-			log.Printf("%s: %s", key, value)
+	case stripe.EventTypePaymentIntentSucceeded:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return err
+		}
+		order := findWebhookOrder(db, pi.Metadata, func() (*models.Order, *int, *utils.ErrorResponse) {
+			return orderManager.GetByPaymentIntent(db, pi.ID)
+		})
+		if order == nil {
+			return nil
 		}
+		return webhookErr(orderManager.MarkPaid(db, order, pi.ID))
 
-		// Print line items
-		log.Printf("\n=== Line Items ===")
-		for _, item := range session.LineItems.Data {
-			log.Printf("\nItem Description: %s", item.Description)
-			log.Printf("Quantity: %d", item.Quantity)
-			log.Printf("Unit Amount: %d", item.Price.UnitAmount)
-			log.Printf("Product ID: %s", item.Price.Product.ID)
-
-			log.Printf("Item Metadata:")
-			for key, value := range item.Price.Product.Metadata {
-				log.Printf("  %s: %s", key, value)
-			}
+	case stripe.EventTypePaymentIntentPaymentFailed:
+		var pi stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &pi); err != nil {
+			return err
+		}
+		order := findWebhookOrder(db, pi.Metadata, func() (*models.Order, *int, *utils.ErrorResponse) {
+			return orderManager.GetByPaymentIntent(db, pi.ID)
+		})
+		if order == nil {
+			return nil
 		}
+		reason := "Payment failed"
+		if pi.LastPaymentError != nil && pi.LastPaymentError.Msg != "" {
+			reason = pi.LastPaymentError.Msg
+		}
+		return webhookErr(orderManager.RecordPaymentFailure(db, order, reason))
 
-		// Print customer details
-		if session.Customer != nil {
-			log.Printf("\n=== Customer Details ===")
-			log.Printf("Name: %s", session.Customer.Name)
-			log.Printf("Email: %s", session.Customer.Email)
-			log.Printf("Customer Metadata:")
-			for key, value := range session.Customer.Metadata {
-				log.Printf("  %s: %s", key, value)
+	case stripe.EventTypeChargeRefunded:
+		var charge stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return err
+		}
+		order := findWebhookOrder(db, charge.Metadata, func() (*models.Order, *int, *utils.ErrorResponse) {
+			if charge.PaymentIntent == nil {
+				return nil, nil, nil
 			}
+			return orderManager.GetByPaymentIntent(db, charge.PaymentIntent.ID)
+		})
+		if order == nil {
+			return nil
 		}
+		return webhookErr(orderManager.RecordRefund(db, order, models.NewMoney(charge.AmountRefunded, string(charge.Currency)), charge.Refunded))
+	}
+
+	return nil
+}
+
+// findWebhookOrder resolves the order an event refers to, preferring the orderId
+// we put in the metadata and falling back to the Stripe object reference. The
+// order comes back locked, so the expiry job can't change it under the event.
+func findWebhookOrder(db *gorm.DB, metadata map[string]string, fallback func() (*models.Order, *int, *utils.ErrorResponse)) *models.Order {
+	var order *models.Order
+	if orderId, err := uuid.Parse(metadata["orderId"]); err == nil {
+		order, _, _ = orderManager.GetById(db, orderId)
+	}
+	if order == nil {
+		order, _, _ = fallback()
+	}
+	if order == nil {
+		log.Printf("Webhook: no order found for event object (metadata: %v)", metadata)
+		return nil
 	}
+	locked, _, _ := orderManager.GetForUpdate(db, order.ID)
+	return locked
+}
 
-	return c.Status(200).JSON(fiber.Map{"received": true})
+func webhookErr(_ *models.Order, errCode *int, errData *utils.ErrorResponse) error {
+	if errCode == nil {
+		return nil
+	}
+	return errData
 }
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+)
+
+var (
+	taxManager = managers.TaxManager{}
+)
+
+func (endpoint Endpoint) GetTaxRates(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	rates := taxManager.GetCategoryRates(db)
+
+	response := schemas.TaxRatesResponseSchema{
+		ResponseSchema: SuccessResponse("Tax rates fetched successfully"),
+		Data:           schemas.TaxRatesDataSchema{Mode: taxManager.Mode(), TaxRates: rates},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) SetTaxRate(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.SetTaxRate{}
+
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	rate, errCode, errData := taxManager.SetCategoryRate(db, reqData)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.TaxRateResponseSchema{
+		ResponseSchema: SuccessResponse("Tax rate saved successfully"),
+		Data:           schemas.TaxRateDataSchema{TaxRate: rate},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) DeleteTaxRate(c *fiber.Ctx) error {
+	db := endpoint.DB
+
+	if errCode, errData := taxManager.DeleteCategoryRate(db, c.Params("category")); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	return c.Status(200).JSON(SuccessResponse("Tax rate deleted successfully"))
+}
@@ -0,0 +1,114 @@
+package routes
+
+import (
+	"encoding/base64"
+
+	auth "github.com/DanSmirnov48/techno-trades-go-backend/authentication"
+	"github.com/DanSmirnov48/techno-trades-go-backend/config"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var (
+	totpManager = managers.TotpManager{}
+)
+
+func (endpoint Endpoint) SetupTotp(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+
+	secret, errCode, errData := totpManager.Setup(db, user)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	uri := utils.TotpProvisioningURI(secret, managers.TotpIssuer, user.Email)
+	png, err := utils.TotpQRCode(uri)
+	if err != nil {
+		return c.Status(500).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to create QR code"))
+	}
+
+	response := schemas.TotpSetupResponseSchema{
+		ResponseSchema: SuccessResponse("Scan the QR code with your authenticator app"),
+		Data: schemas.TotpSetupSchema{
+			Secret:          secret,
+			ProvisioningURI: uri,
+			QRCode:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) ConfirmTotp(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.TotpCodeSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	codes, errCode, errData := totpManager.Confirm(db, user, reqData.Code)
+	if errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	response := schemas.RecoveryCodesResponseSchema{
+		ResponseSchema: SuccessResponse("Two-factor authentication enabled. Keep your recovery codes somewhere safe"),
+		Data:           schemas.RecoveryCodesSchema{RecoveryCodes: codes},
+	}
+	return c.Status(200).JSON(response)
+}
+
+func (endpoint Endpoint) DisableTotp(c *fiber.Ctx) error {
+	db := endpoint.DB
+	user := RequestUser(c)
+	reqData := schemas.TotpCodeSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	if user.RequiresTotp(config.GetConfig().StaffRequireTotp) {
+		return c.Status(403).JSON(utils.RequestErr(utils.ERR_NOT_ALLOWED, "Two-factor authentication is required for staff accounts"))
+	}
+
+	if errCode, errData := totpManager.Disable(db, user, reqData.Code); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+	return c.Status(200).JSON(SuccessResponse("Two-factor authentication disabled"))
+}
+
+// VerifyTotpLogin finishes a login that stopped for a two-factor code
+func (endpoint Endpoint) VerifyTotpLogin(c *fiber.Ctx) error {
+	db := endpoint.DB
+	reqData := schemas.VerifyTotpLoginSchema{}
+
+	// Validate request
+	if errCode, errData := ValidateRequest(c, &reqData); errData != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	userId, ok := auth.DecodeMfaToken(reqData.MfaToken)
+	if !ok {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, "Two-factor login has expired, log in again"))
+	}
+
+	user := models.User{ID: *userId}
+	db.Take(&user, user)
+	if user.ID == uuid.Nil {
+		return c.Status(401).JSON(utils.RequestErr(utils.ERR_INVALID_TOKEN, "Two-factor login has expired, log in again"))
+	}
+
+	if errCode, errData := totpManager.Verify(db, &user, reqData.Code); errCode != nil {
+		return c.Status(*errCode).JSON(errData)
+	}
+
+	return startSession(c, db, &user, "Login successful")
+}
@@ -63,6 +63,9 @@ func (endpoint Endpoint) UpdateSignedInUserPassword(c *fiber.Ctx) error {
 	// Update Users Password
 	db.Model(&user).Updates(map[string]interface{}{"Password": passwordSchema.NewPassword})
 
+	// Keep this device signed in and sign out the rest
+	sessionManager.RevokeAll(db, user.ID, RequestSession(c).ID)
+
 	response := schemas.SingleUserResponseSchem{
 		ResponseSchema: SuccessResponse("Password updated successfully"),
 		Data:           schemas.UserResponseSchem{Users: user},
@@ -113,6 +116,8 @@ func (endpoint Endpoint) UpdateUserEmail(c *fiber.Ctx) error {
 	// Update Users Email & Delete Otp
 	db.Model(&user).Updates(map[string]interface{}{"email": emailSchema.NewEmail})
 	db.Delete(&otp)
+	user.Email = emailSchema.NewEmail
+	endpoint.syncStripeCustomer(user)
 
 	response := schemas.SingleUserResponseSchem{
 		ResponseSchema: SuccessResponse("Email updated successfully"),
@@ -128,6 +133,7 @@ func (endpoint Endpoint) DeleteMe(c *fiber.Ctx) error {
 	if err := db.Delete(&user).Error; err != nil {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Could not delete user"))
 	}
+	sessionManager.RevokeAll(db, user.ID)
 
 	return c.Status(200).JSON(SuccessResponse("User deleted successfully"))
 }
@@ -142,11 +148,13 @@ func (endpoint Endpoint) UpdateMe(c *fiber.Ctx) error {
 		return c.Status(*errCode).JSON(errData)
 	}
 
+	updateMeSchema.VATNumber = models.NormalizeVATNumber(updateMeSchema.VATNumber)
 	if err := db.Model(&user).
 		Clauses(clause.Returning{}).
 		Updates(updateMeSchema).Error; err != nil {
 		return c.Status(401).JSON(utils.RequestErr(utils.ERR_SERVER_ERROR, "Failed to update user information"))
 	}
+	endpoint.syncStripeCustomer(user)
 
 	response := schemas.SingleUserResponseSchem{
 		ResponseSchema: SuccessResponse("User updated successfully"),
@@ -11,3 +11,17 @@ func (obj ResponseSchema) Init() ResponseSchema {
 	}
 	return obj
 }
+
+type PaginationSchema struct {
+	Page  int   `json:"page" example:"1"`
+	Limit int   `json:"limit" example:"10"`
+	Total int64 `json:"total" example:"42"`
+	Pages int   `json:"pages" example:"5"`
+}
+
+func (obj PaginationSchema) Init() PaginationSchema {
+	if obj.Limit > 0 {
+		obj.Pages = int((obj.Total + int64(obj.Limit) - 1) / int64(obj.Limit))
+	}
+	return obj
+}
@@ -0,0 +1,40 @@
+package schemas
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/google/uuid"
+)
+
+// REQUEST BODY SCHEMAS
+type AddCartItem struct {
+	ProductID string `json:"product_id" validate:"required" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Quantity  int    `json:"quantity" validate:"required,gt=0" example:"1"`
+}
+
+type UpdateCartItem struct {
+	Quantity int `json:"quantity" validate:"required,gt=0" example:"2"`
+}
+
+// RESPONSE BODY SCHEMAS
+type CartLineSchema struct {
+	ProductId uuid.UUID    `json:"product_id" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Name      string       `json:"name" example:"Sony PlayStation 5"`
+	Slug      string       `json:"slug" example:"sony-playstation-5"`
+	UnitPrice models.Money `json:"unit_price"`
+	Quantity  int          `json:"quantity" example:"1"`
+	Available int          `json:"available" example:"12"`
+	LineTotal models.Money `json:"line_total"`
+}
+
+type CartSchema struct {
+	ID       uuid.UUID        `json:"id" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Items    []CartLineSchema `json:"items"`
+	Subtotal models.Money     `json:"subtotal"`
+	// Changes made while revalidating, e.g. quantities cut to what's in stock
+	Warnings []string `json:"warnings"`
+}
+
+type CartResponseSchema struct {
+	ResponseSchema
+	Data CartSchema `json:"data"`
+}
@@ -0,0 +1,63 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+)
+
+// REQUEST BODY SCHEMAS
+type CreateCoupon struct {
+	Code           string            `json:"code" validate:"required,min=3,max=40,alphanum" example:"WELCOME10"`
+	Type           models.CouponType `json:"type" validate:"required,oneof=percentage fixed" example:"percentage"`
+	Value          int64             `json:"value" validate:"required,gt=0" example:"10"`
+	Currency       string            `json:"currency" validate:"omitempty,len=3,alpha" example:"gbp"`
+	MinBasket      int64             `json:"min_basket" validate:"min=0" example:"5000"`
+	MaxRedemptions int               `json:"max_redemptions" validate:"min=0" example:"100"`
+	MaxPerUser     int               `json:"max_per_user" validate:"min=0" example:"1"`
+	Categories     []string          `json:"categories" validate:"omitempty,dive,required,max=50" example:"consoles"`
+	ExpiresAt      *time.Time        `json:"expires_at" example:"2024-12-31T23:59:59Z"`
+}
+
+type CartItemSchema struct {
+	ProductID string `json:"product_id" validate:"required" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Quantity  int    `json:"quantity" validate:"required,gt=0" example:"1"`
+}
+
+type ValidateCoupon struct {
+	Code string `json:"code" validate:"required,max=50" example:"WELCOME10"`
+	// Defaults to the user's stored cart
+	Items []CartItemSchema `json:"items" validate:"omitempty,dive"`
+}
+
+// RESPONSE BODY SCHEMAS
+type CouponDataSchema struct {
+	Coupon *models.Coupon `json:"coupon"`
+}
+
+type CouponResponseSchema struct {
+	ResponseSchema
+	Data CouponDataSchema `json:"data"`
+}
+
+type CouponsDataSchema struct {
+	Coupons []*models.Coupon `json:"coupons"`
+	Length  int              `json:"length"`
+}
+
+type CouponsResponseSchema struct {
+	ResponseSchema
+	Data CouponsDataSchema `json:"data"`
+}
+
+type CouponValidationDataSchema struct {
+	Code     string       `json:"code" example:"WELCOME10"`
+	Subtotal models.Money `json:"subtotal"`
+	Discount models.Money `json:"discount"`
+	Total    models.Money `json:"total"`
+}
+
+type CouponValidationResponseSchema struct {
+	ResponseSchema
+	Data CouponValidationDataSchema `json:"data"`
+}
@@ -0,0 +1,14 @@
+package schemas
+
+import "github.com/DanSmirnov48/techno-trades-go-backend/models"
+
+// RESPONSE BODY SCHEMAS
+type InvoicesDataSchema struct {
+	Invoices []*models.Invoice `json:"invoices"`
+	Length   int               `json:"length"`
+}
+
+type InvoicesResponseSchema struct {
+	ResponseSchema
+	Data InvoicesDataSchema `json:"data"`
+}
@@ -0,0 +1,96 @@
+package schemas
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/google/uuid"
+)
+
+// REQUEST BODY SCHEMAS
+// Shipping needs a carrier and tracking number per order, so it isn't a bulk action
+type BulkOrderStatus struct {
+	OrderIds []uuid.UUID        `json:"order_ids" validate:"required,min=1,max=100"`
+	Status   models.OrderStatus `json:"status" validate:"required,oneof=fulfilled delivered" example:"fulfilled"`
+}
+
+type ShipOrder struct {
+	Carrier        string `json:"carrier" validate:"required,max=100" example:"Royal Mail"`
+	TrackingNumber string `json:"tracking_number" validate:"required,max=100" example:"RM123456785GB"`
+}
+
+type CreateOrderNote struct {
+	Body string `json:"body" validate:"required,max=2000" example:"Customer asked for a signed-for delivery"`
+}
+
+// RESPONSE BODY SCHEMAS
+type OrderDetailSchema struct {
+	*models.Order
+	PaymentStatus models.PaymentStatus `json:"payment_status" example:"paid"`
+	Cancellable   bool                 `json:"cancellable" example:"true"`
+	// Only filled in for staff, who see everyone's orders
+	CustomerEmail string `json:"customer_email,omitempty" example:"johndoe@email.com"`
+}
+
+func (obj OrderDetailSchema) Init(order *models.Order) OrderDetailSchema {
+	obj.Order = order
+	obj.PaymentStatus = order.PaymentStatus()
+	obj.Cancellable = order.IsCancellable()
+	obj.CustomerEmail = order.User.Email
+	return obj
+}
+
+type OrderDataSchema struct {
+	Order OrderDetailSchema `json:"order"`
+}
+
+type OrderResponseSchema struct {
+	ResponseSchema
+	Data OrderDataSchema `json:"data"`
+}
+
+type OrdersDataSchema struct {
+	Orders     []OrderDetailSchema `json:"orders"`
+	Pagination PaginationSchema    `json:"pagination"`
+}
+
+func (obj OrdersDataSchema) Init(orders []*models.Order) OrdersDataSchema {
+	obj.Orders = make([]OrderDetailSchema, 0, len(orders))
+	for _, order := range orders {
+		obj.Orders = append(obj.Orders, OrderDetailSchema{}.Init(order))
+	}
+	obj.Pagination = obj.Pagination.Init()
+	return obj
+}
+
+type OrdersResponseSchema struct {
+	ResponseSchema
+	Data OrdersDataSchema `json:"data"`
+}
+
+type StaffOrderDataSchema struct {
+	Order OrderDetailSchema   `json:"order"`
+	Notes []*models.OrderNote `json:"notes"`
+}
+
+type StaffOrderResponseSchema struct {
+	ResponseSchema
+	Data StaffOrderDataSchema `json:"data"`
+}
+
+type BulkOrderStatusDataSchema struct {
+	Updated []OrderDetailSchema  `json:"updated"`
+	Failed  map[uuid.UUID]string `json:"failed"`
+}
+
+type BulkOrderStatusResponseSchema struct {
+	ResponseSchema
+	Data BulkOrderStatusDataSchema `json:"data"`
+}
+
+type OrderNoteDataSchema struct {
+	Note *models.OrderNote `json:"note"`
+}
+
+type OrderNoteResponseSchema struct {
+	ResponseSchema
+	Data OrderNoteDataSchema `json:"data"`
+}
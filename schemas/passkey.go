@@ -0,0 +1,61 @@
+package schemas
+
+import (
+	"encoding/json"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/google/uuid"
+)
+
+// REQUEST BODY SCHEMAS
+type PasskeySignupSchema struct {
+	FirstName string `json:"first_name" validate:"required,max=50" example:"John"`
+	LastName  string `json:"last_name" validate:"required,max=50" example:"Doe"`
+	Email     string `json:"email" validate:"required,min=5,email" example:"johndoe@email.com"`
+}
+
+// FinishPasskeySchema carries the browser's PublicKeyCredential, exactly as
+// navigator.credentials returned it, back to the ceremony it answers
+type FinishPasskeySchema struct {
+	CeremonyId uuid.UUID       `json:"ceremony_id" validate:"required" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Credential json.RawMessage `json:"credential" validate:"required" swaggertype:"object"`
+}
+
+type FinishPasskeyRegistrationSchema struct {
+	FinishPasskeySchema
+	Name string `json:"name" validate:"max=100" example:"MacBook Touch ID"`
+}
+
+type RenamePasskeySchema struct {
+	Name string `json:"name" validate:"required,max=100" example:"MacBook Touch ID"`
+}
+
+// RESPONSE BODY SCHEMAS
+type PasskeyOptionsSchema struct {
+	CeremonyId uuid.UUID   `json:"ceremony_id" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Options    interface{} `json:"options"`
+}
+
+type PasskeyOptionsResponseSchema struct {
+	ResponseSchema
+	Data PasskeyOptionsSchema `json:"data"`
+}
+
+type PasskeyDataSchema struct {
+	Passkey *models.Passkey `json:"passkey"`
+}
+
+type PasskeyResponseSchema struct {
+	ResponseSchema
+	Data PasskeyDataSchema `json:"data"`
+}
+
+type PasskeysDataSchema struct {
+	Passkeys []*models.Passkey `json:"passkeys"`
+	Length   int               `json:"length"`
+}
+
+type PasskeysResponseSchema struct {
+	ResponseSchema
+	Data PasskeysDataSchema `json:"data"`
+}
@@ -0,0 +1,32 @@
+package schemas
+
+import "github.com/DanSmirnov48/techno-trades-go-backend/payments"
+
+// RESPONSE BODY SCHEMAS
+type PaymentMethodSchema struct {
+	ID       string `json:"id" example:"pm_1NG8Du2eZvKYlo2CUI79vXWy"`
+	Type     string `json:"type" example:"card"`
+	Brand    string `json:"brand,omitempty" example:"visa"`
+	Last4    string `json:"last4,omitempty" example:"4242"`
+	ExpMonth int64  `json:"exp_month,omitempty" example:"12"`
+	ExpYear  int64  `json:"exp_year,omitempty" example:"2030"`
+}
+
+type PaymentMethodsDataSchema struct {
+	PaymentMethods []PaymentMethodSchema `json:"payment_methods"`
+	Length         int                   `json:"length"`
+}
+
+func (obj PaymentMethodsDataSchema) Init(methods []payments.PaymentMethod) PaymentMethodsDataSchema {
+	obj.PaymentMethods = make([]PaymentMethodSchema, 0, len(methods))
+	for _, method := range methods {
+		obj.PaymentMethods = append(obj.PaymentMethods, PaymentMethodSchema(method))
+	}
+	obj.Length = len(obj.PaymentMethods)
+	return obj
+}
+
+type PaymentMethodsResponseSchema struct {
+	ResponseSchema
+	Data PaymentMethodsDataSchema `json:"data"`
+}
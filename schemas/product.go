@@ -4,23 +4,24 @@ import "github.com/DanSmirnov48/techno-trades-go-backend/models"
 
 // REQUEST BODY SCHEMAS
 type CreateProduct struct {
-	Name            string  `json:"name" validate:"required,max=50" example:"Sony PlayStation 5"`
-	Brand           string  `json:"brand" validate:"required,max=50" example:"Sony"`
-	Category        string  `json:"category" validate:"required" example:"consoles"`
-	Description     string  `json:"description" validate:"required,max=500" example:"some item description"`
-	Price           float64 `json:"price" validate:"required,gt=0" example:"399.99"`
-	CountInStock    int     `json:"stock" validate:"required,min=0" example:"100"`
-	IsDiscounted    bool    `json:"is_discounted"`
-	DiscountedPrice float64 `json:"discounted_price" validate:"discounted_price_valid" example:"299.99"`
+	Name            string `json:"name" validate:"required,max=50" example:"Sony PlayStation 5"`
+	Brand           string `json:"brand" validate:"required,max=50" example:"Sony"`
+	Category        string `json:"category" validate:"required" example:"consoles"`
+	Description     string `json:"description" validate:"required,max=500" example:"some item description"`
+	Price           int64  `json:"price" validate:"required,gt=0" example:"39999"`
+	Currency        string `json:"currency" validate:"omitempty,len=3,alpha" example:"gbp"`
+	CountInStock    int    `json:"stock" validate:"required,min=0" example:"100"`
+	IsDiscounted    bool   `json:"is_discounted"`
+	DiscountedPrice int64  `json:"discounted_price" validate:"discounted_price_valid" example:"29999"`
 }
 
 type UpdateProduct struct {
-	Name         string  `json:"name" validate:"max=50" example:"Sony PlayStation 5"`
-	Brand        string  `json:"brand" validate:"max=50" example:"Sony"`
-	Category     string  `json:"category" validate:"max=50" example:"consoles"`
-	Description  string  `json:"description" validate:"max=500" example:"some item description"`
-	Price        float64 `json:"price" validate:"min=0" example:"399.99"`
-	CountInStock int     `json:"stock" validate:"min=0" example:"100"`
+	Name         string `json:"name" validate:"max=50" example:"Sony PlayStation 5"`
+	Brand        string `json:"brand" validate:"max=50" example:"Sony"`
+	Category     string `json:"category" validate:"max=50" example:"consoles"`
+	Description  string `json:"description" validate:"max=500" example:"some item description"`
+	Price        int64  `json:"price" validate:"min=0" example:"39999"`
+	CountInStock int    `json:"stock" validate:"min=0" example:"100"`
 }
 
 // RESPONSE BODY SCHEMAS
@@ -43,10 +44,21 @@ type FindSingleProductResponseSchem struct {
 }
 
 type UpdateDiscount struct {
-	IsDiscounted    bool    `json:"is_discounted"`
-	DiscountedPrice float64 `json:"discounted_price" validate:"discounted_price_valid" example:"299.99"`
+	IsDiscounted    bool  `json:"is_discounted"`
+	DiscountedPrice int64 `json:"discounted_price" validate:"discounted_price_valid" example:"29999"`
 }
 
 type UpdateStockSchema struct {
-	StockChange int `json:"stock_change" validate:"required" example:"10"`
+	StockChange int    `json:"stock_change" validate:"required" example:"10"`
+	Reason      string `json:"reason" validate:"omitempty,oneof=restock return adjustment" example:"restock"`
+}
+
+type StockMovementsDataSchema struct {
+	StockMovements []*models.StockMovement `json:"stock_movements"`
+	Length         int                     `json:"length"`
+}
+
+type StockMovementsResponseSchema struct {
+	ResponseSchema
+	Data StockMovementsDataSchema `json:"data"`
 }
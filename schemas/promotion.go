@@ -0,0 +1,39 @@
+package schemas
+
+import (
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+)
+
+// REQUEST BODY SCHEMAS
+type CreatePromotion struct {
+	Name       string                 `json:"name" validate:"required,max=255" example:"Black Friday"`
+	Type       models.PromotionType   `json:"type" validate:"required,oneof=percentage fixed" example:"percentage"`
+	Value      int64                  `json:"value" validate:"required,gt=0" example:"20"`
+	Currency   string                 `json:"currency" validate:"omitempty,len=3,alpha" example:"gbp"`
+	TargetType models.PromotionTarget `json:"target_type" validate:"required,oneof=product brand category" example:"category"`
+	Target     string                 `json:"target" validate:"required,max=255" example:"consoles"`
+	StartsAt   time.Time              `json:"starts_at" validate:"required" example:"2024-11-29T00:00:00Z"`
+	EndsAt     time.Time              `json:"ends_at" validate:"required,gtfield=StartsAt" example:"2024-12-02T23:59:59Z"`
+}
+
+// RESPONSE BODY SCHEMAS
+type PromotionDataSchema struct {
+	Promotion *models.Promotion `json:"promotion"`
+}
+
+type PromotionResponseSchema struct {
+	ResponseSchema
+	Data PromotionDataSchema `json:"data"`
+}
+
+type PromotionsDataSchema struct {
+	Promotions []*models.Promotion `json:"promotions"`
+	Length     int                 `json:"length"`
+}
+
+type PromotionsResponseSchema struct {
+	ResponseSchema
+	Data PromotionsDataSchema `json:"data"`
+}
@@ -0,0 +1,48 @@
+package schemas
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/google/uuid"
+)
+
+// REQUEST BODY SCHEMAS
+type ReturnItemSchema struct {
+	OrderItemId uuid.UUID `json:"order_item_id" validate:"required" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Quantity    int       `json:"quantity" validate:"required,gt=0" example:"1"`
+}
+
+type CreateReturn struct {
+	OrderId uuid.UUID           `json:"order_id" validate:"required" example:"d10dde64-a242-4ed0-bd75-4c759644b3a6"`
+	Items   []ReturnItemSchema  `json:"items" validate:"required,min=1,dive"`
+	Reason  models.ReturnReason `json:"reason" validate:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other" example:"damaged"`
+	Details string              `json:"details" validate:"max=1000" example:"The box arrived crushed"`
+}
+
+type ApproveReturn struct {
+	Restock bool   `json:"restock" example:"true"`
+	Note    string `json:"note" validate:"max=1000" example:"Item checked and resellable"`
+}
+
+type RejectReturn struct {
+	Note string `json:"note" validate:"required,max=1000" example:"Outside the return window"`
+}
+
+// RESPONSE BODY SCHEMAS
+type ReturnDataSchema struct {
+	Return *models.ReturnRequest `json:"return"`
+}
+
+type ReturnResponseSchema struct {
+	ResponseSchema
+	Data ReturnDataSchema `json:"data"`
+}
+
+type ReturnsDataSchema struct {
+	Returns []*models.ReturnRequest `json:"returns"`
+	Length  int                     `json:"length"`
+}
+
+type ReturnsResponseSchema struct {
+	ResponseSchema
+	Data ReturnsDataSchema `json:"data"`
+}
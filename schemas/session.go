@@ -0,0 +1,32 @@
+package schemas
+
+import (
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/google/uuid"
+)
+
+// RESPONSE BODY SCHEMAS
+type SessionSchema struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+type SessionsDataSchema struct {
+	Sessions []SessionSchema `json:"sessions"`
+	Length   int             `json:"length"`
+}
+
+// Init flags the session the request was made from as current
+func (obj SessionsDataSchema) Init(sessions []*models.Session, currentId uuid.UUID) SessionsDataSchema {
+	obj.Sessions = make([]SessionSchema, 0, len(sessions))
+	for _, session := range sessions {
+		obj.Sessions = append(obj.Sessions, SessionSchema{Session: session, Current: session.ID == currentId})
+	}
+	obj.Length = len(obj.Sessions)
+	return obj
+}
+
+type SessionsResponseSchema struct {
+	ResponseSchema
+	Data SessionsDataSchema `json:"data"`
+}
@@ -0,0 +1,67 @@
+package schemas
+
+import "github.com/DanSmirnov48/techno-trades-go-backend/models"
+
+// REQUEST BODY SCHEMAS
+type CreateShippingMethod struct {
+	Name        string   `json:"name" validate:"required,max=100" example:"Next day"`
+	Description string   `json:"description" validate:"max=500" example:"Order before 2pm"`
+	Price       int64    `json:"price" validate:"min=0" example:"1500"`
+	Currency    string   `json:"currency" validate:"omitempty,len=3,alpha" example:"gbp"`
+	FreeOver    int64    `json:"free_over" validate:"min=0" example:"5000"`
+	MinDays     int      `json:"min_days" validate:"min=0" example:"1"`
+	MaxDays     int      `json:"max_days" validate:"gtefield=MinDays" example:"1"`
+	Countries   []string `json:"countries" validate:"required,min=1,dive,len=2,alpha" example:"GB"`
+	IsActive    *bool    `json:"is_active" example:"true"`
+}
+
+type AddressSchema struct {
+	FullName   string `json:"full_name" validate:"required,max=255" example:"John Doe"`
+	Line1      string `json:"line1" validate:"required,max=255" example:"221B Baker Street"`
+	Line2      string `json:"line2" validate:"max=255" example:"Flat 2"`
+	City       string `json:"city" validate:"required,max=100" example:"London"`
+	Region     string `json:"region" validate:"max=100" example:"Greater London"`
+	PostalCode string `json:"postal_code" validate:"required,max=20" example:"NW1 6XE"`
+	Country    string `json:"country" validate:"required,len=2,alpha" example:"GB"`
+	Phone      string `json:"phone" validate:"max=30" example:"+447700900123"`
+	IsDefault  bool   `json:"is_default" example:"true"`
+}
+
+// RESPONSE BODY SCHEMAS
+type ShippingMethodDataSchema struct {
+	ShippingMethod *models.ShippingMethod `json:"shipping_method"`
+}
+
+type ShippingMethodResponseSchema struct {
+	ResponseSchema
+	Data ShippingMethodDataSchema `json:"data"`
+}
+
+type ShippingMethodsDataSchema struct {
+	ShippingMethods []*models.ShippingMethod `json:"shipping_methods"`
+	Length          int                      `json:"length"`
+}
+
+type ShippingMethodsResponseSchema struct {
+	ResponseSchema
+	Data ShippingMethodsDataSchema `json:"data"`
+}
+
+type AddressDataSchema struct {
+	Address *models.Address `json:"address"`
+}
+
+type AddressResponseSchema struct {
+	ResponseSchema
+	Data AddressDataSchema `json:"data"`
+}
+
+type AddressesDataSchema struct {
+	Addresses []*models.Address `json:"addresses"`
+	Length    int               `json:"length"`
+}
+
+type AddressesResponseSchema struct {
+	ResponseSchema
+	Data AddressesDataSchema `json:"data"`
+}
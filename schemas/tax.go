@@ -0,0 +1,29 @@
+package schemas
+
+import "github.com/DanSmirnov48/techno-trades-go-backend/models"
+
+// REQUEST BODY SCHEMAS
+type SetTaxRate struct {
+	Category string         `json:"category" validate:"required,max=255" example:"books"`
+	Band     models.VATBand `json:"band" validate:"required,oneof=standard reduced zero" example:"zero"`
+}
+
+// RESPONSE BODY SCHEMAS
+type TaxRateDataSchema struct {
+	TaxRate *models.TaxCategoryRate `json:"tax_rate"`
+}
+
+type TaxRateResponseSchema struct {
+	ResponseSchema
+	Data TaxRateDataSchema `json:"data"`
+}
+
+type TaxRatesDataSchema struct {
+	Mode     models.TaxMode            `json:"mode"`
+	TaxRates []*models.TaxCategoryRate `json:"tax_rates"`
+}
+
+type TaxRatesResponseSchema struct {
+	ResponseSchema
+	Data TaxRatesDataSchema `json:"data"`
+}
@@ -14,8 +14,12 @@ type UpdateUserEmailRequestSchema struct {
 }
 
 type UpdateUserRequestSchema struct {
-	FirstName string `json:"first_name" validate:"max=50" example:"John"`
-	LastName  string `json:"last_name" validate:"max=50" example:"Doe"`
+	FirstName   string `json:"first_name" validate:"max=50" example:"John"`
+	LastName    string `json:"last_name" validate:"max=50" example:"Doe"`
+	CompanyName string `json:"company_name" validate:"max=255" example:"Techno Trades Ltd"`
+	VATNumber   string `json:"vat_number" validate:"omitempty,vat_number" example:"GB123456789"`
+	// Stops reminder emails such as abandoned cart nudges
+	MarketingOptOut *bool `json:"marketing_opt_out" example:"true"`
 }
 
 // RESPONSE BODY SCHEMAS
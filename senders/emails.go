@@ -15,8 +15,13 @@ import (
 )
 
 type EmailContext struct {
-	Name string
-	Otp  *uint32
+	Name    string
+	Otp     *uint32
+	Return  *models.ReturnRequest
+	Cart    *models.Cart
+	Session *models.Session
+	Link    string
+	Note    string
 }
 
 type EmailType string
@@ -27,6 +32,13 @@ const (
 	EmailOtpLogin             EmailType = "otp-login"
 	EmailResetPassword        EmailType = "reset-password"
 	EmailResetPasswordSuccess EmailType = "reset-password-success"
+	EmailReturnRequested      EmailType = "return-requested"
+	EmailReturnApproved       EmailType = "return-approved"
+	EmailReturnRejected       EmailType = "return-rejected"
+	EmailReturnRefunded       EmailType = "return-refunded"
+	EmailCartReminder         EmailType = "cart-reminder"
+	EmailSessionAlert         EmailType = "session-alert"
+	EmailMagicLink            EmailType = "magic-link"
 )
 
 func sortEmail(emailType EmailType, code *uint32) map[string]interface{} {
@@ -56,29 +68,82 @@ func sortEmail(emailType EmailType, code *uint32) map[string]interface{} {
 		data["template_file"] = "senders/templates/reset-password-success.html"
 		data["subject"] = "Password reset successfully"
 		data["otp"] = code
+
+	case EmailReturnRequested:
+		data["template_file"] = "senders/templates/return-update.html"
+		data["subject"] = "We've received your return request"
+
+	case EmailReturnApproved:
+		data["template_file"] = "senders/templates/return-update.html"
+		data["subject"] = "Your return has been approved"
+
+	case EmailReturnRejected:
+		data["template_file"] = "senders/templates/return-update.html"
+		data["subject"] = "Update on your return request"
+
+	case EmailReturnRefunded:
+		data["template_file"] = "senders/templates/return-update.html"
+		data["subject"] = "Your refund is on its way"
+
+	case EmailCartReminder:
+		data["template_file"] = "senders/templates/cart-reminder.html"
+		data["subject"] = "You left something in your cart"
+
+	case EmailSessionAlert:
+		data["template_file"] = "senders/templates/session-alert.html"
+		data["subject"] = "We signed out one of your devices"
+
+	case EmailMagicLink:
+		data["template_file"] = "senders/templates/magic-link.html"
+		data["subject"] = "Your sign-in link"
 	}
 	return data
 }
 
 func SendEmail(user *models.User, emailType EmailType, code *uint32) {
+	send(user, emailType, EmailContext{Otp: code})
+}
+
+// SendReturnEmail tells the customer where their return has got to, with
+// the note from its latest step
+func SendReturnEmail(user *models.User, emailType EmailType, rma *models.ReturnRequest) {
+	data := EmailContext{Return: rma}
+	if len(rma.History) > 0 {
+		data.Note = rma.History[len(rma.History)-1].Note
+	}
+	send(user, emailType, data)
+}
+
+// SendCartReminder nudges the customer about the cart they left behind,
+// with a link straight back to it
+func SendCartReminder(user *models.User, cart *models.Cart, link string) {
+	send(user, EmailCartReminder, EmailContext{Cart: cart, Link: link})
+}
+
+// SendSessionAlert warns the user that a refresh token from one of their
+// sessions was replayed, and that the session has been signed out
+func SendSessionAlert(user *models.User, session *models.Session) {
+	send(user, EmailSessionAlert, EmailContext{Session: session})
+}
+
+// SendMagicLink emails the user a link that signs them in when opened
+func SendMagicLink(user *models.User, link string) {
+	send(user, EmailMagicLink, EmailContext{Link: link})
+}
+
+func send(user *models.User, emailType EmailType, data EmailContext) {
 	if os.Getenv("ENVIRONMENT") == "TESTING" {
 		return
 	}
 
 	cfg := config.GetConfig()
 
-	emailData := sortEmail(emailType, code)
+	emailData := sortEmail(emailType, data.Otp)
 	templateFile := emailData["template_file"]
 	subject := emailData["subject"]
 
-	// Create a context with dynamic data
-	data := EmailContext{
-		Name: user.FirstName,
-	}
-	if otp, ok := emailData["otp"]; ok {
-		code := otp.(*uint32)
-		data.Otp = code
-	}
+	// Fill in the context with dynamic data
+	data.Name = user.FirstName
 
 	// Read the HTML file content
 	_, file, _, ok := runtime.Caller(0)
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+// SoftAuthenticator is a passkey held in memory. It answers the options
+// from the begin endpoints the way a browser and platform authenticator
+// would, so passkey flows can be tested without a real device. The origin
+// and RP ID match WEBAUTHN_RP_ORIGINS and WEBAUTHN_RP_ID in tests/.env.
+type SoftAuthenticator struct {
+	Origin    string
+	RPID      string
+	SignCount uint32
+	// Acts like a security key without a PIN, which only proves presence
+	SkipUserVerification bool
+	key                  *ecdsa.PrivateKey
+	credentialId         []byte
+	userHandle           []byte
+}
+
+func NewSoftAuthenticator() *SoftAuthenticator {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	credentialId := make([]byte, 32)
+	rand.Read(credentialId)
+	return &SoftAuthenticator{
+		Origin:       "http://localhost:3000",
+		RPID:         "localhost",
+		key:          key,
+		credentialId: credentialId,
+	}
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func publicKeyOptions(options map[string]interface{}) map[string]interface{} {
+	return options["publicKey"].(map[string]interface{})
+}
+
+func (a *SoftAuthenticator) clientData(ceremonyType string, options map[string]interface{}) []byte {
+	clientData, _ := json.Marshal(map[string]interface{}{
+		"type":        ceremonyType,
+		"challenge":   publicKeyOptions(options)["challenge"],
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	return clientData
+}
+
+// authenticatorData is the RP ID hash, the user present and verified flags
+// and the sign count, followed by any attested credential data
+func (a *SoftAuthenticator) authenticatorData(flags byte, attested []byte) []byte {
+	rpIdHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpIdHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.SignCount)
+	return append(data, attested...)
+}
+
+// Register creates the credential for a registration or signup ceremony
+func (a *SoftAuthenticator) Register(options map[string]interface{}) map[string]interface{} {
+	user := publicKeyOptions(options)["user"].(map[string]interface{})
+	a.userHandle, _ = base64.RawURLEncoding.DecodeString(user["id"].(string))
+
+	coseKey, _ := webauthncbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialId)))
+	attested = append(attested, a.credentialId...)
+	attested = append(attested, coseKey...)
+
+	attestationObject, _ := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authenticatorData(0x45, attested),
+	})
+	return map[string]interface{}{
+		"id":    encode(a.credentialId),
+		"rawId": encode(a.credentialId),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    encode(a.clientData("webauthn.create", options)),
+			"attestationObject": encode(attestationObject),
+			"transports":        []string{"internal"},
+		},
+	}
+}
+
+// Login signs the challenge of a login ceremony, counting up like a real key
+func (a *SoftAuthenticator) Login(options map[string]interface{}) map[string]interface{} {
+	a.SignCount++
+	clientData := a.clientData("webauthn.get", options)
+	var flags byte = 0x05 // User present and verified
+	if a.SkipUserVerification {
+		flags = 0x01
+	}
+	authData := a.authenticatorData(flags, nil)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, _ := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	return map[string]interface{}{
+		"id":    encode(a.credentialId),
+		"rawId": encode(a.credentialId),
+		"type":  "public-key",
+		"response": map[string]interface{}{
+			"clientDataJSON":    encode(clientData),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode(a.userHandle),
+		},
+	}
+}
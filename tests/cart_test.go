@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	auth "github.com/DanSmirnov48/techno-trades-go-backend/authentication"
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/jobs"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func cartItems(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Add, Update And Remove Cart Items", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		ProcessTestBody(t, app, baseUrl, "DELETE", nil, accessToken)
+
+		// Adding the same product twice adds up the quantity
+		addData := schemas.AddCartItem{ProductID: product.ID.String(), Quantity: 2}
+		ProcessTestBody(t, app, fmt.Sprintf("%s/items", baseUrl), "POST", addData, accessToken)
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/items", baseUrl), "POST", addData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		items := data["items"].([]interface{})
+		assert.Equal(t, 1, len(items))
+		assert.Equal(t, float64(4), items[0].(map[string]interface{})["quantity"])
+		assert.Equal(t, float64(40000), data["subtotal"].(map[string]interface{})["amount"])
+
+		// More than is in stock can't be added
+		itemUrl := fmt.Sprintf("%s/items/%s", baseUrl, product.ID)
+		res = ProcessTestBody(t, app, itemUrl, "PATCH", schemas.UpdateCartItem{Quantity: product.CountInStock + 1}, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		res = ProcessTestBody(t, app, itemUrl, "PATCH", schemas.UpdateCartItem{Quantity: 1}, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		res = ProcessTestBody(t, app, itemUrl, "DELETE", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		data = body["data"].(map[string]interface{})
+		assert.Equal(t, 0, len(data["items"].([]interface{})))
+	})
+}
+
+func cartRevalidation(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Cart Revalidates On Read", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		FillTestCart(db, user.ID, product, 10)
+
+		// Price drops and stock runs low after the item was added
+		productManager.UpdateDiscount(db, product.ID, schemas.UpdateDiscount{IsDiscounted: true, DiscountedPrice: 5000})
+		productManager.UpdateStock(db, product.ID, 4-product.CountInStock, models.StockMovementAdjustment, &user.ID, nil)
+
+		res := ProcessTestBody(t, app, baseUrl, "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		line := data["items"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, float64(4), line["quantity"])
+		assert.Equal(t, float64(5000), line["unit_price"].(map[string]interface{})["amount"])
+		assert.Equal(t, 1, len(data["warnings"].([]interface{})))
+	})
+}
+
+func guestCartMerge(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Guest Cart Merges On Login", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		FillTestCart(db, user.ID, product, 2)
+
+		// A guest builds a cart without signing in
+		addData := schemas.AddCartItem{ProductID: product.ID.String(), Quantity: 99}
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/items", baseUrl), "POST", addData)
+		assert.Equal(t, 200, res.StatusCode)
+		var guestCookie *http.Cookie
+		for _, cookie := range res.Cookies() {
+			if cookie.Name == string(auth.GuestCart) {
+				guestCookie = cookie
+			}
+		}
+		assert.NotNil(t, guestCookie)
+
+		// A tampered cookie doesn't give access to the cart
+		_, ok := auth.DecodeGuestCartToken(guestCookie.Value + "x")
+		assert.False(t, ok)
+
+		// Signing in from the same browser merges it, capped at the stock
+		loginData, _ := json.Marshal(schemas.LoginSchema{Email: user.Email, Password: "testpassword"})
+		req := httptest.NewRequest("POST", "/api/v1/auth/login", bytes.NewReader(loginData))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(guestCookie)
+		res, _ = app.Test(req)
+		assert.Equal(t, 201, res.StatusCode)
+
+		cart, _, _ := cartManager.GetOrCreate(db, user.ID)
+		assert.Equal(t, 1, len(cart.Items))
+		assert.Equal(t, product.CountInStock, cart.Items[0].Quantity)
+		guestCartId, _ := auth.DecodeGuestCartToken(guestCookie.Value)
+		assert.Nil(t, cartManager.GetGuest(db, *guestCartId))
+	})
+}
+
+func abandonedCartReminders(t *testing.T, app *fiber.App, db *gorm.DB) {
+	t.Run("Abandoned Cart Reminders", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		cart := FillTestCart(db, user.ID, product, 1)
+		remindersFor := func() int64 {
+			var count int64
+			db.Model(&models.CartReminder{}).Where("cart_id = ?", cart.ID).Count(&count)
+			return count
+		}
+		hoursAgo := func(hours int) time.Time {
+			return time.Now().Add(-time.Duration(hours) * time.Hour)
+		}
+		ageCart := func(hours int) {
+			db.Model(&models.Cart{}).Where("id = ?", cart.ID).Update("updated_at", hoursAgo(hours))
+		}
+		ageReminders := func(hours int) {
+			db.Model(&models.CartReminder{}).Where("cart_id = ?", cart.ID).Update("created_at", hoursAgo(hours))
+		}
+
+		// A freshly used cart is left alone
+		jobs.RemindAbandonedCarts(db)
+		assert.Equal(t, int64(0), remindersFor())
+
+		// ### Idle for a day, it gets one reminder per idle period
+		ageCart(30)
+		jobs.RemindAbandonedCarts(db)
+		assert.Equal(t, int64(1), remindersFor())
+		jobs.RemindAbandonedCarts(db)
+		assert.Equal(t, int64(1), remindersFor())
+
+		// Up to the configured maximum
+		ageReminders(25)
+		jobs.RemindAbandonedCarts(db)
+		assert.Equal(t, int64(2), remindersFor())
+		ageReminders(25)
+		jobs.RemindAbandonedCarts(db)
+		assert.Equal(t, int64(2), remindersFor())
+
+		// ### Nothing goes to customers who opted out
+		db.Where("cart_id = ?", cart.ID).Delete(&models.CartReminder{})
+		optOut := true
+		res := ProcessTestBody(t, app, "/api/v1/users/update-me", "PATCH", schemas.UpdateUserRequestSchema{MarketingOptOut: &optOut}, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+		jobs.RemindAbandonedCarts(db)
+		assert.Equal(t, int64(0), remindersFor())
+		db.Model(&models.User{}).Where("id = ?", user.ID).Update("marketing_opt_out", false)
+
+		// ### Or for products that sold out
+		productManager.UpdateStock(db, product.ID, -product.CountInStock, models.StockMovementAdjustment, &user.ID, nil)
+		jobs.RemindAbandonedCarts(db)
+		assert.Equal(t, int64(0), remindersFor())
+		productManager.UpdateStock(db, product.ID, product.CountInStock, models.StockMovementAdjustment, &user.ID, nil)
+
+		// ### Paying afterwards counts as a recovered cart
+		jobs.RemindAbandonedCarts(db)
+		assert.Equal(t, int64(1), remindersFor())
+		order := CreateTestPaidOrder(db, user.ID, product, 1)
+		reminder := models.CartReminder{}
+		db.Take(&reminder, "cart_id = ?", cart.ID)
+		assert.NotNil(t, reminder.ConvertedAt)
+		assert.Equal(t, order.ID, *reminder.OrderId)
+	})
+}
+
+func TestCart(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/cart"
+
+	// Run Cart Tests
+	cartItems(t, app, db, BASEURL)
+	cartRevalidation(t, app, db, BASEURL)
+	guestCartMerge(t, app, db, BASEURL)
+	abandonedCartReminders(t, app, db)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
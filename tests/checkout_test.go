@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func checkoutSession(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Checkout Session End To End", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+
+		// ### Create the checkout session
+		url := fmt.Sprintf("%s/create-checkout-session", baseUrl)
+		FillTestCart(db, user.ID, product, 2)
+		checkoutData := CheckoutTestData(db, user.ID)
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, true, body["success"])
+		assert.Contains(t, body["url"], "https://checkout.fake/")
+
+		// The order is persisted as pending and linked to the session
+		orderId := uuid.MustParse(body["order_id"].(string))
+		order, _, _ := orderManager.GetById(db, orderId)
+		assert.Equal(t, models.OrderStatusPending, order.Status)
+		assert.NotNil(t, order.StripeCheckoutSessionId)
+		assert.Equal(t, models.NewMoney(20000, "gbp"), order.Total)
+
+		checkout := paymentProvider.LastCheckout()
+		assert.Equal(t, "gbp", checkout.Currency)
+		assert.Equal(t, 1, len(checkout.LineItems))
+		assert.Equal(t, int64(10000), checkout.LineItems[0].UnitAmount)
+		assert.Equal(t, int64(2), checkout.LineItems[0].Quantity)
+		assert.Equal(t, orderId.String(), checkout.Metadata["orderId"])
+
+		// The session expiry sits inside the window Stripe accepts
+		assert.True(t, checkout.ExpiresAt.After(time.Now().Add(30*time.Minute)))
+		assert.True(t, checkout.ExpiresAt.Before(time.Now().Add(24*time.Hour)))
+
+		// ### Complete the payment through the webhook
+		session := map[string]interface{}{
+			"id":             *order.StripeCheckoutSessionId,
+			"object":         "checkout.session",
+			"payment_status": "paid",
+			"payment_intent": "pi_checkout_e2e",
+			"metadata":       checkout.Metadata,
+		}
+		SendStripeEvent(t, app, fmt.Sprintf("%s/webhook", baseUrl), fmt.Sprintf("evt_checkout_%s", orderId), "checkout.session.completed", session)
+
+		order, _, _ = orderManager.GetById(db, orderId)
+		assert.Equal(t, models.OrderStatusPaid, order.Status)
+		assert.Equal(t, "pi_checkout_e2e", *order.StripePaymentIntentId)
+		updatedProduct, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock-2, updatedProduct.CountInStock)
+
+		// The bought items leave the cart
+		cart, _, _ := cartManager.GetOrCreate(db, user.ID)
+		assert.Equal(t, 0, len(cart.Items))
+	})
+}
+
+func checkoutPaymentIntent(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Payment Intent End To End", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+
+		url := fmt.Sprintf("%s/create-payment-intent", baseUrl)
+		FillTestCart(db, user.ID, product, 1)
+		checkoutData := CheckoutTestData(db, user.ID)
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Contains(t, body["clientSecret"], "_secret")
+
+		intent := paymentProvider.LastIntent()
+		assert.Equal(t, int64(10000), intent.Amount)
+		assert.Equal(t, "gbp", intent.Currency)
+
+		orderId := uuid.MustParse(body["order_id"].(string))
+		order, _, _ := orderManager.GetById(db, orderId)
+		SendStripeEvent(t, app, fmt.Sprintf("%s/webhook", baseUrl), fmt.Sprintf("evt_intent_%s", orderId), "payment_intent.succeeded", map[string]interface{}{
+			"id":       *order.StripePaymentIntentId,
+			"object":   "payment_intent",
+			"metadata": intent.Metadata,
+		})
+
+		order, _, _ = orderManager.GetById(db, orderId)
+		assert.Equal(t, models.OrderStatusPaid, order.Status)
+	})
+}
+
+func checkoutInsufficientStock(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Checkout Insufficient Stock", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+
+		// Stock runs out after the item was put in the cart
+		FillTestCart(db, user.ID, product, 60)
+		productManager.UpdateStock(db, product.ID, -50, models.StockMovementAdjustment, &user.ID, nil)
+
+		url := fmt.Sprintf("%s/create-checkout-session", baseUrl)
+		res := ProcessTestBody(t, app, url, "POST", CheckoutTestData(db, user.ID), accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+	})
+}
+
+func checkoutReservesStock(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Checkout Reserves Stock", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+
+		url := fmt.Sprintf("%s/create-checkout-session", baseUrl)
+		FillTestCart(db, user.ID, product, 60)
+		checkoutData := CheckoutTestData(db, user.ID)
+
+		// The first checkout holds 60 of the 100 units
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		firstOrderId := uuid.MustParse(body["order_id"].(string))
+		assert.Equal(t, 60, productManager.ReservedStock(db, product.ID))
+
+		// A second buyer can't take the held units
+		res = ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Once the hold lapses the order is cancelled and the stock is free again
+		db.Model(&models.StockReservation{}).Where("order_id = ?", firstOrderId).Update("expires_at", time.Now().Add(-time.Minute))
+		assert.Equal(t, 1, orderManager.ExpireReservations(db, paymentProvider))
+		firstOrder, _, _ := orderManager.GetById(db, firstOrderId)
+		assert.Equal(t, models.OrderStatusCancelled, firstOrder.Status)
+		assert.Equal(t, 0, productManager.ReservedStock(db, product.ID))
+
+		res = ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Stock itself is only decremented when the payment succeeds
+		updatedProduct, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock, updatedProduct.CountInStock)
+	})
+}
+
+func expiredPaymentIntent(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Expired Hold Cancels Payment Intent", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+
+		url := fmt.Sprintf("%s/create-payment-intent", baseUrl)
+		FillTestCart(db, user.ID, product, 1)
+		checkoutData := CheckoutTestData(db, user.ID)
+		expire := func(orderId uuid.UUID) {
+			db.Model(&models.StockReservation{}).Where("order_id = ?", orderId).Update("expires_at", time.Now().Add(-time.Minute))
+			orderManager.ExpireReservations(db, paymentProvider)
+		}
+
+		// The intent is cancelled along with the order, so it can't be paid later
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		orderId := uuid.MustParse(body["order_id"].(string))
+		expire(orderId)
+		order, _, _ := orderManager.GetById(db, orderId)
+		assert.Equal(t, models.OrderStatusCancelled, order.Status)
+		assert.Contains(t, paymentProvider.CancelledIntents, *order.StripePaymentIntentId)
+
+		// An intent that was already paid keeps its order for the webhook
+		res = ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		orderId = uuid.MustParse(body["order_id"].(string))
+		order, _, _ = orderManager.GetById(db, orderId)
+		paymentProvider.SucceedIntent(*order.StripePaymentIntentId)
+		expire(orderId)
+		order, _, _ = orderManager.GetById(db, orderId)
+		assert.Equal(t, models.OrderStatusPending, order.Status)
+	})
+}
+
+func checkoutBillsDiscountedPrice(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Checkout Bills Discounted Price", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		productManager.UpdateDiscount(db, product.ID, schemas.UpdateDiscount{IsDiscounted: true, DiscountedPrice: 7999})
+		accessToken := LoginTestUser(t, app, user.Email)
+
+		// Listings show the discounted price
+		discounted, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, int64(7999), discounted.EffectivePrice.Amount)
+
+		url := fmt.Sprintf("%s/create-checkout-session", baseUrl)
+		FillTestCart(db, user.ID, product, 2)
+		checkoutData := CheckoutTestData(db, user.ID)
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// The line item and the order snapshot both use it
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		order, _, _ := orderManager.GetById(db, uuid.MustParse(body["order_id"].(string)))
+		assert.Equal(t, int64(7999), paymentProvider.LastCheckout().LineItems[0].UnitAmount)
+		assert.Equal(t, int64(7999), order.Items[0].UnitPrice.Amount)
+		assert.Equal(t, int64(15998), order.Total.Amount)
+	})
+}
+
+func TestCheckout(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/stripe"
+
+	// Run Checkout Tests
+	checkoutSession(t, app, db, BASEURL)
+	checkoutPaymentIntent(t, app, db, BASEURL)
+	checkoutInsufficientStock(t, app, db, BASEURL)
+	checkoutReservesStock(t, app, db, BASEURL)
+	expiredPaymentIntent(t, app, db, BASEURL)
+	checkoutBillsDiscountedPrice(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var couponManager = managers.CouponManager{}
+
+func createTestCoupon(db *gorm.DB, data schemas.CreateCoupon) *models.Coupon {
+	data.Code = fmt.Sprintf("TEST%s", utils.GetRandomString(6))
+	coupon, _, _ := couponManager.Create(db, data)
+	return coupon
+}
+
+func validateCoupon(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Validate Coupon", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		coupon := createTestCoupon(db, schemas.CreateCoupon{Type: models.CouponPercentage, Value: 10, MinBasket: 15000})
+
+		url := fmt.Sprintf("%s/validate", baseUrl)
+		items := []schemas.CartItemSchema{{ProductID: product.ID.String(), Quantity: 2}}
+
+		// 10% off a 200.00 basket
+		res := ProcessTestBody(t, app, url, "POST", schemas.ValidateCoupon{Code: coupon.Code, Items: items}, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(2000), data["discount"].(map[string]interface{})["amount"])
+		assert.Equal(t, float64(18000), data["total"].(map[string]interface{})["amount"])
+
+		// A single unit is below the minimum basket
+		items[0].Quantity = 1
+		res = ProcessTestBody(t, app, url, "POST", schemas.ValidateCoupon{Code: coupon.Code, Items: items}, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Coupons limited to other categories don't apply
+		other := createTestCoupon(db, schemas.CreateCoupon{Type: models.CouponFixed, Value: 500, Categories: []string{"laptops"}})
+		res = ProcessTestBody(t, app, url, "POST", schemas.ValidateCoupon{Code: other.Code, Items: items}, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+	})
+}
+
+func checkoutWithCoupon(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Checkout With Coupon", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		coupon := createTestCoupon(db, schemas.CreateCoupon{Type: models.CouponFixed, Value: 1500, MaxRedemptions: 1})
+
+		url := "/api/v1/stripe/create-checkout-session"
+		FillTestCart(db, user.ID, product, 1)
+		checkoutData := CheckoutTestData(db, user.ID)
+		checkoutData["coupon_code"] = coupon.Code
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// The discount is on the order and passed to the provider
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		order, _, _ := orderManager.GetById(db, uuid.MustParse(body["order_id"].(string)))
+		assert.Equal(t, int64(1500), order.Discount.Amount)
+		assert.Equal(t, int64(8500), order.Total.Amount)
+		assert.Equal(t, int64(1500), paymentProvider.LastCheckout().DiscountAmount)
+
+		// The only redemption is taken
+		res = ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Cancelling the order gives it back
+		orderManager.UpdateStatus(db, order, models.OrderStatusCancelled)
+		released, _, _ := couponManager.GetById(db, coupon.ID)
+		assert.Equal(t, 0, released.Redemptions)
+		res = ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+	})
+}
+
+func TestCoupon(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/coupons"
+
+	// Run Coupon Tests
+	validateCoupon(t, app, db, BASEURL)
+	checkoutWithCoupon(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
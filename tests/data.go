@@ -12,7 +12,11 @@ import (
 )
 
 var (
-	productManager = managers.ProductManager{}
+	productManager  = managers.ProductManager{}
+	orderManager    = managers.OrderManager{}
+	cartManager     = managers.CartManager{}
+	shippingManager = managers.ShippingManager{}
+	addressManager  = managers.AddressManager{}
 )
 
 // AUTH
@@ -46,11 +50,11 @@ func CreateTestVerifiedUser(db *gorm.DB) models.User {
 func CreateVerifiedTestAdminUser(db *gorm.DB) models.User {
 	user := models.User{
 		FirstName:       "Test",
-		LastName:        "Verified",
-		Email:           "testverifieduser@example.com",
+		LastName:        "Admin",
+		Email:           "testadmin@example.com",
 		Password:        "testpassword",
 		IsEmailVerified: true,
-		Role:            models.AdminRole,
+		AccountType:     models.AccountTypeStaff,
 	}
 	db.FirstOrCreate(&user, models.User{Email: user.Email})
 	return user
@@ -64,10 +68,80 @@ func CreateNewProduct(db *gorm.DB, userId uuid.UUID) *models.Product {
 		Brand:        "test_products",
 		Category:     "test_products",
 		Description:  "this is a product description blah blah blah",
-		Price:        100,
+		Price:        10000,
 		CountInStock: 100,
 		IsDiscounted: false,
 	}
 	newProduct := productManager.Create(db, productData, userId)
 	return newProduct
 }
+
+// CART
+func FillTestCart(db *gorm.DB, userId uuid.UUID, product *models.Product, quantity int) *models.Cart {
+	cart, _, _ := cartManager.GetOrCreate(db, userId)
+	cartManager.Clear(db, cart)
+	cart, _, _ = cartManager.AddItem(db, cart, product.ID, quantity)
+	return cart
+}
+
+// SHIPPING
+func CreateTestShippingMethod(db *gorm.DB, price int64, freeOver int64, countries ...string) *models.ShippingMethod {
+	if len(countries) == 0 {
+		countries = []string{"GB"}
+	}
+	method, _, _ := shippingManager.Create(db, schemas.CreateShippingMethod{
+		Name:      fmt.Sprintf("test_shipping_%s", utils.GetRandomString(6)),
+		Price:     price,
+		FreeOver:  freeOver,
+		MinDays:   1,
+		MaxDays:   3,
+		Countries: countries,
+	})
+	return method
+}
+
+func CreateTestAddress(db *gorm.DB, userId uuid.UUID, country string) *models.Address {
+	address, _, _ := addressManager.Create(db, userId, schemas.AddressSchema{
+		FullName:   "Test User",
+		Line1:      "221B Baker Street",
+		City:       "London",
+		PostalCode: "NW1 6XE",
+		Country:    country,
+	})
+	return address
+}
+
+// CheckoutTestData is a checkout body with a GB address and free delivery,
+// so order totals are the goods alone
+func CheckoutTestData(db *gorm.DB, userId uuid.UUID) map[string]interface{} {
+	address := CreateTestAddress(db, userId, "GB")
+	method := CreateTestShippingMethod(db, 0, 0)
+	return map[string]interface{}{
+		"address_id":         address.ID,
+		"shipping_method_id": method.ID,
+	}
+}
+
+// ORDERS
+func CreateTestOrder(db *gorm.DB, userId uuid.UUID, product *models.Product, quantity int) *models.Order {
+	items := []models.OrderItem{
+		{ProductId: product.ID, Name: product.Name, UnitPrice: product.Price, Quantity: quantity},
+	}
+	order, _, _ := orderManager.Create(db, userId, items)
+	return order
+}
+
+func CreateTestPaidOrder(db *gorm.DB, userId uuid.UUID, product *models.Product, quantity int) *models.Order {
+	order := CreateTestOrder(db, userId, product, quantity)
+	orderManager.MarkPaid(db, order, fmt.Sprintf("pi_%s", order.ID))
+	return order
+}
+
+// CreateTestDeliveredOrder pays for a new order and takes it all the way to delivered
+func CreateTestDeliveredOrder(db *gorm.DB, userId uuid.UUID, product *models.Product, quantity int) *models.Order {
+	order := CreateTestPaidOrder(db, userId, product, quantity)
+	for _, status := range []models.OrderStatus{models.OrderStatusFulfilled, models.OrderStatusShipped, models.OrderStatusDelivered} {
+		orderManager.UpdateStatus(db, order, status)
+	}
+	return order
+}
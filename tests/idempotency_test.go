@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	midw "github.com/DanSmirnov48/techno-trades-go-backend/authentication"
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func processIdempotentTestBody(t *testing.T, app *fiber.App, url string, body interface{}, key string, access string) *http.Response {
+	requestBytes, err := json.Marshal(body)
+	assert.Nil(t, err)
+	req := httptest.NewRequest("POST", url, bytes.NewReader(requestBytes))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", access))
+	req.Header.Set(midw.IdempotencyKeyHeader, key)
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	return res
+}
+
+func idempotentCheckout(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Idempotent Checkout", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		FillTestCart(db, user.ID, product, 1)
+		checkoutData := CheckoutTestData(db, user.ID)
+		url := fmt.Sprintf("%s/create-checkout-session", baseUrl)
+		checkouts := len(paymentProvider.Checkouts)
+
+		res := processIdempotentTestBody(t, app, url, checkoutData, "pay-click-1", accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		first := ParseResponseBody(t, res.Body).(map[string]interface{})
+
+		// ### The double-click gets the first response back
+		res = processIdempotentTestBody(t, app, url, checkoutData, "pay-click-1", accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "true", res.Header.Get("Idempotent-Replayed"))
+		second := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, first["order_id"], second["order_id"])
+		assert.Equal(t, first["url"], second["url"])
+		assert.Equal(t, checkouts+1, len(paymentProvider.Checkouts))
+
+		var orders int64
+		db.Table("orders").Where("user_id = ?", user.ID).Count(&orders)
+		assert.Equal(t, int64(1), orders)
+
+		// The same key can't be used for a different request
+		checkoutData["coupon_code"] = "SOMETHINGELSE"
+		res = processIdempotentTestBody(t, app, url, checkoutData, "pay-click-1", accessToken)
+		assert.Equal(t, 422, res.StatusCode)
+
+		// ### A new key is a new request
+		checkoutData["coupon_code"] = ""
+		res = processIdempotentTestBody(t, app, url, checkoutData, "pay-click-2", accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Empty(t, res.Header.Get("Idempotent-Replayed"))
+		assert.Equal(t, checkouts+2, len(paymentProvider.Checkouts))
+	})
+}
+
+func abandonedIdempotencyKey(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Abandoned Idempotency Key", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		FillTestCart(db, user.ID, product, 1)
+		checkoutData := CheckoutTestData(db, user.ID)
+		url := fmt.Sprintf("%s/create-checkout-session", baseUrl)
+		requestBytes, _ := json.Marshal(checkoutData)
+		hash := sha256.Sum256(requestBytes)
+		record := models.IdempotencyRecord{
+			UserId:      user.ID,
+			Key:         "pay-click-lost",
+			Route:       "POST " + url,
+			RequestHash: hex.EncodeToString(hash[:]),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}
+		db.Create(&record)
+
+		// While the first request could still be running the retry waits
+		res := processIdempotentTestBody(t, app, url, checkoutData, "pay-click-lost", accessToken)
+		assert.Equal(t, 409, res.StatusCode)
+
+		// Once it's clearly been abandoned the retry goes through
+		db.Model(&record).Update("created_at", time.Now().Add(-managers.IdempotencyInFlightTimeout))
+		res = processIdempotentTestBody(t, app, url, checkoutData, "pay-click-lost", accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Empty(t, res.Header.Get("Idempotent-Replayed"))
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/stripe"
+
+	// Run Idempotency Tests
+	idempotentCheckout(t, app, db, BASEURL)
+	abandonedIdempotencyKey(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
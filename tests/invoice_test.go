@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var invoiceManager = managers.InvoiceManager{}
+
+func invoiceIssuedOnPayment(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Invoice Issued On Payment", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+
+		first := CreateTestOrder(db, user.ID, product, 1)
+		second := CreateTestOrder(db, user.ID, product, 2)
+
+		// Nothing to download before payment
+		url := fmt.Sprintf("%s/%s/invoice", baseUrl, first.ID)
+		res := ProcessTestBody(t, app, url, "GET", nil, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		orderManager.MarkPaid(db, first, "pi_invoice_first")
+		orderManager.MarkPaid(db, second, "pi_invoice_second")
+
+		// Numbers follow on from each other
+		firstInvoice, errCode, _ := invoiceManager.GetInvoice(db, first.ID)
+		assert.Nil(t, errCode)
+		secondInvoice, _, _ := invoiceManager.GetInvoice(db, second.ID)
+		assert.True(t, strings.HasPrefix(firstInvoice.Number, "INV-"))
+		firstSeq, _ := strconv.Atoi(strings.TrimPrefix(firstInvoice.Number, "INV-"))
+		secondSeq, _ := strconv.Atoi(strings.TrimPrefix(secondInvoice.Number, "INV-"))
+		assert.Equal(t, firstSeq+1, secondSeq)
+		assert.Equal(t, first.Total, firstInvoice.Amount)
+
+		// An order only ever gets one invoice
+		again, _, _ := invoiceManager.IssueInvoice(db, first)
+		assert.Equal(t, firstInvoice.Number, again.Number)
+		assert.Equal(t, 1, len(invoiceManager.GetForOrder(db, first.ID)))
+
+		// ### The buyer downloads the PDF
+		res = ProcessTestBody(t, app, url, "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "application/pdf", res.Header.Get("Content-Type"))
+		content, _ := io.ReadAll(res.Body)
+		assert.True(t, strings.HasPrefix(string(content), "%PDF"))
+
+		// Other buyers can't see it
+		otherUser := CreateTestUser(db)
+		otherUser.IsEmailVerified = true
+		db.Save(&otherUser)
+		otherToken := LoginTestUser(t, app, otherUser.Email)
+		res = ProcessTestBody(t, app, url, "GET", nil, otherToken)
+		assert.Equal(t, 404, res.StatusCode)
+	})
+}
+
+func creditNoteOnRefund(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Credit Note On Refund", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		order := CreateTestOrder(db, user.ID, product, 2)
+		orderManager.MarkPaid(db, order, "pi_invoice_refund")
+
+		charge := map[string]interface{}{
+			"id":              "ch_invoice_refund",
+			"object":          "charge",
+			"payment_intent":  "pi_invoice_refund",
+			"amount_refunded": 5000,
+			"refunded":        false,
+			"currency":        "gbp",
+		}
+		SendStripeEvent(t, app, "/api/v1/stripe/webhook", fmt.Sprintf("evt_credit_%s", order.ID), "charge.refunded", charge)
+
+		// The invoice and a credit note for the refund are listed
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/%s/invoices", baseUrl, order.ID), "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		invoices := body["data"].(map[string]interface{})["invoices"].([]interface{})
+		assert.Equal(t, 2, len(invoices))
+		creditNote := invoices[1].(map[string]interface{})
+		assert.Equal(t, string(models.InvoiceTypeCreditNote), creditNote["type"])
+		assert.Equal(t, float64(5000), creditNote["amount"].(map[string]interface{})["amount"])
+		// 20% VAT is a sixth of a VAT inclusive amount
+		assert.Equal(t, float64(833), creditNote["tax"].(map[string]interface{})["amount"])
+
+		url := fmt.Sprintf("%s/%s/invoices/%s", baseUrl, order.ID, creditNote["number"])
+		res = ProcessTestBody(t, app, url, "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "application/pdf", res.Header.Get("Content-Type"))
+	})
+}
+
+func concurrentInvoiceIssue(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Concurrent Invoice Issue", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		order := CreateTestOrder(db, user.ID, product, 1)
+
+		// The payment and a few first downloads all try to raise the invoice
+		var wg sync.WaitGroup
+		numbers := make([]string, 5)
+		for i := range numbers {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if invoice, errCode, _ := invoiceManager.IssueInvoice(db, order); errCode == nil {
+					numbers[i] = invoice.Number
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, len(invoiceManager.GetForOrder(db, order.ID)))
+		for _, number := range numbers {
+			assert.Equal(t, numbers[0], number)
+		}
+	})
+}
+
+func TestInvoice(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/orders"
+
+	// Run Invoice Tests
+	invoiceIssuedOnPayment(t, app, db, BASEURL)
+	creditNoteOnRefund(t, app, db, BASEURL)
+	concurrentInvoiceIssue(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// requestMagicLink asks for a sign-in link and returns its path along with the
+// nonce cookie the requesting browser was given
+func requestMagicLink(t *testing.T, app *fiber.App, baseUrl string, email string) (string, *http.Cookie) {
+	res := ProcessTestBody(t, app, fmt.Sprintf("%s/auth/magic-link", baseUrl), "POST", map[string]string{"email": email})
+	assert.Equal(t, 200, res.StatusCode)
+
+	var nonce *http.Cookie
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == "magicLink" {
+			nonce = cookie
+		}
+	}
+	assert.NotNil(t, nonce)
+
+	body := ParseResponseBody(t, res.Body).(map[string]interface{})
+	link, err := url.Parse(body["data"].(map[string]interface{})["link"].(string))
+	assert.Nil(t, err)
+	return link.Path, nonce
+}
+
+func openMagicLink(t *testing.T, app *fiber.App, path string, nonce *http.Cookie) *http.Response {
+	req := httptest.NewRequest("GET", path, nil)
+	if nonce != nil {
+		req.AddCookie(nonce)
+	}
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	return res
+}
+
+func loginWithMagicLink(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Login With Magic Link", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+
+		// Unknown emails don't get a link
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/auth/magic-link", baseUrl), "POST", map[string]string{"email": "nobody@example.com"})
+		assert.Equal(t, 401, res.StatusCode)
+
+		path, nonce := requestMagicLink(t, app, baseUrl, user.Email)
+
+		// A forwarded link doesn't work without the requesting browser's cookie
+		res = openMagicLink(t, app, path, nil)
+		assert.Equal(t, 401, res.StatusCode)
+		res = openMagicLink(t, app, path, &http.Cookie{Name: "magicLink", Value: "someone-elses-nonce"})
+		assert.Equal(t, 401, res.StatusCode)
+
+		// A tampered link is refused
+		res = openMagicLink(t, app, path+"x", nonce)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// A spoofed Host header doesn't change where the emailed link points
+		req := httptest.NewRequest("POST", fmt.Sprintf("%s/auth/magic-link", baseUrl), strings.NewReader(fmt.Sprintf(`{"email":"%s"}`, user.Email)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Host = "attacker.example.com"
+		res, _ = app.Test(req)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		link, _ := url.Parse(body["data"].(map[string]interface{})["link"].(string))
+		assert.NotEqual(t, "attacker.example.com", link.Host)
+		path, nonce = link.Path, nil
+		for _, cookie := range res.Cookies() {
+			if cookie.Name == "magicLink" {
+				nonce = cookie
+			}
+		}
+
+		// The requesting browser is signed in
+		res = openMagicLink(t, app, path, nonce)
+		assert.Equal(t, 201, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		assert.NotEmpty(t, data["access"])
+
+		// Each link works once
+		res = openMagicLink(t, app, path, nonce)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Asking again replaces the previous link
+		oldPath, oldNonce := requestMagicLink(t, app, baseUrl, user.Email)
+		path, nonce = requestMagicLink(t, app, baseUrl, user.Email)
+		res = openMagicLink(t, app, oldPath, oldNonce)
+		assert.Equal(t, 400, res.StatusCode)
+		res = openMagicLink(t, app, path, nonce)
+		assert.Equal(t, 201, res.StatusCode)
+	})
+}
+
+func TestMagicLinks(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1"
+
+	// Run Magic Link Endpoint Tests
+	loginWithMagicLink(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/DanSmirnov48/techno-trades-go-backend/config"
 	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
 	"github.com/DanSmirnov48/techno-trades-go-backend/routes"
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
@@ -21,6 +22,9 @@ import (
 	"gorm.io/gorm/logger"
 )
 
+// Deterministic payment provider shared by every test app, so no test talks to Stripe
+var paymentProvider = payments.NewFakeProvider()
+
 func CreateSingleTable(db *gorm.DB, model interface{}) {
 	db.AutoMigrate(&model)
 }
@@ -74,7 +78,7 @@ func Setup(t *testing.T, app *fiber.App) *gorm.DB {
 	// Set up the test database
 	db := SetupTestDatabase(t)
 
-	routes.SetupRoutes(app, db)
+	routes.SetupRoutes(app, db, paymentProvider)
 	t.Logf("Making Database Migrations....")
 	database.DropTables(db)
 	database.CreateTables(db)
@@ -109,3 +113,14 @@ func ProcessTestBody(t *testing.T, app *fiber.App, url string, method string, bo
 	}
 	return res
 }
+
+func LoginTestUser(t *testing.T, app *fiber.App, email string) string {
+	loginData := map[string]string{"email": email, "password": "testpassword"}
+	res := ProcessTestBody(t, app, "/api/v1/auth/login", "POST", loginData)
+	assert.Equal(t, 201, res.StatusCode)
+
+	// Extract the access token from the login response data
+	body := ParseResponseBody(t, res.Body).(map[string]interface{})
+	tokenData := body["data"].(map[string]interface{})
+	return tokenData["access"].(string)
+}
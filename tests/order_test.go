@@ -0,0 +1,254 @@
+package tests
+
+import (
+	"encoding/csv"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func orderLifecycle(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Order Lifecycle", func(t *testing.T) {
+		// An order can go all the way from pending to refunded, with each step stamped
+		order := models.Order{Status: models.OrderStatusPending}
+		for _, next := range []models.OrderStatus{
+			models.OrderStatusPaid,
+			models.OrderStatusFulfilled,
+			models.OrderStatusShipped,
+			models.OrderStatusDelivered,
+			models.OrderStatusRefunded,
+		} {
+			assert.Nil(t, order.TransitionTo(next))
+			assert.Equal(t, next, order.Status)
+		}
+		assert.NotNil(t, order.PaidAt)
+		assert.NotNil(t, order.FulfilledAt)
+		assert.NotNil(t, order.ShippedAt)
+		assert.NotNil(t, order.DeliveredAt)
+		assert.NotNil(t, order.RefundedAt)
+
+		// Pending and paid orders can be cancelled
+		assert.True(t, models.OrderStatusPending.CanTransitionTo(models.OrderStatusCancelled))
+		assert.True(t, models.OrderStatusPaid.CanTransitionTo(models.OrderStatusCancelled))
+		cancelled := models.Order{Status: models.OrderStatusPaid}
+		assert.Nil(t, cancelled.TransitionTo(models.OrderStatusCancelled))
+		assert.NotNil(t, cancelled.CancelledAt)
+
+		// Orders can't go backwards, skip steps or leave a terminal status
+		rejected := map[models.OrderStatus]models.OrderStatus{
+			models.OrderStatusDelivered: models.OrderStatusPending,
+			models.OrderStatusCancelled: models.OrderStatusPaid,
+			models.OrderStatusRefunded:  models.OrderStatusDelivered,
+			models.OrderStatusShipped:   models.OrderStatusFulfilled,
+			models.OrderStatusPending:   models.OrderStatusShipped,
+			models.OrderStatusFulfilled: models.OrderStatusCancelled,
+		}
+		for from, to := range rejected {
+			assert.False(t, from.CanTransitionTo(to))
+			order := models.Order{Status: from}
+			assert.NotNil(t, order.TransitionTo(to))
+			assert.Equal(t, from, order.Status)
+		}
+
+		// Buyers can only cancel before fulfilment starts
+		for status, cancellable := range map[models.OrderStatus]bool{
+			models.OrderStatusPending:   true,
+			models.OrderStatusPaid:      true,
+			models.OrderStatusFulfilled: false,
+			models.OrderStatusShipped:   false,
+			models.OrderStatusDelivered: false,
+			models.OrderStatusCancelled: false,
+			models.OrderStatusRefunded:  false,
+		} {
+			order := models.Order{Status: status}
+			assert.Equal(t, cancellable, order.IsCancellable(), status)
+		}
+	})
+}
+
+func searchOrders(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Search Orders", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		admin := CreateVerifiedTestAdminUser(db)
+		adminToken := LoginTestUser(t, app, admin.Email)
+		pending := CreateTestOrder(db, user.ID, product, 1)
+		paid := CreateTestPaidOrder(db, user.ID, product, 1)
+		CreateTestOrder(db, admin.ID, product, 1)
+
+		// Customers can't search everyone's orders
+		res := ProcessTestBody(t, app, baseUrl, "GET", nil, accessToken)
+		assert.Equal(t, 401, res.StatusCode)
+
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s?email=testverified", baseUrl), "GET", nil, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		orders := data["orders"].([]interface{})
+		assert.Equal(t, 2, len(orders))
+		assert.Equal(t, user.Email, orders[0].(map[string]interface{})["customer_email"])
+
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s?order_number=%s", baseUrl, pending.OrderNumber), "GET", nil, adminToken)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		orders = body["data"].(map[string]interface{})["orders"].([]interface{})
+		assert.Equal(t, 1, len(orders))
+		assert.Equal(t, pending.ID.String(), orders[0].(map[string]interface{})["id"])
+
+		today := time.Now().Format("2006-01-02")
+		url := fmt.Sprintf("%s?status=paid&from=%s&to=%s", baseUrl, today, today)
+		res = ProcessTestBody(t, app, url, "GET", nil, adminToken)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		orders = body["data"].(map[string]interface{})["orders"].([]interface{})
+		assert.Equal(t, 1, len(orders))
+		assert.Equal(t, paid.ID.String(), orders[0].(map[string]interface{})["id"])
+
+		// Nothing placed tomorrow yet
+		tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s?from=%s", baseUrl, tomorrow), "GET", nil, adminToken)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, 0, len(body["data"].(map[string]interface{})["orders"].([]interface{})))
+
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s?from=yesterday", baseUrl), "GET", nil, adminToken)
+		assert.Equal(t, 400, res.StatusCode)
+	})
+}
+
+func exportOrders(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Export Orders", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		admin := CreateVerifiedTestAdminUser(db)
+		adminToken := LoginTestUser(t, app, admin.Email)
+		order := CreateTestPaidOrder(db, user.ID, product, 3)
+
+		url := fmt.Sprintf("%s/export?order_number=%s", baseUrl, order.OrderNumber)
+		res := ProcessTestBody(t, app, url, "GET", nil, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, "text/csv", res.Header.Get("Content-Type"))
+
+		rows, err := csv.NewReader(res.Body).ReadAll()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(rows))
+		assert.Equal(t, "order_number", rows[0][0])
+		assert.Equal(t, order.OrderNumber, rows[1][0])
+		assert.Equal(t, "paid", rows[1][2])
+		assert.Equal(t, user.Email, rows[1][4])
+		assert.Equal(t, "3", rows[1][5])
+		assert.Equal(t, order.Total.Decimal(), rows[1][10])
+	})
+}
+
+func bulkUpdateOrderStatus(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Bulk Update Order Status", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		admin := CreateVerifiedTestAdminUser(db)
+		adminToken := LoginTestUser(t, app, admin.Email)
+		first := CreateTestPaidOrder(db, user.ID, product, 1)
+		second := CreateTestPaidOrder(db, user.ID, product, 1)
+		pending := CreateTestOrder(db, user.ID, product, 1)
+
+		data := schemas.BulkOrderStatus{
+			OrderIds: []uuid.UUID{first.ID, second.ID, pending.ID},
+			Status:   models.OrderStatusFulfilled,
+		}
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/status", baseUrl), "PATCH", data, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		result := body["data"].(map[string]interface{})
+		assert.Equal(t, 2, len(result["updated"].([]interface{})))
+		failed := result["failed"].(map[string]interface{})
+		assert.Equal(t, 1, len(failed))
+		assert.Contains(t, failed, pending.ID.String())
+
+		updated, _, _ := orderManager.GetById(db, second.ID)
+		assert.Equal(t, models.OrderStatusFulfilled, updated.Status)
+
+		// Cancelling and refunding aren't bulk actions
+		data.Status = models.OrderStatusCancelled
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/status", baseUrl), "PATCH", data, adminToken)
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Neither is shipping, which needs each parcel's tracking details
+		data.Status = models.OrderStatusShipped
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/status", baseUrl), "PATCH", data, adminToken)
+		assert.Equal(t, 422, res.StatusCode)
+	})
+}
+
+func shipOrderWithNotes(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Ship Order With Notes", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		admin := CreateVerifiedTestAdminUser(db)
+		adminToken := LoginTestUser(t, app, admin.Email)
+		order := CreateTestPaidOrder(db, user.ID, product, 1)
+
+		shipData := schemas.ShipOrder{Carrier: "Royal Mail", TrackingNumber: "RM123456785GB"}
+		url := fmt.Sprintf("%s/%s/ship", baseUrl, order.ID)
+
+		// It has to be fulfilled first
+		res := ProcessTestBody(t, app, url, "PATCH", shipData, adminToken)
+		assert.Equal(t, 400, res.StatusCode)
+		orderManager.UpdateStatus(db, order, models.OrderStatusFulfilled)
+
+		res = ProcessTestBody(t, app, url, "PATCH", shipData, accessToken)
+		assert.Equal(t, 401, res.StatusCode)
+		res = ProcessTestBody(t, app, url, "PATCH", shipData, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		noteData := schemas.CreateOrderNote{Body: "Customer asked for a signed-for delivery"}
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s/notes", baseUrl, order.ID), "POST", noteData, accessToken)
+		assert.Equal(t, 401, res.StatusCode)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s/notes", baseUrl, order.ID), "POST", noteData, adminToken)
+		assert.Equal(t, 201, res.StatusCode)
+
+		// Staff see the tracking details and their notes
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", baseUrl, order.ID), "GET", nil, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		staffOrder := data["order"].(map[string]interface{})
+		assert.Equal(t, "shipped", staffOrder["status"])
+		assert.Equal(t, "Royal Mail", staffOrder["carrier"])
+		assert.Equal(t, "RM123456785GB", staffOrder["tracking_number"])
+		notes := data["notes"].([]interface{})
+		assert.Equal(t, 1, len(notes))
+		assert.Equal(t, noteData.Body, notes[0].(map[string]interface{})["body"])
+
+		// The customer gets the tracking but never the notes
+		res = ProcessTestBody(t, app, fmt.Sprintf("/api/v1/users/me/orders/%s", order.ID), "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		data = body["data"].(map[string]interface{})
+		assert.Equal(t, "RM123456785GB", data["order"].(map[string]interface{})["tracking_number"])
+		assert.NotContains(t, data, "notes")
+	})
+}
+
+func TestOrders(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/orders"
+
+	// Run Order Tests
+	orderLifecycle(t, app, db, BASEURL)
+	searchOrders(t, app, db, BASEURL)
+	exportOrders(t, app, db, BASEURL)
+	bulkUpdateOrderStatus(t, app, db, BASEURL)
+	shipOrderWithNotes(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// beginPasskeyCeremony calls a begin endpoint and returns the ceremony id and options
+func beginPasskeyCeremony(t *testing.T, app *fiber.App, url string, body interface{}, access ...string) (string, map[string]interface{}) {
+	res := ProcessTestBody(t, app, url, "POST", body, access...)
+	assert.Equal(t, 200, res.StatusCode)
+	data := ParseResponseBody(t, res.Body).(map[string]interface{})["data"].(map[string]interface{})
+	return data["ceremony_id"].(string), data["options"].(map[string]interface{})
+}
+
+func passkeyLogin(t *testing.T, app *fiber.App, baseUrl string, authenticator *SoftAuthenticator) *http.Response {
+	ceremonyId, options := beginPasskeyCeremony(t, app, fmt.Sprintf("%s/auth/passkeys/login/begin", baseUrl), nil)
+	finishData := map[string]interface{}{"ceremony_id": ceremonyId, "credential": authenticator.Login(options)}
+	return ProcessTestBody(t, app, fmt.Sprintf("%s/auth/passkeys/login/finish", baseUrl), "POST", finishData)
+}
+
+func registerPasskey(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Register Passkey", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		accessToken := LoginTestUser(t, app, user.Email)
+		authenticator := NewSoftAuthenticator()
+		url := fmt.Sprintf("%s/auth/passkeys/register/finish", baseUrl)
+
+		ceremonyId, options := beginPasskeyCeremony(t, app, fmt.Sprintf("%s/auth/passkeys/register/begin", baseUrl), nil, accessToken)
+		finishData := map[string]interface{}{"ceremony_id": ceremonyId, "credential": authenticator.Register(options), "name": "Test Laptop"}
+		res := ProcessTestBody(t, app, url, "POST", finishData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		passkey := body["data"].(map[string]interface{})["passkey"].(map[string]interface{})
+		assert.Equal(t, "Test Laptop", passkey["name"])
+
+		// A ceremony can only be finished once
+		res = ProcessTestBody(t, app, url, "POST", finishData, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// The passkey signs its owner in, and the sign count is kept
+		assert.Equal(t, 201, passkeyLogin(t, app, baseUrl, authenticator).StatusCode)
+		stored := models.Passkey{}
+		db.Take(&stored, "user_id = ?", user.ID)
+		assert.Equal(t, uint32(1), stored.SignCount)
+		assert.NotNil(t, stored.LastUsedAt)
+
+		// A key that doesn't verify the user is only one factor, so it's refused
+		authenticator.SkipUserVerification = true
+		assert.Equal(t, 400, passkeyLogin(t, app, baseUrl, authenticator).StatusCode)
+		authenticator.SkipUserVerification = false
+
+		// A key whose count goes backwards looks cloned and is refused
+		authenticator.SignCount = 0
+		assert.Equal(t, 400, passkeyLogin(t, app, baseUrl, authenticator).StatusCode)
+
+		// Unknown passkeys are refused
+		assert.Equal(t, 400, passkeyLogin(t, app, baseUrl, NewSoftAuthenticator()).StatusCode)
+
+		// Rename and remove it
+		passkeyUrl := fmt.Sprintf("%s/users/me/passkeys/%s", baseUrl, passkey["id"])
+		res = ProcessTestBody(t, app, passkeyUrl, "PATCH", map[string]string{"name": "Work Laptop"}, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/users/me/passkeys", baseUrl), "GET", nil, accessToken)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(1), data["length"])
+		assert.Equal(t, "Work Laptop", data["passkeys"].([]interface{})[0].(map[string]interface{})["name"])
+		res = ProcessTestBody(t, app, passkeyUrl, "DELETE", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+	})
+}
+
+func signupWithPasskey(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Signup With Passkey", func(t *testing.T) {
+		authenticator := NewSoftAuthenticator()
+		signupData := map[string]string{"first_name": "Pass", "last_name": "Key", "email": "testpasskey@example.com"}
+		beginUrl := fmt.Sprintf("%s/auth/passkeys/signup/begin", baseUrl)
+
+		ceremonyId, options := beginPasskeyCeremony(t, app, beginUrl, signupData)
+		finishData := map[string]interface{}{"ceremony_id": ceremonyId, "credential": authenticator.Register(options)}
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/auth/passkeys/signup/finish", baseUrl), "POST", finishData)
+		assert.Equal(t, 201, res.StatusCode)
+
+		user := models.User{}
+		db.Take(&user, "email = ?", signupData["email"])
+		assert.Equal(t, models.AuthTypePasskey, user.AuthType)
+
+		// The email is taken now
+		res = ProcessTestBody(t, app, beginUrl, "POST", signupData)
+		assert.Equal(t, 422, res.StatusCode)
+
+		// Like any new account, the email has to be verified first
+		assert.Equal(t, 401, passkeyLogin(t, app, baseUrl, authenticator).StatusCode)
+		db.Model(&user).Update("is_email_verified", true)
+		res = passkeyLogin(t, app, baseUrl, authenticator)
+		assert.Equal(t, 201, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		accessToken := body["data"].(map[string]interface{})["access"].(string)
+
+		// With no password, the only passkey can't be removed
+		passkey := models.Passkey{}
+		db.Take(&passkey, "user_id = ?", user.ID)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/users/me/passkeys/%s", baseUrl, passkey.ID), "DELETE", nil, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+	})
+}
+
+func TestPasskeys(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1"
+
+	// Run Passkey Endpoint Tests
+	registerPasskey(t, app, db, BASEURL)
+	signupWithPasskey(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func reuseStripeCustomer(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Reuse Stripe Customer", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		customers := len(paymentProvider.Customers)
+
+		// ### Checkout and payment intent share one customer
+		FillTestCart(db, user.ID, product, 1)
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/stripe/create-checkout-session", baseUrl), "POST", CheckoutTestData(db, user.ID), accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		FillTestCart(db, user.ID, product, 1)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/stripe/create-payment-intent", baseUrl), "POST", CheckoutTestData(db, user.ID), accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		assert.Equal(t, customers+1, len(paymentProvider.Customers))
+		stored := models.User{}
+		db.Take(&stored, "id = ?", user.ID)
+		assert.NotNil(t, stored.StripeCustomerID)
+		assert.Equal(t, *stored.StripeCustomerID, paymentProvider.LastCheckout().CustomerID)
+		assert.Equal(t, *stored.StripeCustomerID, paymentProvider.LastIntent().CustomerID)
+
+		// ### Profile changes reach the customer
+		updateData := schemas.UpdateUserRequestSchema{FirstName: "Renamed"}
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/users/update-me", baseUrl), "PATCH", updateData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+		update := paymentProvider.CustomerUpdates[*stored.StripeCustomerID]
+		assert.Equal(t, "Renamed Verified", update.Name)
+		assert.Equal(t, user.Email, update.Email)
+	})
+}
+
+func managePaymentMethods(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Manage Payment Methods", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		accessToken := LoginTestUser(t, app, user.Email)
+		admin := CreateVerifiedTestAdminUser(db)
+		db.Take(&user, "id = ?", user.ID)
+		url := fmt.Sprintf("%s/users/me/payment-methods", baseUrl)
+
+		// Nobody else's cards can be removed
+		adminToken := LoginTestUser(t, app, admin.Email)
+		adminCustomer := "cus_someone_else"
+		db.Model(&admin).Update("stripe_customer_id", adminCustomer)
+		other := paymentProvider.AddPaymentMethod(adminCustomer, "mastercard", "4444")
+
+		visa := paymentProvider.AddPaymentMethod(*user.StripeCustomerID, "visa", "4242")
+		paymentProvider.AddPaymentMethod(*user.StripeCustomerID, "amex", "0005")
+
+		res := ProcessTestBody(t, app, url, "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(2), data["length"])
+		first := data["payment_methods"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, visa.ID, first["id"])
+		assert.Equal(t, "4242", first["last4"])
+
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", url, other.ID), "DELETE", nil, accessToken)
+		assert.Equal(t, 404, res.StatusCode)
+
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", url, visa.ID), "DELETE", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		methods, _ := paymentProvider.ListPaymentMethods(*user.StripeCustomerID)
+		assert.Equal(t, 1, len(methods))
+
+		res = ProcessTestBody(t, app, url, "GET", nil, adminToken)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, float64(1), body["data"].(map[string]interface{})["length"])
+	})
+}
+
+func TestPaymentMethods(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1"
+
+	// Run Payment Method Tests
+	reuseStripeCustomer(t, app, db, BASEURL)
+	managePaymentMethods(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
@@ -43,7 +43,7 @@ func create(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 			Brand:        "test_products",
 			Category:     "test_products",
 			Description:  "this is a product description blah blah blah",
-			Price:        100,
+			Price:        10000,
 			CountInStock: 100,
 			IsDiscounted: false,
 		}
@@ -143,6 +143,52 @@ func delete(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
 	})
 }
 
+func updateStock(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Update Product Stock Records Movement", func(t *testing.T) {
+		adminUser := CreateVerifiedTestAdminUser(db)
+		accessToken := LoginTestUser(t, app, adminUser.Email)
+
+		product := CreateNewProduct(db, adminUser.ID)
+		url := fmt.Sprintf("%s/%s/update-stock", baseUrl, product.ID)
+		stockData := schemas.UpdateStockSchema{StockChange: 5, Reason: "restock"}
+
+		res := ProcessTestBody(t, app, url, "PATCH", stockData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// The change shows up in the product's ledger with its actor
+		url = fmt.Sprintf("%s/%s/stock-movements", baseUrl, product.ID)
+		res = ProcessTestBody(t, app, url, "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		movements := data["stock_movements"].([]interface{})
+		latest := movements[0].(map[string]interface{})
+		assert.Equal(t, float64(5), latest["delta"])
+		assert.Equal(t, "restock", latest["reason"])
+		assert.Equal(t, adminUser.ID.String(), latest["actor_id"])
+
+		// Units held for a pending order can't be adjusted away
+		CreateTestOrder(db, adminUser.ID, product, 100)
+		url = fmt.Sprintf("%s/%s/update-stock", baseUrl, product.ID)
+		res = ProcessTestBody(t, app, url, "PATCH", schemas.UpdateStockSchema{StockChange: -10, Reason: "adjustment"}, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s/update", baseUrl, product.ID), "PATCH", schemas.UpdateProduct{CountInStock: 50}, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+		res = ProcessTestBody(t, app, url, "PATCH", schemas.UpdateStockSchema{StockChange: -5, Reason: "adjustment"}, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Stock and ledger agree
+		drifts, err := productManager.ReconcileStock(db)
+		assert.Nil(t, err)
+		for _, drift := range drifts {
+			if drift.ProductId == product.ID {
+				assert.False(t, drift.HasDrift())
+			}
+		}
+	})
+}
+
 func TestProduct(t *testing.T) {
 	app := fiber.New()
 	db := Setup(t, app)
@@ -152,6 +198,7 @@ func TestProduct(t *testing.T) {
 	create(t, app, db, BASEURL)
 	update(t, app, db, BASEURL)
 	delete(t, app, db, BASEURL)
+	updateStock(t, app, db, BASEURL)
 
 	// Drop Tables and Close Connectiom
 	database.DropTables(db)
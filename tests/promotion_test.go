@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var promotionManager = managers.PromotionManager{}
+
+func createPromotion(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Create Live Promotion", func(t *testing.T) {
+		adminUser := CreateVerifiedTestAdminUser(db)
+		accessToken := LoginTestUser(t, app, adminUser.Email)
+		product := CreateNewProduct(db, adminUser.ID)
+
+		promotionData := schemas.CreatePromotion{
+			Name:       "Flash sale",
+			Type:       models.PromotionPercentage,
+			Value:      25,
+			TargetType: models.PromotionTargetProduct,
+			Target:     product.ID.String(),
+			StartsAt:   time.Now().Add(-time.Hour),
+			EndsAt:     time.Now().Add(time.Hour),
+		}
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/", baseUrl), "POST", promotionData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		promotion := body["data"].(map[string]interface{})["promotion"].(map[string]interface{})
+		assert.Equal(t, string(models.PromotionActive), promotion["status"])
+
+		// 25% off 100.00
+		promoted, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, int64(7500), promoted.EffectivePrice.Amount)
+	})
+}
+
+func scheduledPromotion(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Scheduled Promotion Window", func(t *testing.T) {
+		adminUser := CreateVerifiedTestAdminUser(db)
+		product := CreateNewProduct(db, adminUser.ID)
+
+		promotion, _, _ := promotionManager.Create(db, schemas.CreatePromotion{
+			Name:       "Black Friday",
+			Type:       models.PromotionFixed,
+			Value:      1500,
+			TargetType: models.PromotionTargetBrand,
+			Target:     product.Brand,
+			StartsAt:   time.Now().Add(time.Hour),
+			EndsAt:     time.Now().Add(2 * time.Hour),
+		}, adminUser.ID)
+		assert.Equal(t, models.PromotionScheduled, promotion.Status)
+
+		// Not live before the window opens
+		listed, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, product.Price.Amount, listed.EffectivePrice.Amount)
+
+		// The scheduler activates it once the window opens
+		db.Model(promotion).Updates(map[string]interface{}{"starts_at": time.Now().Add(-time.Minute)})
+		promotionManager.SyncStatuses(db)
+		listed, _, _ = productManager.GetById(db, product.ID)
+		assert.Equal(t, product.Price.Amount-1500, listed.EffectivePrice.Amount)
+
+		// ...and expires it once the window closes
+		db.Model(promotion).Updates(map[string]interface{}{"ends_at": time.Now().Add(-time.Second)})
+		promotionManager.SyncStatuses(db)
+		expired, _, _ := promotionManager.GetById(db, promotion.ID)
+		assert.Equal(t, models.PromotionExpired, expired.Status)
+		listed, _, _ = productManager.GetById(db, product.ID)
+		assert.Equal(t, product.Price.Amount, listed.EffectivePrice.Amount)
+	})
+}
+
+func TestPromotion(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/promotions"
+
+	// Run Promotion Tests
+	createPromotion(t, app, db, BASEURL)
+	scheduledPromotion(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var returnManager = managers.ReturnManager{}
+
+func approveReturn(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Approve Return Refunds And Restocks", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		admin := CreateVerifiedTestAdminUser(db)
+		adminToken := LoginTestUser(t, app, admin.Email)
+		order := CreateTestDeliveredOrder(db, user.ID, product, 2)
+
+		// ### The customer returns one of the two units
+		returnData := schemas.CreateReturn{
+			OrderId: order.ID,
+			Items:   []schemas.ReturnItemSchema{{OrderItemId: order.Items[0].ID, Quantity: 1}},
+			Reason:  models.ReturnReasonDamaged,
+			Details: "The box arrived crushed",
+		}
+		res := ProcessTestBody(t, app, baseUrl, "POST", returnData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		rmaId := body["data"].(map[string]interface{})["return"].(map[string]interface{})["id"].(string)
+
+		// Only one unit is left to return
+		returnData.Items[0].Quantity = 2
+		res = ProcessTestBody(t, app, baseUrl, "POST", returnData, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Customers can't approve their own returns
+		url := fmt.Sprintf("%s/%s/approve", baseUrl, rmaId)
+		approveData := schemas.ApproveReturn{Restock: true, Note: "Resellable"}
+		res = ProcessTestBody(t, app, url, "PATCH", approveData, accessToken)
+		assert.Equal(t, 401, res.StatusCode)
+
+		// ### Staff approve it, which refunds and restocks the unit
+		res = ProcessTestBody(t, app, url, "PATCH", approveData, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		rma, _, _ := returnManager.GetById(db, uuid.MustParse(rmaId))
+		assert.Equal(t, models.ReturnStatusRefunded, rma.Status)
+		assert.Equal(t, int64(10000), rma.Refund.Amount)
+		assert.Equal(t, 3, len(rma.History))
+		refund := paymentProvider.Refunds[len(paymentProvider.Refunds)-1]
+		assert.Equal(t, int64(10000), refund.Amount)
+		assert.Equal(t, fmt.Sprintf("pi_%s", order.ID), refund.PaymentIntentID)
+		updatedProduct, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock-1, updatedProduct.CountInStock)
+
+		// ### A later full refund only restocks the unit that wasn't returned
+		charge := map[string]interface{}{
+			"id":              fmt.Sprintf("ch_%s", order.ID),
+			"object":          "charge",
+			"payment_intent":  fmt.Sprintf("pi_%s", order.ID),
+			"amount_refunded": 20000,
+			"refunded":        true,
+			"currency":        "gbp",
+		}
+		SendStripeEvent(t, app, "/api/v1/stripe/webhook", fmt.Sprintf("evt_refund_%s", order.ID), "charge.refunded", charge)
+		updatedProduct, _, _ = productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock, updatedProduct.CountInStock)
+	})
+}
+
+func rejectReturn(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Reject Return", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		admin := CreateVerifiedTestAdminUser(db)
+		adminToken := LoginTestUser(t, app, admin.Email)
+
+		// Orders that haven't been delivered can't be returned
+		pending := CreateTestOrder(db, user.ID, product, 1)
+		returnData := schemas.CreateReturn{
+			OrderId: pending.ID,
+			Items:   []schemas.ReturnItemSchema{{OrderItemId: pending.Items[0].ID, Quantity: 1}},
+			Reason:  models.ReturnReasonNoLongerNeeded,
+		}
+		res := ProcessTestBody(t, app, baseUrl, "POST", returnData, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		order := CreateTestDeliveredOrder(db, user.ID, product, 1)
+		returnData.OrderId = order.ID
+		returnData.Items[0].OrderItemId = order.Items[0].ID
+		res = ProcessTestBody(t, app, baseUrl, "POST", returnData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		rmaId := body["data"].(map[string]interface{})["return"].(map[string]interface{})["id"].(string)
+
+		url := fmt.Sprintf("%s/%s/reject", baseUrl, rmaId)
+		res = ProcessTestBody(t, app, url, "PATCH", schemas.RejectReturn{Note: "Item has been used"}, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// The customer sees the outcome and the staff note in the history
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", baseUrl, rmaId), "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		rma := body["data"].(map[string]interface{})["return"].(map[string]interface{})
+		assert.Equal(t, "rejected", rma["status"])
+		history := rma["history"].([]interface{})
+		assert.Equal(t, "Item has been used", history[len(history)-1].(map[string]interface{})["note"])
+
+		// A rejected return is closed, but its units can be asked for again
+		res = ProcessTestBody(t, app, url, "PATCH", schemas.RejectReturn{Note: "Again"}, adminToken)
+		assert.Equal(t, 400, res.StatusCode)
+		res = ProcessTestBody(t, app, baseUrl, "POST", returnData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+	})
+}
+
+func concurrentApproval(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Concurrent Approvals Restock Once", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		admin := CreateVerifiedTestAdminUser(db)
+		order := CreateTestDeliveredOrder(db, user.ID, product, 1)
+		created, _, _ := returnManager.Create(db, user.ID, schemas.CreateReturn{
+			OrderId: order.ID,
+			Items:   []schemas.ReturnItemSchema{{OrderItemId: order.Items[0].ID, Quantity: 1}},
+			Reason:  models.ReturnReasonDefective,
+		})
+
+		// Two staff members open the same return before either approves it
+		first, _, _ := returnManager.GetById(db, created.ID)
+		second, _, _ := returnManager.GetById(db, created.ID)
+		approveData := schemas.ApproveReturn{Restock: true}
+		_, errCode, _ := returnManager.Approve(db, first, admin.ID, approveData)
+		assert.Nil(t, errCode)
+		_, errCode, _ = returnManager.Approve(db, second, admin.ID, approveData)
+		assert.Equal(t, 409, *errCode)
+
+		// The bought unit went back once
+		updatedProduct, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock, updatedProduct.CountInStock)
+
+		// A retry after the return failed to close gets the first refund back
+		adminToken := LoginTestUser(t, app, admin.Email)
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/%s/refund", baseUrl, created.ID), "POST", nil, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+		refunds := len(paymentProvider.Refunds)
+		db.Model(&models.ReturnRequest{}).Where("id = ?", created.ID).Update("status", models.ReturnStatusApproved)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s/refund", baseUrl, created.ID), "POST", nil, adminToken)
+		assert.Equal(t, 200, res.StatusCode)
+		assert.Equal(t, refunds, len(paymentProvider.Refunds))
+	})
+}
+
+func TestReturn(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/returns"
+
+	// Run Return Tests
+	approveReturn(t, app, db, BASEURL)
+	rejectReturn(t, app, db, BASEURL)
+	concurrentApproval(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
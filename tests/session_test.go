@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func manageSessions(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Manage Sessions", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		laptopToken := LoginTestUser(t, app, user.Email)
+		phoneToken := LoginTestUser(t, app, user.Email)
+		url := fmt.Sprintf("%s/users/me/sessions", baseUrl)
+
+		// Signing in on a second device leaves the first one signed in
+		res := ProcessTestBody(t, app, url, "GET", nil, laptopToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, float64(2), data["length"])
+
+		phoneId := ""
+		for _, session := range data["sessions"].([]interface{}) {
+			session := session.(map[string]interface{})
+			if session["current"] == false {
+				phoneId = session["id"].(string)
+			}
+		}
+		assert.NotEmpty(t, phoneId)
+
+		// Revoking the phone signs it out but not the laptop
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", url, phoneId), "DELETE", nil, laptopToken)
+		assert.Equal(t, 200, res.StatusCode)
+		res = ProcessTestBody(t, app, url, "GET", nil, phoneToken)
+		assert.Equal(t, 401, res.StatusCode)
+		res = ProcessTestBody(t, app, url, "GET", nil, laptopToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// A revoked session can't be revoked twice
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", url, phoneId), "DELETE", nil, laptopToken)
+		assert.Equal(t, 404, res.StatusCode)
+
+		// Logging out only ends the current session
+		tabletToken := LoginTestUser(t, app, user.Email)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/auth/logout", baseUrl), "GET", nil, tabletToken)
+		assert.Equal(t, 200, res.StatusCode)
+		res = ProcessTestBody(t, app, url, "GET", nil, tabletToken)
+		assert.Equal(t, 401, res.StatusCode)
+		res = ProcessTestBody(t, app, url, "GET", nil, laptopToken)
+		assert.Equal(t, 200, res.StatusCode)
+	})
+}
+
+func logoutEverywhere(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Logout Everywhere", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		laptopToken := LoginTestUser(t, app, user.Email)
+		phoneToken := LoginTestUser(t, app, user.Email)
+
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/auth/logout-all", baseUrl), "GET", nil, laptopToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		for _, token := range []string{laptopToken, phoneToken} {
+			res = ProcessTestBody(t, app, fmt.Sprintf("%s/users/me/sessions", baseUrl), "GET", nil, token)
+			assert.Equal(t, 401, res.StatusCode)
+		}
+	})
+}
+
+// loginTestTokens signs in like LoginTestUser but returns the refresh token too
+func loginTestTokens(t *testing.T, app *fiber.App, email string) (string, string) {
+	loginData := map[string]string{"email": email, "password": "testpassword"}
+	res := ProcessTestBody(t, app, "/api/v1/auth/login", "POST", loginData)
+	assert.Equal(t, 201, res.StatusCode)
+	body := ParseResponseBody(t, res.Body).(map[string]interface{})
+	tokenData := body["data"].(map[string]interface{})
+	return tokenData["access"].(string), tokenData["refresh"].(string)
+}
+
+func rotateRefreshTokens(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Rotate Refresh Tokens", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		url := fmt.Sprintf("%s/auth/refresh", baseUrl)
+		sessionsUrl := fmt.Sprintf("%s/users/me/sessions", baseUrl)
+		otherAccess, _ := loginTestTokens(t, app, user.Email)
+		_, firstRefresh := loginTestTokens(t, app, user.Email)
+
+		// A made up token is refused
+		res := ProcessTestBody(t, app, url, "POST", map[string]string{"refresh": "not-a-token"})
+		assert.Equal(t, 401, res.StatusCode)
+
+		// An access token isn't a refresh token, and sending one doesn't look
+		// like reuse, so the session stays signed in. Neither works the other way.
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"refresh": otherAccess})
+		assert.Equal(t, 401, res.StatusCode)
+		res = ProcessTestBody(t, app, sessionsUrl, "GET", nil, otherAccess)
+		assert.Equal(t, 200, res.StatusCode)
+		res = ProcessTestBody(t, app, sessionsUrl, "GET", nil, firstRefresh)
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Each refresh works without an access token and hands out a new refresh token
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"refresh": firstRefresh})
+		assert.Equal(t, 201, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		secondRefresh := body["data"].(map[string]interface{})["refresh"].(string)
+		assert.NotEqual(t, firstRefresh, secondRefresh)
+
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"refresh": secondRefresh})
+		assert.Equal(t, 201, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		latestAccess := data["access"].(string)
+		latestRefresh := data["refresh"].(string)
+		res = ProcessTestBody(t, app, sessionsUrl, "GET", nil, latestAccess)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// Replaying a rotated token signs the whole family out
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"refresh": firstRefresh})
+		assert.Equal(t, 401, res.StatusCode)
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"refresh": latestRefresh})
+		assert.Equal(t, 401, res.StatusCode)
+		res = ProcessTestBody(t, app, sessionsUrl, "GET", nil, latestAccess)
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Other devices are left alone
+		res = ProcessTestBody(t, app, sessionsUrl, "GET", nil, otherAccess)
+		assert.Equal(t, 200, res.StatusCode)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1"
+
+	// Run Session Endpoint Tests
+	manageSessions(t, app, db, BASEURL)
+	rotateRefreshTokens(t, app, db, BASEURL)
+	logoutEverywhere(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
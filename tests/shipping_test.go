@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func manageAddresses(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Manage Address Book", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		accessToken := LoginTestUser(t, app, user.Email)
+		url := fmt.Sprintf("%s/users/me/addresses", baseUrl)
+
+		addressData := schemas.AddressSchema{
+			FullName:   "Test User",
+			Line1:      "10 Downing Street",
+			City:       "London",
+			PostalCode: "sw1a 2aa",
+			Country:    "gb",
+			IsDefault:  true,
+		}
+		res := ProcessTestBody(t, app, url, "POST", addressData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		address := body["data"].(map[string]interface{})["address"].(map[string]interface{})
+		assert.Equal(t, "GB", address["country"])
+		assert.Equal(t, "SW1A 2AA", address["postal_code"])
+		assert.Equal(t, true, address["is_default"])
+
+		// A new default takes over from the old one
+		addressData.Line1 = "11 Downing Street"
+		res = ProcessTestBody(t, app, url, "POST", addressData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+		old, _, _ := addressManager.GetForUser(db, user.ID, uuid.MustParse(address["id"].(string)))
+		assert.False(t, old.IsDefault)
+
+		// Other users can't touch the address
+		admin := CreateVerifiedTestAdminUser(db)
+		adminToken := LoginTestUser(t, app, admin.Email)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", url, old.ID), "DELETE", nil, adminToken)
+		assert.Equal(t, 404, res.StatusCode)
+
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", url, old.ID), "DELETE", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+	})
+}
+
+func checkoutChargesShipping(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Checkout Charges Shipping", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		address := CreateTestAddress(db, user.ID, "GB")
+		method := CreateTestShippingMethod(db, 499, 20000)
+		url := fmt.Sprintf("%s/stripe/create-checkout-session", baseUrl)
+
+		// Below the threshold delivery is charged and snapshotted on the order
+		FillTestCart(db, user.ID, product, 1)
+		checkoutData := map[string]interface{}{"address_id": address.ID, "shipping_method_id": method.ID}
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		order, _, _ := orderManager.GetById(db, uuid.MustParse(body["order_id"].(string)))
+		assert.Equal(t, int64(499), order.Shipping.Amount)
+		assert.Equal(t, int64(10499), order.Total.Amount)
+		assert.Equal(t, method.Name, order.ShippingMethodName)
+		assert.Equal(t, "NW1 6XE", order.ShippingAddress.PostalCode)
+
+		checkout := paymentProvider.LastCheckout()
+		assert.Equal(t, int64(499), checkout.ShippingRate.Amount)
+		assert.Equal(t, "GB", checkout.Shipping.Country)
+
+		// At the threshold it is free
+		FillTestCart(db, user.ID, product, 2)
+		res = ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		order, _, _ = orderManager.GetById(db, uuid.MustParse(body["order_id"].(string)))
+		assert.Equal(t, int64(0), order.Shipping.Amount)
+		assert.Equal(t, int64(20000), order.Total.Amount)
+	})
+}
+
+func checkoutRejectsUnshippableAddress(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Checkout Rejects Unshippable Address", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		address := CreateTestAddress(db, user.ID, "US")
+		method := CreateTestShippingMethod(db, 499, 0, "GB", "IE")
+
+		FillTestCart(db, user.ID, product, 1)
+		url := fmt.Sprintf("%s/stripe/create-checkout-session", baseUrl)
+		checkoutData := map[string]interface{}{"address_id": address.ID, "shipping_method_id": method.ID}
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// Nothing is held for the failed checkout
+		assert.Equal(t, 0, productManager.ReservedStock(db, product.ID))
+
+		// The listing filtered by country doesn't offer it either
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/shipping-methods?country=us", baseUrl), "GET", nil)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		for _, listed := range body["data"].(map[string]interface{})["shipping_methods"].([]interface{}) {
+			assert.NotEqual(t, method.ID.String(), listed.(map[string]interface{})["id"])
+		}
+	})
+}
+
+func TestShipping(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1"
+
+	// Run Shipping Tests
+	manageAddresses(t, app, db, BASEURL)
+	checkoutChargesShipping(t, app, db, BASEURL)
+	checkoutRejectsUnshippableAddress(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/payments"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/webhook"
+	"gorm.io/gorm"
+)
+
+// Builds a Stripe event fixture and signs it locally with the fake provider's webhook secret
+func SendStripeEvent(t *testing.T, app *fiber.App, url string, eventId string, eventType string, object map[string]interface{}) map[string]interface{} {
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":          eventId,
+		"object":      "event",
+		"api_version": stripe.APIVersion,
+		"type":        eventType,
+		"data":        map[string]interface{}{"object": object},
+	})
+	assert.Nil(t, err)
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload:   payload,
+		Secret:    payments.FakeWebhookSecret,
+		Timestamp: time.Now(),
+	})
+
+	req := httptest.NewRequest("POST", url, bytes.NewReader(signed.Payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", signed.Header)
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 200, res.StatusCode)
+	return ParseResponseBody(t, res.Body).(map[string]interface{})
+}
+
+func webhookPaymentSucceeded(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Webhook Payment Succeeded", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		order := CreateTestOrder(db, user.ID, product, 2)
+
+		url := fmt.Sprintf("%s/webhook", baseUrl)
+		eventId := fmt.Sprintf("evt_paid_%s", order.ID)
+		intent := map[string]interface{}{
+			"id":       "pi_test_paid",
+			"object":   "payment_intent",
+			"metadata": map[string]string{"orderId": order.ID.String()},
+		}
+
+		body := SendStripeEvent(t, app, url, eventId, "payment_intent.succeeded", intent)
+		assert.Equal(t, false, body["duplicate"])
+
+		paidOrder, _, _ := orderManager.GetById(db, order.ID)
+		assert.Equal(t, models.OrderStatusPaid, paidOrder.Status)
+		updatedProduct, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock-2, updatedProduct.CountInStock)
+
+		// Replaying the same event must not touch stock again
+		body = SendStripeEvent(t, app, url, eventId, "payment_intent.succeeded", intent)
+		assert.Equal(t, true, body["duplicate"])
+		updatedProduct, _, _ = productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock-2, updatedProduct.CountInStock)
+	})
+}
+
+func webhookPaymentFailed(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Webhook Payment Failed", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		order := CreateTestOrder(db, user.ID, product, 1)
+
+		url := fmt.Sprintf("%s/webhook", baseUrl)
+		intent := map[string]interface{}{
+			"id":                 "pi_test_failed",
+			"object":             "payment_intent",
+			"metadata":           map[string]string{"orderId": order.ID.String()},
+			"last_payment_error": map[string]interface{}{"message": "Your card was declined."},
+		}
+		SendStripeEvent(t, app, url, fmt.Sprintf("evt_failed_%s", order.ID), "payment_intent.payment_failed", intent)
+
+		failedOrder, _, _ := orderManager.GetById(db, order.ID)
+		assert.Equal(t, models.OrderStatusPending, failedOrder.Status)
+		assert.Equal(t, "Your card was declined.", failedOrder.LastPaymentError)
+	})
+}
+
+func webhookChargeRefunded(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Webhook Charge Refunded", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		order := CreateTestOrder(db, user.ID, product, 3)
+		orderManager.MarkPaid(db, order, "pi_test_refunded")
+
+		url := fmt.Sprintf("%s/webhook", baseUrl)
+		charge := map[string]interface{}{
+			"id":              "ch_test_refunded",
+			"object":          "charge",
+			"payment_intent":  "pi_test_refunded",
+			"amount_refunded": 30000,
+			"refunded":        true,
+			"currency":        "gbp",
+		}
+		SendStripeEvent(t, app, url, fmt.Sprintf("evt_refund_%s", order.ID), "charge.refunded", charge)
+
+		refundedOrder, _, _ := orderManager.GetById(db, order.ID)
+		assert.Equal(t, models.OrderStatusRefunded, refundedOrder.Status)
+		assert.Equal(t, int64(30000), refundedOrder.Refunded.Amount)
+		updatedProduct, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock, updatedProduct.CountInStock)
+	})
+}
+
+func webhookInvalidSignature(t *testing.T, app *fiber.App, baseUrl string) {
+	t.Run("Webhook Invalid Signature", func(t *testing.T) {
+		url := fmt.Sprintf("%s/webhook", baseUrl)
+		req := httptest.NewRequest("POST", url, bytes.NewReader([]byte(`{"id":"evt_forged"}`)))
+		req.Header.Set("Stripe-Signature", "t=1,v1=forged")
+		res, _ := app.Test(req)
+		assert.Equal(t, 400, res.StatusCode)
+	})
+}
+
+func TestStripeWebhook(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/stripe"
+
+	// Run Stripe Webhook Tests
+	webhookPaymentSucceeded(t, app, db, BASEURL)
+	webhookPaymentFailed(t, app, db, BASEURL)
+	webhookChargeRefunded(t, app, db, BASEURL)
+	webhookInvalidSignature(t, app, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
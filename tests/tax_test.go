@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/managers"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/DanSmirnov48/techno-trades-go-backend/schemas"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var taxManager = managers.TaxManager{}
+
+func orderTaxBreakdown(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Order Tax Breakdown", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		standard := CreateNewProduct(db, user.ID)
+		reduced := CreateNewProduct(db, user.ID)
+		db.Model(reduced).Update("category", "child_car_seats")
+		taxManager.SetCategoryRate(db, schemas.SetTaxRate{Category: "child_car_seats", Band: models.VATBandReduced})
+		coupon := createTestCoupon(db, schemas.CreateCoupon{Type: models.CouponFixed, Value: 2000})
+
+		items := []models.OrderItem{
+			{ProductId: standard.ID, Name: standard.Name, UnitPrice: standard.Price, Quantity: 1},
+			{ProductId: reduced.ID, Name: reduced.Name, UnitPrice: reduced.Price, Quantity: 1},
+		}
+		order, errCode, _ := orderManager.Create(db, user.ID, items, managers.OrderOptions{CouponCode: coupon.Code})
+		assert.Nil(t, errCode)
+
+		// Prices include VAT, and the discount is shared 1000/1000 between
+		// the lines before VAT is extracted
+		order, _, _ = orderManager.GetById(db, order.ID)
+		assert.Equal(t, models.TaxModeInclusive, order.TaxMode)
+		for _, item := range order.Items {
+			if item.ProductId == standard.ID {
+				assert.Equal(t, int64(2000), item.TaxRate)
+				assert.Equal(t, int64(1500), item.Tax.Amount)
+			} else {
+				assert.Equal(t, int64(500), item.TaxRate)
+				assert.Equal(t, int64(429), item.Tax.Amount)
+			}
+		}
+		assert.Equal(t, int64(1929), order.Tax.Amount)
+		assert.Equal(t, int64(18000), order.Total.Amount)
+	})
+}
+
+func categoryCouponTax(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Category Coupon Tax", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		standard := CreateNewProduct(db, user.ID)
+		reduced := CreateNewProduct(db, user.ID)
+		db.Model(reduced).Update("category", "child_car_seats")
+		taxManager.SetCategoryRate(db, schemas.SetTaxRate{Category: "child_car_seats", Band: models.VATBandReduced})
+		coupon := createTestCoupon(db, schemas.CreateCoupon{Type: models.CouponFixed, Value: 2000, Categories: []string{"child_car_seats"}})
+
+		items := []models.OrderItem{
+			{ProductId: standard.ID, Name: standard.Name, UnitPrice: standard.Price, Quantity: 1},
+			{ProductId: reduced.ID, Name: reduced.Name, UnitPrice: reduced.Price, Quantity: 1},
+		}
+		order, errCode, _ := orderManager.Create(db, user.ID, items, managers.OrderOptions{CouponCode: coupon.Code})
+		assert.Nil(t, errCode)
+
+		// The whole discount comes off the reduced rate line it was given for
+		order, _, _ = orderManager.GetById(db, order.ID)
+		for _, item := range order.Items {
+			if item.ProductId == standard.ID {
+				assert.Equal(t, int64(1667), item.Tax.Amount)
+			} else {
+				assert.Equal(t, int64(381), item.Tax.Amount)
+			}
+		}
+		assert.Equal(t, int64(2048), order.Tax.Amount)
+		assert.Equal(t, int64(18000), order.Total.Amount)
+	})
+}
+
+func exportIsZeroRated(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Export Is Zero Rated", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		address := CreateTestAddress(db, user.ID, "US")
+		method := CreateTestShippingMethod(db, 1000, 0, "US")
+
+		FillTestCart(db, user.ID, product, 1)
+		url := fmt.Sprintf("%s/stripe/create-checkout-session", baseUrl)
+		checkoutData := map[string]interface{}{"address_id": address.ID, "shipping_method_id": method.ID}
+		res := ProcessTestBody(t, app, url, "POST", checkoutData, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		order, _, _ := orderManager.GetById(db, uuid.MustParse(body["order_id"].(string)))
+		assert.Equal(t, int64(0), order.Tax.Amount)
+		assert.Equal(t, int64(0), order.ShippingTax.Amount)
+		assert.Equal(t, int64(11000), order.Total.Amount)
+	})
+}
+
+func businessBuyerVATNumber(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Business Buyer VAT Number", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		url := fmt.Sprintf("%s/users/update-me", baseUrl)
+
+		res := ProcessTestBody(t, app, url, "PATCH", schemas.UpdateUserRequestSchema{VATNumber: "FR123"}, accessToken)
+		assert.Equal(t, 422, res.StatusCode)
+
+		updateData := schemas.UpdateUserRequestSchema{CompanyName: "Test Trading Ltd", VATNumber: "gb 123 4567 89"}
+		res = ProcessTestBody(t, app, url, "PATCH", updateData, accessToken)
+		assert.Equal(t, 201, res.StatusCode)
+
+		// New orders carry the buyer's details for the VAT breakdown
+		order := CreateTestOrder(db, user.ID, product, 1)
+		assert.Equal(t, "GB123456789", order.BuyerVATNumber)
+		assert.Equal(t, "Test Trading Ltd", order.BuyerCompany)
+	})
+}
+
+func TestTax(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1"
+
+	// Run Tax Tests
+	orderTaxBreakdown(t, app, db, BASEURL)
+	categoryCouponTax(t, app, db, BASEURL)
+	exportIsZeroRated(t, app, db, BASEURL)
+	businessBuyerVATNumber(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
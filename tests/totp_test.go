@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// enableTestTotp turns two-factor on for the signed in user and returns the
+// secret along with the recovery codes
+func enableTestTotp(t *testing.T, app *fiber.App, baseUrl string, accessToken string) (string, []interface{}) {
+	res := ProcessTestBody(t, app, fmt.Sprintf("%s/auth/2fa/setup", baseUrl), "POST", nil, accessToken)
+	assert.Equal(t, 200, res.StatusCode)
+	body := ParseResponseBody(t, res.Body).(map[string]interface{})
+	data := body["data"].(map[string]interface{})
+	secret := data["secret"].(string)
+	assert.True(t, strings.HasPrefix(data["provisioning_uri"].(string), "otpauth://totp/TechnoTrades:"))
+	assert.True(t, strings.HasPrefix(data["qr_code"].(string), "data:image/png;base64,"))
+
+	res = ProcessTestBody(t, app, fmt.Sprintf("%s/auth/2fa/confirm", baseUrl), "POST", map[string]string{"code": utils.TotpCode(secret, time.Now())}, accessToken)
+	assert.Equal(t, 200, res.StatusCode)
+	body = ParseResponseBody(t, res.Body).(map[string]interface{})
+	codes := body["data"].(map[string]interface{})["recovery_codes"].([]interface{})
+	return secret, codes
+}
+
+// loginForMfaToken logs in a user with two-factor on, stopping at the mfa token
+func loginForMfaToken(t *testing.T, app *fiber.App, baseUrl string, email string) string {
+	loginData := map[string]string{"email": email, "password": "testpassword"}
+	res := ProcessTestBody(t, app, fmt.Sprintf("%s/auth/login", baseUrl), "POST", loginData)
+	assert.Equal(t, 200, res.StatusCode)
+	body := ParseResponseBody(t, res.Body).(map[string]interface{})
+	data := body["data"].(map[string]interface{})
+	assert.Equal(t, true, data["mfa_required"])
+	assert.Nil(t, data["access"])
+	return data["mfa_token"].(string)
+}
+
+func loginWithTotp(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Login With TOTP", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		accessToken := LoginTestUser(t, app, user.Email)
+		url := fmt.Sprintf("%s/auth/2fa/verify", baseUrl)
+
+		// A wrong first code doesn't turn two-factor on
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/auth/2fa/setup", baseUrl), "POST", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/auth/2fa/confirm", baseUrl), "POST", map[string]string{"code": "000000"}, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		secret, recoveryCodes := enableTestTotp(t, app, baseUrl, accessToken)
+		assert.Equal(t, 10, len(recoveryCodes))
+
+		// The mfa token isn't an access token
+		mfaToken := loginForMfaToken(t, app, baseUrl, user.Email)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/users/me/sessions", baseUrl), "GET", nil, mfaToken)
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Wrong codes, and the code already used to confirm, are refused
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"mfa_token": mfaToken, "code": "000000"})
+		assert.Equal(t, 401, res.StatusCode)
+		db.Take(&user, "id = ?", user.ID)
+		usedCode := utils.TotpCode(secret, time.Unix(user.TotpLastStep*30, 0))
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"mfa_token": mfaToken, "code": usedCode})
+		assert.Equal(t, 401, res.StatusCode)
+
+		// The next code from the authenticator finishes the login
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"mfa_token": mfaToken, "code": utils.TotpCode(secret, time.Now().Add(30*time.Second))})
+		assert.Equal(t, 201, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.NotEmpty(t, body["data"].(map[string]interface{})["access"])
+
+		// Recovery codes work once each
+		recoveryCode := recoveryCodes[0].(string)
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"mfa_token": loginForMfaToken(t, app, baseUrl, user.Email), "code": recoveryCode})
+		assert.Equal(t, 201, res.StatusCode)
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"mfa_token": loginForMfaToken(t, app, baseUrl, user.Email), "code": recoveryCode})
+		assert.Equal(t, 401, res.StatusCode)
+
+		// Turning it off takes a valid code too
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/auth/2fa/disable", baseUrl), "POST", map[string]string{"code": "000000"}, accessToken)
+		assert.Equal(t, 401, res.StatusCode)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/auth/2fa/disable", baseUrl), "POST", map[string]string{"code": recoveryCodes[1].(string)}, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		LoginTestUser(t, app, user.Email)
+	})
+}
+
+func totpLockout(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("TOTP Lockout", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		accessToken := LoginTestUser(t, app, user.Email)
+		secret, _ := enableTestTotp(t, app, baseUrl, accessToken)
+		url := fmt.Sprintf("%s/auth/2fa/verify", baseUrl)
+		mfaToken := loginForMfaToken(t, app, baseUrl, user.Email)
+
+		// Guessing stops after five wrong codes, even with a fresh mfa token
+		for i := 0; i < 5; i++ {
+			res := ProcessTestBody(t, app, url, "POST", map[string]string{"mfa_token": mfaToken, "code": "000000"})
+			assert.Equal(t, 401, res.StatusCode)
+		}
+		nextCode := utils.TotpCode(secret, time.Now().Add(30*time.Second))
+		res := ProcessTestBody(t, app, url, "POST", map[string]string{"mfa_token": loginForMfaToken(t, app, baseUrl, user.Email), "code": nextCode})
+		assert.Equal(t, 429, res.StatusCode)
+
+		// Once the lockout runs out the right code works again
+		db.Model(&user).Update("totp_locked_until", time.Now().Add(-time.Minute))
+		res = ProcessTestBody(t, app, url, "POST", map[string]string{"mfa_token": mfaToken, "code": nextCode})
+		assert.Equal(t, 201, res.StatusCode)
+	})
+}
+
+func requireStaffTotp(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Require Staff TOTP", func(t *testing.T) {
+		os.Setenv("STAFF_REQUIRE_2FA", "true")
+		defer os.Setenv("STAFF_REQUIRE_2FA", "false")
+
+		admin := CreateVerifiedTestAdminUser(db)
+		accessToken := LoginTestUser(t, app, admin.Email)
+		url := fmt.Sprintf("%s/orders", baseUrl)
+
+		// Staff can still sign in, but not use staff routes until two-factor is on
+		res := ProcessTestBody(t, app, url, "GET", nil, accessToken)
+		assert.Equal(t, 403, res.StatusCode)
+
+		secret, _ := enableTestTotp(t, app, baseUrl, accessToken)
+		res = ProcessTestBody(t, app, url, "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+
+		// And can't turn it back off
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/auth/2fa/disable", baseUrl), "POST", map[string]string{"code": utils.TotpCode(secret, time.Now().Add(30*time.Second))}, accessToken)
+		assert.Equal(t, 403, res.StatusCode)
+	})
+}
+
+func TestTotp(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1"
+
+	// Run Two-factor Endpoint Tests
+	loginWithTotp(t, app, db, BASEURL)
+	totpLockout(t, app, db, BASEURL)
+	requireStaffTotp(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
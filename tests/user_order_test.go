@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DanSmirnov48/techno-trades-go-backend/database"
+	"github.com/DanSmirnov48/techno-trades-go-backend/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func getMyOrders(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Get My Orders", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		admin := CreateVerifiedTestAdminUser(db)
+		CreateTestOrder(db, user.ID, product, 1)
+		CreateTestOrder(db, user.ID, product, 1)
+		paid := CreateTestPaidOrder(db, user.ID, product, 2)
+		// Other people's orders never show up
+		CreateTestOrder(db, admin.ID, product, 1)
+
+		// Newest first, split into pages
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s?limit=2", baseUrl), "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})
+		orders := data["orders"].([]interface{})
+		assert.Equal(t, 2, len(orders))
+		assert.Equal(t, paid.ID.String(), orders[0].(map[string]interface{})["id"])
+		pagination := data["pagination"].(map[string]interface{})
+		assert.Equal(t, float64(3), pagination["total"])
+		assert.Equal(t, float64(2), pagination["pages"])
+
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s?limit=2&page=2", baseUrl), "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, 1, len(body["data"].(map[string]interface{})["orders"].([]interface{})))
+
+		// Filtered by status
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s?status=paid,shipped", baseUrl), "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body = ParseResponseBody(t, res.Body).(map[string]interface{})
+		orders = body["data"].(map[string]interface{})["orders"].([]interface{})
+		assert.Equal(t, 1, len(orders))
+		assert.Equal(t, "paid", orders[0].(map[string]interface{})["payment_status"])
+
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s?status=lost", baseUrl), "GET", nil, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s?page=0", baseUrl), "GET", nil, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+	})
+}
+
+func getMyOrder(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Get My Order", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+		admin := CreateVerifiedTestAdminUser(db)
+		order := CreateTestDeliveredOrder(db, user.ID, product, 1)
+		orderManager.RecordRefund(db, order, models.NewMoney(5000, order.Total.Currency), false)
+
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/%s", baseUrl, order.ID), "GET", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		data := body["data"].(map[string]interface{})["order"].(map[string]interface{})
+		assert.Equal(t, "delivered", data["status"])
+		assert.Equal(t, "partially_refunded", data["payment_status"])
+		assert.Equal(t, false, data["cancellable"])
+		assert.Equal(t, 1, len(data["items"].([]interface{})))
+
+		// Someone else's order is not found
+		other := CreateTestOrder(db, admin.ID, product, 1)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s", baseUrl, other.ID), "GET", nil, accessToken)
+		assert.Equal(t, 404, res.StatusCode)
+	})
+}
+
+func cancelMyOrder(t *testing.T, app *fiber.App, db *gorm.DB, baseUrl string) {
+	t.Run("Cancel My Order", func(t *testing.T) {
+		user := CreateTestVerifiedUser(db)
+		product := CreateNewProduct(db, user.ID)
+		accessToken := LoginTestUser(t, app, user.Email)
+
+		// ### A pending order just lets go of its stock hold
+		pending := CreateTestOrder(db, user.ID, product, 1)
+		refunds := len(paymentProvider.Refunds)
+		res := ProcessTestBody(t, app, fmt.Sprintf("%s/%s/cancel", baseUrl, pending.ID), "POST", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		body := ParseResponseBody(t, res.Body).(map[string]interface{})
+		assert.Equal(t, "cancelled", body["data"].(map[string]interface{})["order"].(map[string]interface{})["status"])
+		assert.Equal(t, refunds, len(paymentProvider.Refunds))
+
+		// ### A paid order is refunded in full and restocked
+		paid := CreateTestPaidOrder(db, user.ID, product, 2)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s/cancel", baseUrl, paid.ID), "POST", nil, accessToken)
+		assert.Equal(t, 200, res.StatusCode)
+		refund := paymentProvider.Refunds[len(paymentProvider.Refunds)-1]
+		assert.Equal(t, paid.Total.Amount, refund.Amount)
+		assert.Equal(t, fmt.Sprintf("pi_%s", paid.ID), refund.PaymentIntentID)
+		assert.Equal(t, fmt.Sprintf("cancel-%s", paid.ID), refund.IdempotencyKey)
+		updatedProduct, _, _ := productManager.GetById(db, product.ID)
+		assert.Equal(t, product.CountInStock, updatedProduct.CountInStock)
+
+		// Cancelled is final
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s/cancel", baseUrl, paid.ID), "POST", nil, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+
+		// ### Once it's on its way it can't be cancelled
+		delivered := CreateTestDeliveredOrder(db, user.ID, product, 1)
+		res = ProcessTestBody(t, app, fmt.Sprintf("%s/%s/cancel", baseUrl, delivered.ID), "POST", nil, accessToken)
+		assert.Equal(t, 400, res.StatusCode)
+	})
+}
+
+func TestUserOrders(t *testing.T) {
+	app := fiber.New()
+	db := Setup(t, app)
+	BASEURL := "/api/v1/users/me/orders"
+
+	// Run User Order Tests
+	getMyOrders(t, app, db, BASEURL)
+	getMyOrder(t, app, db, BASEURL)
+	cancelMyOrder(t, app, db, BASEURL)
+
+	// Drop Tables and Close Connectiom
+	database.DropTables(db)
+	CloseTestDatabase(db)
+}
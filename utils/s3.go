@@ -7,6 +7,7 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -86,8 +87,48 @@ func UploadFile(file *multipart.FileHeader) (string, error) {
 	return fileURL, nil
 }
 
+// testFiles stands in for the bucket while testing
+var testFiles sync.Map
+
+// UploadBytes stores generated content under the given key and returns its
+// URL. Unlike UploadFile the object stays private, fetch it with GetFile.
+func UploadBytes(fileKey string, content []byte, contentType string) (string, error) {
+	if os.Getenv("ENVIRONMENT") == "TESTING" {
+		testFiles.Store(fileKey, content)
+		return fileKey, nil
+	}
+
+	// Create a new S3 client
+	s3Client, err := newS3Client()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s3Client.s3.PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(s3Client.bucketName),
+		Key:                  aws.String(fileKey),
+		Body:                 bytes.NewReader(content),
+		ContentLength:        aws.Int64(int64(len(content))),
+		ContentType:          aws.String(contentType),
+		ServerSideEncryption: aws.String("AES256"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload file to S3: %v", err)
+	}
+
+	fileURL := fmt.Sprintf("https://%s.s3.amazonaws.com/%s", s3Client.bucketName, fileKey)
+	return fileURL, nil
+}
+
 // GetFile retrieves a file from S3 and returns its content
 func GetFile(fileKey string) ([]byte, error) {
+	if os.Getenv("ENVIRONMENT") == "TESTING" {
+		if content, ok := testFiles.Load(fileKey); ok {
+			return content.([]byte), nil
+		}
+		return nil, fmt.Errorf("file %s does not exist", fileKey)
+	}
+
 	// Create a new S3 client
 	s3Client, err := newS3Client()
 	if err != nil {
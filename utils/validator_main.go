@@ -30,6 +30,7 @@ func init() {
 	customValidator.RegisterValidation("date", DateValidator)
 	customValidator.RegisterValidation("is_uuid", ValidateUUID)
 	customValidator.RegisterValidation("discounted_price_valid", ValidateDiscountedPrice)
+	customValidator.RegisterValidation("vat_number", ValidateVATNumber)
 
 	customValidator.RegisterTagNameFunc(func(fld reflect.StructField) string {
 		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
@@ -55,6 +56,7 @@ func registerTranslations(param string) {
 
 	registerTranslation("discounted_price_valid", "Invalid discounted price value based on the discount status.", translator)
 	registerTranslation("date", "Invalid date format!", translator)
+	registerTranslation("vat_number", "Invalid UK VAT number", translator)
 	registerTranslation("gt", "Value is too small!", translator)
 	registerTranslation("required", "This field is required.", translator)
 	registerTranslation("required_if", "This field is required.", translator)
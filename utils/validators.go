@@ -2,6 +2,8 @@ package utils
 
 import (
 	"reflect"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -33,11 +35,12 @@ func ValidateDiscountedPrice(fl validator.FieldLevel) bool {
 
 	isDiscounted := isDiscountedField.Bool()
 	discountedPriceField := fl.Parent().FieldByName("DiscountedPrice")
-	if !discountedPriceField.IsValid() || discountedPriceField.Kind() != reflect.Float64 {
+	if !discountedPriceField.IsValid() || discountedPriceField.Kind() != reflect.Int64 {
 		return false
 	}
 
-	discountedPrice := discountedPriceField.Float()
+	// Prices are in minor units (pence)
+	discountedPrice := discountedPriceField.Int()
 	if isDiscounted && discountedPrice <= 0 {
 		return false
 	}
@@ -48,3 +51,12 @@ func ValidateDiscountedPrice(fl validator.FieldLevel) bool {
 
 	return true
 }
+
+// UK VAT numbers: GB or XI followed by 9 or 12 digits, or a government
+// department (GD) or health authority (HA) number. Spaces are allowed.
+var vatNumberPattern = regexp.MustCompile(`^(GB|XI)([0-9]{9}|[0-9]{12}|GD[0-4][0-9]{2}|HA[5-9][0-9]{2})$`)
+
+func ValidateVATNumber(fl validator.FieldLevel) bool {
+	number := strings.ToUpper(strings.ReplaceAll(fl.Field().String(), " ", ""))
+	return vatNumberPattern.MatchString(number)
+}